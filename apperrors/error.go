@@ -0,0 +1,98 @@
+package apperrors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Stable error codes, clients may switch on them
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeDuplicate        = "duplicate"
+	CodeAmountTooLarge   = "amount_too_large"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeTooManyRequests  = "too_many_requests"
+	CodeInternal         = "internal_error"
+	CodeMethodNotAllowed = "method_not_allowed"
+)
+
+// Error is an application error, rendered by Handler as an RFC 7807 problem.
+// Detail is shown to the client; the wrapped cause is only logged.
+type Error struct {
+	Status     int
+	Code       string
+	Detail     string
+	Extensions map[string]interface{}
+	cause      error
+}
+
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func BadRequest(detail string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, detail)
+}
+
+// Validation reports the messages of every invalid field, keyed by its path in the body
+func Validation(fields map[string][]string) *Error {
+	return New(http.StatusBadRequest, CodeValidation, "The request body is invalid").With("errors", fields)
+}
+
+func Unauthorized(detail string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func Forbidden(detail string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NotFound(detail string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+func Conflict(detail string) *Error {
+	return New(http.StatusConflict, CodeConflict, detail)
+}
+
+// Internal hides the cause from the client, wrap it with Wrap so it is logged
+func Internal(detail string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, detail)
+}
+
+// WithCode replaces the generic code of the constructor with a more specific one
+func (e *Error) WithCode(code string) *Error {
+	e.Code = code
+	return e
+}
+
+// With adds a member to the problem document
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Extensions == nil {
+		e.Extensions = map[string]interface{}{}
+	}
+	e.Extensions[key] = value
+	return e
+}
+
+// Wrap records the underlying error
+func (e *Error) Wrap(err error) *Error {
+	e.cause = err
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.cause)
+	}
+	return e.Code + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
@@ -0,0 +1,89 @@
+package apperrors
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"fiber/money"
+	"fiber/repositories"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ProblemContentType is the media type of RFC 7807 responses
+const ProblemContentType = "application/problem+json"
+
+// statusCodes gives errors raised by Fiber itself (unknown routes, oversized bodies...) a stable code
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnprocessableEntity:   CodeValidation,
+	http.StatusTooManyRequests:       CodeTooManyRequests,
+}
+
+// Handler is the fiber.Config.ErrorHandler of the app. Handlers and middlewares return
+// *Error values; repository and Fiber errors are translated, anything else is a 500.
+func Handler(c *fiber.Ctx, err error) error {
+	problem := From(err)
+	requestID, _ := c.Locals("requestid").(string)
+
+	if problem.Status >= http.StatusInternalServerError {
+		log.Printf("request %s %s %s failed: %v", requestID, c.Method(), c.Path(), err)
+	}
+
+	body := fiber.Map{}
+	for key, value := range problem.Extensions {
+		body[key] = value
+	}
+	body["type"] = "about:blank"
+	body["title"] = http.StatusText(problem.Status)
+	body["status"] = problem.Status
+	body["detail"] = problem.Detail
+	body["code"] = problem.Code
+	body["instance"] = c.OriginalURL()
+	if requestID != "" {
+		body["request_id"] = requestID
+	}
+
+	return c.Status(problem.Status).JSON(body, ProblemContentType)
+}
+
+// From converts any error into an application error
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var duplicate *repositories.DuplicateError
+	if errors.As(err, &duplicate) {
+		detail := "A resource with the same value already exists"
+		if duplicate.Field != "" {
+			detail = "A resource with this " + duplicate.Field + " already exists"
+		}
+		return Conflict(detail).WithCode(CodeDuplicate).With("field", duplicate.Field).Wrap(err)
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return NotFound("Resource not found").Wrap(err)
+	}
+	if errors.Is(err, money.ErrOutOfRange) {
+		return New(http.StatusUnprocessableEntity, CodeAmountTooLarge, "The amount is too large to be computed").Wrap(err)
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		code, ok := statusCodes[fiberErr.Code]
+		if !ok {
+			code = CodeInternal
+		}
+		return New(fiberErr.Code, code, fiberErr.Message).Wrap(err)
+	}
+
+	return Internal("Internal server error").Wrap(err)
+}
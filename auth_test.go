@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fiber/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.LoginBackoff = time.Hour
+	})
+	s.register(t, "Carol", "carol@example.com")
+
+	status, body := s.request(t, "POST", "/api/auth/login", "", fiber.Map{"email": "carol@example.com", "password": "wrong1"})
+	expectProblem(t, status, body, fiber.StatusUnauthorized, "unauthorized")
+
+	// Even the right password waits for the backoff to end
+	status, body = s.request(t, "POST", "/api/auth/login", "", fiber.Map{"email": "carol@example.com", "password": "secret1"})
+	expectProblem(t, status, body, fiber.StatusTooManyRequests, "too_many_attempts")
+}
+
+func TestLoginLockoutAndUnlock(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.LoginMaxFailures = 3
+		cfg.LoginBackoff = time.Millisecond
+		cfg.LoginLockout = time.Hour
+	})
+	s.register(t, "Carol", "carol@example.com")
+
+	for i := 0; i < 3; i++ {
+		// Outwait the backoff, which doubles after each failure
+		time.Sleep(10 * time.Millisecond)
+		status, body := s.request(t, "POST", "/api/auth/login", "", fiber.Map{"email": "carol@example.com", "password": "wrong1"})
+		expectProblem(t, status, body, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	status, body := s.request(t, "POST", "/api/auth/login", "", fiber.Map{"email": "carol@example.com", "password": "secret1"})
+	expectProblem(t, status, body, fiber.StatusTooManyRequests, "too_many_attempts")
+	if retryAfter, _ := body["retry_after"].(float64); retryAfter < time.Minute.Seconds() {
+		t.Fatalf("retry_after = %v, want the rest of the lockout", body["retry_after"])
+	}
+
+	user, err := s.repos.Users.FindByEmail(context.Background(), "carol@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, body := s.request(t, "POST", "/api/admin/users/"+user.ID.Hex()+"/unlock", s.adminToken, nil); status != fiber.StatusOK {
+		t.Fatalf("unlock: %d %v", status, body)
+	}
+	s.login(t, "carol@example.com", "secret1")
+
+	status, body = s.request(t, "GET", "/api/admin/lockouts", s.adminToken, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("lockouts: %d %v", status, body)
+	}
+	events, _ := body["data"].([]interface{})
+	if len(events) != 2 {
+		t.Fatalf("got %d lockout events, want the lockout and the unlock: %v", len(events), events)
+	}
+	for i, want := range []string{"locked", "unlocked"} {
+		if event := events[i].(map[string]interface{}); event["type"] != want || event["user_id"] != user.ID.Hex() {
+			t.Fatalf("event %d = %v, want %s for the user", i, event, want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCategoryDuplicateName(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	category := fiber.Map{"name": "Books", "status": "ACTIVE"}
+	if status, body := s.request(t, "POST", "/api/categories/", s.adminToken, category); status != fiber.StatusCreated {
+		t.Fatalf("create: %d %v", status, body)
+	}
+
+	status, body := s.request(t, "POST", "/api/categories/", s.adminToken, category)
+	expectProblem(t, status, body, fiber.StatusConflict, "duplicate")
+	if body["field"] != "name" {
+		t.Fatalf("field = %v, want name", body["field"])
+	}
+}
+
+func TestProductDuplicateName(t *testing.T) {
+	s := newTestServer(t, nil)
+	existing := s.createProduct(t, "Pen", "2.50")
+
+	product := fiber.Map{"name": "Pen", "description": "Blue", "price": 3, "category_id": existing.CategoryID.Hex()}
+	status, body := s.request(t, "POST", "/api/products/", s.adminToken, product)
+	expectProblem(t, status, body, fiber.StatusConflict, "duplicate")
+
+	other := s.createProduct(t, "Ink", "4")
+	product["name"] = "Pen"
+	status, body = s.request(t, "PATCH", "/api/products/"+other.ID.Hex(), s.adminToken, product)
+	expectProblem(t, status, body, fiber.StatusConflict, "duplicate")
+}
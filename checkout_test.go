@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"fiber/money"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// checkout places the order of the user's cart and returns the order
+func (s *testServer) checkout(t *testing.T, token string) map[string]interface{} {
+	t.Helper()
+
+	status, body := s.request(t, "POST", "/api/cart/checkout", token, nil)
+	if status != fiber.StatusCreated {
+		t.Fatalf("checkout: %d %v", status, body)
+	}
+	return body["data"].(map[string]interface{})
+}
+
+// stockOf returns the on hand and reserved quantities of the product
+func (s *testServer) stockOf(t *testing.T, productID string) (onHand, reserved float64) {
+	t.Helper()
+
+	status, body := s.request(t, "GET", "/api/inventory/"+productID, s.adminToken, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("stock: %d %v", status, body)
+	}
+	stock := body["data"].(map[string]interface{})
+	return stock["on_hand"].(float64), stock["reserved"].(float64)
+}
+
+func TestCheckoutReservesStock(t *testing.T) {
+	s := newTestServer(t, nil)
+	pen := s.createProduct(t, "Pen", "2.50")
+	s.receiveStock(t, pen, 5)
+	token := s.register(t, "Vivian", "vivian@example.com")
+
+	s.addToCart(t, token, pen, 2)
+	order := s.checkout(t, token)
+	if total := order["total"].(map[string]interface{}); total["amount"] != "5.00" {
+		t.Fatalf("total = %v, want 5.00", total)
+	}
+	if onHand, reserved := s.stockOf(t, pen.ID.Hex()); onHand != 5 || reserved != 2 {
+		t.Fatalf("stock = %v on hand, %v reserved, want 5 and 2", onHand, reserved)
+	}
+
+	status, body := s.request(t, "POST", "/api/cart/checkout", token, nil)
+	expectProblem(t, status, body, fiber.StatusConflict, "cart_empty")
+}
+
+func TestEmptyCartTotal(t *testing.T) {
+	s := newTestServer(t, nil)
+	token := s.register(t, "Vivian", "vivian@example.com")
+
+	status, body := s.request(t, "GET", "/api/cart/", token, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("cart: %d %v", status, body)
+	}
+	// An empty cart is priced like any other, in the shop currency
+	total := body["data"].(map[string]interface{})["total"].(map[string]interface{})
+	if total["amount"] != "0.00" || total["currency"] != s.cfg.Currency {
+		t.Fatalf("total = %v, want 0.00 %s", total, s.cfg.Currency)
+	}
+}
+
+func TestCheckoutCartChanged(t *testing.T) {
+	s := newTestServer(t, nil)
+	pen := s.createProduct(t, "Pen", "2.50")
+	s.receiveStock(t, pen, 5)
+	token := s.register(t, "Vivian", "vivian@example.com")
+	s.addToCart(t, token, pen, 2)
+
+	// The price changes after the cart was last shown
+	price, err := money.Parse("3", s.cfg.Currency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pen.Price = price
+	if err := s.repos.Products.Update(context.Background(), pen); err != nil {
+		t.Fatal(err)
+	}
+
+	status, body := s.request(t, "POST", "/api/cart/checkout", token, nil)
+	expectProblem(t, status, body, fiber.StatusConflict, "cart_changed")
+
+	// Once the cart has been shown again with the new price it can be checked out
+	if status, body := s.request(t, "GET", "/api/cart/", token, nil); status != fiber.StatusOK {
+		t.Fatalf("cart: %d %v", status, body)
+	}
+	order := s.checkout(t, token)
+	if total := order["total"].(map[string]interface{}); total["amount"] != "6.00" {
+		t.Fatalf("total = %v, want 6.00", total)
+	}
+}
+
+func TestCheckoutOutOfStock(t *testing.T) {
+	s := newTestServer(t, nil)
+	pen := s.createProduct(t, "Pen", "2.50")
+	s.receiveStock(t, pen, 1)
+	token := s.register(t, "Vivian", "vivian@example.com")
+
+	s.addToCart(t, token, pen, 2)
+	status, body := s.request(t, "POST", "/api/cart/checkout", token, nil)
+	expectProblem(t, status, body, fiber.StatusConflict, "out_of_stock")
+	if body["product_id"] != pen.ID.Hex() {
+		t.Fatalf("product_id = %v, want %s", body["product_id"], pen.ID.Hex())
+	}
+
+	// Nothing was reserved and the cart is kept
+	if _, reserved := s.stockOf(t, pen.ID.Hex()); reserved != 0 {
+		t.Fatalf("reserved = %v, want 0", reserved)
+	}
+	status, body = s.request(t, "GET", "/api/cart/", token, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("cart: %d %v", status, body)
+	}
+	if items := body["data"].(map[string]interface{})["items"].([]interface{}); len(items) != 1 {
+		t.Fatalf("cart has %d items, want 1", len(items))
+	}
+}
@@ -1,11 +1,624 @@
 package config
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
-var JwtSecret = []byte("your-secret-key")
+	"fiber/money"
 
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+	EnvTest        = "test"
+
+	// DefaultJwtSecret is only accepted while running in development mode
+	DefaultJwtSecret = "your-secret-key"
+	// DefaultPaymentWebhookSecret is only accepted while running in development mode
+	DefaultPaymentWebhookSecret = "your-webhook-secret"
+
+	// What happens to the products of a category that is deleted or disabled
+	CategoryOnDeleteRestrict = "restrict"
+	CategoryOnDeleteCascade  = "cascade"
+	CategoryOnDeleteReassign = "reassign"
+
+	// Where exchange rates come from: set through the API or from the configuration
+	ExchangeRateSourceManual = "manual"
+	ExchangeRateSourceStatic = "static"
+)
+
+// Config holds every runtime setting of the application
+type Config struct {
+	Env           string        `yaml:"env"`
+	Port          string        `yaml:"port"`
+	MongoURI      string        `yaml:"mongo_uri"`
+	MongoDatabase string        `yaml:"mongo_database"`
+	JwtSecret     string        `yaml:"jwt_secret"`
+	JwtExpiry     time.Duration `yaml:"jwt_expiry"`
+	// RefreshTokenExpiry is the lifetime of refresh tokens, each rotation restarts it
+	RefreshTokenExpiry time.Duration `yaml:"refresh_token_expiry"`
+
+	DefaultPageSize int `yaml:"default_page_size"`
+	MaxPageSize     int `yaml:"max_page_size"`
+
+	// AdminEmail and AdminPassword seed the first admin when no admin exists yet
+	AdminEmail    string `yaml:"admin_email"`
+	AdminPassword string `yaml:"admin_password"`
+
+	// StorageDriver selects where uploads are kept: "local" (StorageDir) or "s3"
+	StorageDriver string `yaml:"storage_driver"`
+	StorageDir    string `yaml:"storage_dir"`
+	// S3 settings, S3Endpoint may point to any S3-compatible server such as MinIO
+	S3Endpoint  string `yaml:"s3_endpoint"`
+	S3Region    string `yaml:"s3_region"`
+	S3Bucket    string `yaml:"s3_bucket"`
+	S3AccessKey string `yaml:"s3_access_key"`
+	S3SecretKey string `yaml:"s3_secret_key"`
+	S3PathStyle bool   `yaml:"s3_path_style"`
+
+	// TrashRetention is how long soft deleted documents are kept before being purged,
+	// TrashPurgeInterval how often the purge runs
+	TrashRetention     time.Duration `yaml:"trash_retention"`
+	TrashPurgeInterval time.Duration `yaml:"trash_purge_interval"`
+
+	// CategoryOnDelete applies to the products of a category that is deleted or set
+	// INACTIVE: restrict refuses while products remain, cascade moves them to the trash
+	// and reassign moves them to the CategoryFallbackID category
+	CategoryOnDelete   string `yaml:"category_on_delete"`
+	CategoryFallbackID string `yaml:"category_fallback_id"`
+
+	// CartExpiry is how long an anonymous cart is kept after its last change
+	CartExpiry time.Duration `yaml:"cart_expiry"`
+
+	// ReservationTTL is how long checkout holds stock for an unpaid order,
+	// ReservationSweepInterval how often expired reservations are released
+	ReservationTTL           time.Duration `yaml:"reservation_ttl"`
+	ReservationSweepInterval time.Duration `yaml:"reservation_sweep_interval"`
+
+	// Currency is the ISO 4217 code of the prices and of the payments
+	Currency string `yaml:"currency"`
+	// PaymentProvider selects the payment gateway, PaymentWebhookSecret signs the
+	// notifications it sends to /webhooks/payments
+	PaymentProvider      string `yaml:"payment_provider"`
+	PaymentWebhookSecret string `yaml:"payment_webhook_secret"`
+
+	// PriceRounding is the rounding mode of converted prices and
+	// PriceRoundingIncrements the steps of some currencies, e.g. "CHF:0.05"
+	PriceRounding           string `yaml:"price_rounding"`
+	PriceRoundingIncrements string `yaml:"price_rounding_increments"`
+	// ExchangeRateSource is where rates from Currency come from: manual (set through
+	// the API) or static (ExchangeRates, e.g. "EUR:0.92,GBP:0.79", copied every
+	// ExchangeRateRefreshInterval)
+	ExchangeRateSource          string        `yaml:"exchange_rate_source"`
+	ExchangeRates               string        `yaml:"exchange_rates"`
+	ExchangeRateRefreshInterval time.Duration `yaml:"exchange_rate_refresh_interval"`
+
+	// AppURL is the public address of the application, used in the links sent by email
+	AppURL string `yaml:"app_url"`
+	// MailDriver selects how emails are sent: "smtp", "file" (.eml files in MailDir)
+	// or "memory" (kept in the process, for tests)
+	MailDriver   string `yaml:"mail_driver"`
+	MailDir      string `yaml:"mail_dir"`
+	MailFrom     string `yaml:"mail_from"`
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+
+	// EmailVerificationTTL and PasswordResetTTL are the lifetimes of the links sent by email
+	EmailVerificationTTL time.Duration `yaml:"email_verification_ttl"`
+	PasswordResetTTL     time.Duration `yaml:"password_reset_ttl"`
+	// RequireEmailVerification refuses logins to accounts whose email is not verified yet
+	RequireEmailVerification bool `yaml:"require_email_verification"`
+
+	// After LoginMaxFailures failed logins for an email, or LoginIPMaxFailures from an
+	// IP address, further attempts are locked out for LoginLockout. Failures for an
+	// email also wait LoginBackoff, doubled after each failure. Failures are forgotten
+	// after LoginFailureWindow without attempts.
+	LoginMaxFailures   int           `yaml:"login_max_failures"`
+	LoginIPMaxFailures int           `yaml:"login_ip_max_failures"`
+	LoginBackoff       time.Duration `yaml:"login_backoff"`
+	LoginLockout       time.Duration `yaml:"login_lockout"`
+	LoginFailureWindow time.Duration `yaml:"login_failure_window"`
+}
+
+// AuthClaims are the claims of an access token; the registered ID (jti) is used
+// for revocation and SessionId links the token to its refresh token family
 type AuthClaims struct {
-	Email  string `json:"email"`
-	UserId string `json:"user_id"`
+	Email     string `json:"email"`
+	UserId    string `json:"user_id"`
+	Role      string `json:"role"`
+	SessionId string `json:"sid"`
 	jwt.RegisteredClaims
 }
+
+// settings maps every environment variable to its command-line flag
+var settings = []struct {
+	env   string
+	flag  string
+	usage string
+}{
+	{"APP_ENV", "env", "application environment (development, production, test)"},
+	{"PORT", "port", "HTTP port to listen on"},
+	{"MONGO_URI", "mongo-uri", "MongoDB connection string"},
+	{"MONGO_DATABASE", "mongo-database", "MongoDB database name"},
+	{"JWT_SECRET", "jwt-secret", "secret used to sign JWT tokens"},
+	{"JWT_EXPIRY", "jwt-expiry", "lifetime of issued access tokens (e.g. 15m)"},
+	{"REFRESH_TOKEN_EXPIRY", "refresh-token-expiry", "lifetime of issued refresh tokens (e.g. 168h)"},
+	{"DEFAULT_PAGE_SIZE", "default-page-size", "number of items per page when per_page is omitted"},
+	{"MAX_PAGE_SIZE", "max-page-size", "upper bound for the per_page query parameter"},
+	{"ADMIN_EMAIL", "admin-email", "email of the admin seeded when no admin exists"},
+	{"ADMIN_PASSWORD", "admin-password", "password of the seeded admin"},
+	{"STORAGE_DRIVER", "storage-driver", "upload storage backend (local, s3)"},
+	{"STORAGE_DIR", "storage-dir", "directory of the local storage backend"},
+	{"S3_ENDPOINT", "s3-endpoint", "URL of the S3-compatible endpoint"},
+	{"S3_REGION", "s3-region", "S3 region used to sign requests"},
+	{"S3_BUCKET", "s3-bucket", "S3 bucket storing uploads"},
+	{"S3_ACCESS_KEY", "s3-access-key", "S3 access key ID"},
+	{"S3_SECRET_KEY", "s3-secret-key", "S3 secret access key"},
+	{"S3_PATH_STYLE", "s3-path-style", "address the bucket in the path instead of the host name"},
+	{"TRASH_RETENTION", "trash-retention", "how long deleted documents stay in the trash (e.g. 720h)"},
+	{"TRASH_PURGE_INTERVAL", "trash-purge-interval", "how often expired trash is purged (e.g. 1h)"},
+	{"CATEGORY_ON_DELETE", "category-on-delete", "products of deleted categories (restrict, cascade, reassign)"},
+	{"CATEGORY_FALLBACK_ID", "category-fallback-id", "category receiving the products with category_on_delete=reassign"},
+	{"CART_EXPIRY", "cart-expiry", "how long anonymous carts are kept after their last change (e.g. 720h)"},
+	{"RESERVATION_TTL", "reservation-ttl", "how long stock is held for an unpaid order (e.g. 15m)"},
+	{"RESERVATION_SWEEP_INTERVAL", "reservation-sweep-interval", "how often expired stock reservations are released (e.g. 1m)"},
+	{"CURRENCY", "currency", "ISO 4217 code of prices and payments (e.g. USD)"},
+	{"PAYMENT_PROVIDER", "payment-provider", "payment gateway (fake)"},
+	{"PAYMENT_WEBHOOK_SECRET", "payment-webhook-secret", "secret signing the payment webhooks"},
+	{"PRICE_ROUNDING", "price-rounding", "rounding of converted prices (half_up, half_even, down, up)"},
+	{"PRICE_ROUNDING_INCREMENTS", "price-rounding-increments", "rounding steps per currency (e.g. CHF:0.05)"},
+	{"EXCHANGE_RATE_SOURCE", "exchange-rate-source", "origin of exchange rates (manual, static)"},
+	{"EXCHANGE_RATES", "exchange-rates", "rates of the static source (e.g. EUR:0.92,GBP:0.79)"},
+	{"EXCHANGE_RATE_REFRESH_INTERVAL", "exchange-rate-refresh-interval", "how often rates are pulled from the source (e.g. 1h)"},
+	{"APP_URL", "app-url", "public URL of the application, used in links sent by email"},
+	{"MAIL_DRIVER", "mail-driver", "how emails are sent (smtp, file, memory)"},
+	{"MAIL_DIR", "mail-dir", "directory of the emails written by the file mail driver"},
+	{"MAIL_FROM", "mail-from", "sender of the emails (e.g. Shop <no-reply@example.com>)"},
+	{"SMTP_HOST", "smtp-host", "host of the SMTP relay"},
+	{"SMTP_PORT", "smtp-port", "port of the SMTP relay (465 for implicit TLS)"},
+	{"SMTP_USERNAME", "smtp-username", "SMTP username, leave empty to send without authentication"},
+	{"SMTP_PASSWORD", "smtp-password", "SMTP password"},
+	{"EMAIL_VERIFICATION_TTL", "email-verification-ttl", "lifetime of email verification links (e.g. 48h)"},
+	{"PASSWORD_RESET_TTL", "password-reset-ttl", "lifetime of password reset links (e.g. 1h)"},
+	{"REQUIRE_EMAIL_VERIFICATION", "require-email-verification", "refuse logins until the email address is verified"},
+	{"LOGIN_MAX_FAILURES", "login-max-failures", "failed logins for an email before it is locked out"},
+	{"LOGIN_IP_MAX_FAILURES", "login-ip-max-failures", "failed logins from an IP address before it is locked out"},
+	{"LOGIN_BACKOFF", "login-backoff", "wait after the first failed login for an email, doubled after each failure (e.g. 1s)"},
+	{"LOGIN_LOCKOUT", "login-lockout", "how long logins stay locked out (e.g. 15m)"},
+	{"LOGIN_FAILURE_WINDOW", "login-failure-window", "how long failed logins are remembered without new attempts (e.g. 1h)"},
+}
+
+func defaults() *Config {
+	return &Config{
+		Env:           EnvDevelopment,
+		Port:          "4000",
+		MongoURI:      "mongodb://localhost:27017",
+		MongoDatabase: "go_fiber",
+		JwtSecret:     DefaultJwtSecret,
+		JwtExpiry:     15 * time.Minute,
+
+		RefreshTokenExpiry: 7 * 24 * time.Hour,
+
+		DefaultPageSize: 20,
+		MaxPageSize:     100,
+
+		StorageDriver: "local",
+		StorageDir:    "./uploads",
+		S3Endpoint:    "https://s3.amazonaws.com",
+		S3Region:      "us-east-1",
+
+		TrashRetention:     30 * 24 * time.Hour,
+		TrashPurgeInterval: time.Hour,
+
+		CategoryOnDelete: CategoryOnDeleteRestrict,
+
+		CartExpiry: 30 * 24 * time.Hour,
+
+		ReservationTTL:           15 * time.Minute,
+		ReservationSweepInterval: time.Minute,
+
+		Currency:             "USD",
+		PaymentProvider:      "fake",
+		PaymentWebhookSecret: DefaultPaymentWebhookSecret,
+
+		PriceRounding:               string(money.HalfUp),
+		ExchangeRateSource:          ExchangeRateSourceManual,
+		ExchangeRateRefreshInterval: time.Hour,
+
+		AppURL:     "http://localhost:4000",
+		MailDriver: "file",
+		MailDir:    "./mail-out",
+		MailFrom:   "no-reply@localhost",
+		SMTPPort:   587,
+
+		EmailVerificationTTL: 48 * time.Hour,
+		PasswordResetTTL:     time.Hour,
+
+		LoginMaxFailures:   5,
+		LoginIPMaxFailures: 20,
+		LoginBackoff:       time.Second,
+		LoginLockout:       15 * time.Minute,
+		LoginFailureWindow: time.Hour,
+	}
+}
+
+// Load builds the configuration with the following precedence (lowest first):
+// defaults, config file (YAML or .env), environment variables, command-line flags.
+// The config file is taken from -config, then CONFIG_FILE, then ./.env if present.
+func Load(args []string) (*Config, error) {
+	cfg := defaults()
+
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a YAML or .env config file")
+	flagValues := make(map[string]*string)
+	for _, s := range settings {
+		flagValues[s.env] = fs.String(s.flag, "", s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		if _, err := os.Stat(".env"); err == nil {
+			path = ".env"
+		}
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			if err := cfg.set(s.env, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Only apply flags that were explicitly passed
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				flagErr = cfg.set(s.env, *flagValues[s.env])
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate refuses configurations that are unsafe or incomplete
+func (c *Config) Validate() error {
+	switch c.Env {
+	case EnvDevelopment, EnvProduction, EnvTest:
+	default:
+		return fmt.Errorf("invalid env %q, allowed: %s, %s, %s", c.Env, EnvDevelopment, EnvProduction, EnvTest)
+	}
+	if c.Port == "" {
+		return errors.New("port is required")
+	}
+	if c.MongoURI == "" {
+		return errors.New("mongo_uri is required")
+	}
+	if c.MongoDatabase == "" {
+		return errors.New("mongo_database is required")
+	}
+	if c.JwtSecret == "" {
+		return errors.New("jwt_secret is required")
+	}
+	if c.JwtSecret == DefaultJwtSecret && !c.IsDev() {
+		return fmt.Errorf("the default jwt_secret is not allowed in %s mode", c.Env)
+	}
+	if c.JwtExpiry <= 0 {
+		return errors.New("jwt_expiry must be positive")
+	}
+	if c.RefreshTokenExpiry <= c.JwtExpiry {
+		return errors.New("refresh_token_expiry must be longer than jwt_expiry")
+	}
+	if c.DefaultPageSize <= 0 || c.MaxPageSize < c.DefaultPageSize {
+		return errors.New("default_page_size must be positive and not exceed max_page_size")
+	}
+	if c.AdminEmail != "" && len(c.AdminPassword) < 6 {
+		return errors.New("admin_password of at least 6 characters is required with admin_email")
+	}
+	switch c.StorageDriver {
+	case "local":
+		if c.StorageDir == "" {
+			return errors.New("storage_dir is required with the local storage driver")
+		}
+	case "s3":
+		if c.S3Endpoint == "" || c.S3Region == "" || c.S3Bucket == "" || c.S3AccessKey == "" || c.S3SecretKey == "" {
+			return errors.New("s3_endpoint, s3_region, s3_bucket, s3_access_key and s3_secret_key are required with the s3 storage driver")
+		}
+	default:
+		return fmt.Errorf("invalid storage_driver %q, allowed: local, s3", c.StorageDriver)
+	}
+	if c.TrashRetention <= 0 || c.TrashPurgeInterval <= 0 {
+		return errors.New("trash_retention and trash_purge_interval must be positive")
+	}
+	if c.CartExpiry <= 0 {
+		return errors.New("cart_expiry must be positive")
+	}
+	if c.ReservationTTL <= 0 || c.ReservationSweepInterval <= 0 {
+		return errors.New("reservation_ttl and reservation_sweep_interval must be positive")
+	}
+	if len(c.Currency) != 3 || strings.ToUpper(c.Currency) != c.Currency {
+		return fmt.Errorf("invalid currency %q, expected an uppercase ISO 4217 code such as USD", c.Currency)
+	}
+	if c.PaymentProvider != "fake" {
+		return fmt.Errorf("invalid payment_provider %q, allowed: fake", c.PaymentProvider)
+	}
+	if c.PaymentWebhookSecret == "" {
+		return errors.New("payment_webhook_secret is required")
+	}
+	if c.PaymentWebhookSecret == DefaultPaymentWebhookSecret && !c.IsDev() {
+		return fmt.Errorf("the default payment_webhook_secret is not allowed in %s mode", c.Env)
+	}
+	if _, err := c.PriceRules(); err != nil {
+		return err
+	}
+	switch c.ExchangeRateSource {
+	case ExchangeRateSourceManual, ExchangeRateSourceStatic:
+	default:
+		return fmt.Errorf("invalid exchange_rate_source %q, allowed: %s, %s", c.ExchangeRateSource, ExchangeRateSourceManual, ExchangeRateSourceStatic)
+	}
+	if _, err := money.ParseRates(c.ExchangeRates); err != nil {
+		return fmt.Errorf("invalid exchange_rates: %w", err)
+	}
+	if c.ExchangeRateRefreshInterval <= 0 {
+		return errors.New("exchange_rate_refresh_interval must be positive")
+	}
+	if u, err := url.Parse(c.AppURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid app_url %q, expected an absolute http or https URL", c.AppURL)
+	}
+	if _, err := mail.ParseAddress(c.MailFrom); err != nil {
+		return fmt.Errorf("invalid mail_from %q: %w", c.MailFrom, err)
+	}
+	switch c.MailDriver {
+	case "smtp":
+		if c.SMTPHost == "" || c.SMTPPort <= 0 {
+			return errors.New("smtp_host and smtp_port are required with the smtp mail driver")
+		}
+	case "file":
+		if c.MailDir == "" {
+			return errors.New("mail_dir is required with the file mail driver")
+		}
+	case "memory":
+	default:
+		return fmt.Errorf("invalid mail_driver %q, allowed: smtp, file, memory", c.MailDriver)
+	}
+	if c.EmailVerificationTTL <= 0 || c.PasswordResetTTL <= 0 {
+		return errors.New("email_verification_ttl and password_reset_ttl must be positive")
+	}
+	if c.LoginMaxFailures <= 0 || c.LoginIPMaxFailures <= 0 {
+		return errors.New("login_max_failures and login_ip_max_failures must be positive")
+	}
+	if c.LoginBackoff <= 0 || c.LoginLockout <= 0 || c.LoginFailureWindow <= 0 {
+		return errors.New("login_backoff, login_lockout and login_failure_window must be positive")
+	}
+	switch c.CategoryOnDelete {
+	case CategoryOnDeleteRestrict, CategoryOnDeleteCascade:
+	case CategoryOnDeleteReassign:
+		if !primitive.IsValidObjectID(c.CategoryFallbackID) {
+			return errors.New("a valid category_fallback_id is required with category_on_delete=reassign")
+		}
+	default:
+		return fmt.Errorf("invalid category_on_delete %q, allowed: %s, %s, %s", c.CategoryOnDelete, CategoryOnDeleteRestrict, CategoryOnDeleteCascade, CategoryOnDeleteReassign)
+	}
+	return nil
+}
+
+func (c *Config) IsDev() bool {
+	return c.Env == EnvDevelopment
+}
+
+// PriceRules returns how converted prices are rounded
+func (c *Config) PriceRules() (money.Rules, error) {
+	rules, err := money.ParseRules(c.PriceRounding, c.PriceRoundingIncrements)
+	if err != nil {
+		return money.Rules{}, fmt.Errorf("invalid price rounding: %w", err)
+	}
+	return rules, nil
+}
+
+func (c *Config) set(key, value string) error {
+	switch key {
+	case "APP_ENV":
+		c.Env = value
+	case "PORT":
+		c.Port = value
+	case "MONGO_URI":
+		c.MongoURI = value
+	case "MONGO_DATABASE":
+		c.MongoDatabase = value
+	case "JWT_SECRET":
+		c.JwtSecret = value
+	case "ADMIN_EMAIL":
+		c.AdminEmail = value
+	case "ADMIN_PASSWORD":
+		c.AdminPassword = value
+	case "STORAGE_DRIVER":
+		c.StorageDriver = value
+	case "STORAGE_DIR":
+		c.StorageDir = value
+	case "CATEGORY_ON_DELETE":
+		c.CategoryOnDelete = value
+	case "CATEGORY_FALLBACK_ID":
+		c.CategoryFallbackID = value
+	case "CURRENCY":
+		c.Currency = value
+	case "PAYMENT_PROVIDER":
+		c.PaymentProvider = value
+	case "PAYMENT_WEBHOOK_SECRET":
+		c.PaymentWebhookSecret = value
+	case "PRICE_ROUNDING":
+		c.PriceRounding = value
+	case "PRICE_ROUNDING_INCREMENTS":
+		c.PriceRoundingIncrements = value
+	case "EXCHANGE_RATE_SOURCE":
+		c.ExchangeRateSource = value
+	case "EXCHANGE_RATES":
+		c.ExchangeRates = value
+	case "S3_ENDPOINT":
+		c.S3Endpoint = value
+	case "S3_REGION":
+		c.S3Region = value
+	case "S3_BUCKET":
+		c.S3Bucket = value
+	case "S3_ACCESS_KEY":
+		c.S3AccessKey = value
+	case "S3_SECRET_KEY":
+		c.S3SecretKey = value
+	case "APP_URL":
+		c.AppURL = value
+	case "MAIL_DRIVER":
+		c.MailDriver = value
+	case "MAIL_DIR":
+		c.MailDir = value
+	case "MAIL_FROM":
+		c.MailFrom = value
+	case "SMTP_HOST":
+		c.SMTPHost = value
+	case "SMTP_USERNAME":
+		c.SMTPUsername = value
+	case "SMTP_PASSWORD":
+		c.SMTPPassword = value
+	case "S3_PATH_STYLE", "REQUIRE_EMAIL_VERIFICATION":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		if key == "S3_PATH_STYLE" {
+			c.S3PathStyle = b
+		} else {
+			c.RequireEmailVerification = b
+		}
+	case "JWT_EXPIRY", "REFRESH_TOKEN_EXPIRY", "TRASH_RETENTION", "TRASH_PURGE_INTERVAL", "CART_EXPIRY",
+		"RESERVATION_TTL", "RESERVATION_SWEEP_INTERVAL", "EXCHANGE_RATE_REFRESH_INTERVAL", "EMAIL_VERIFICATION_TTL", "PASSWORD_RESET_TTL",
+		"LOGIN_BACKOFF", "LOGIN_LOCKOUT", "LOGIN_FAILURE_WINDOW":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		switch key {
+		case "JWT_EXPIRY":
+			c.JwtExpiry = d
+		case "REFRESH_TOKEN_EXPIRY":
+			c.RefreshTokenExpiry = d
+		case "TRASH_RETENTION":
+			c.TrashRetention = d
+		case "CART_EXPIRY":
+			c.CartExpiry = d
+		case "RESERVATION_TTL":
+			c.ReservationTTL = d
+		case "RESERVATION_SWEEP_INTERVAL":
+			c.ReservationSweepInterval = d
+		case "EXCHANGE_RATE_REFRESH_INTERVAL":
+			c.ExchangeRateRefreshInterval = d
+		case "EMAIL_VERIFICATION_TTL":
+			c.EmailVerificationTTL = d
+		case "PASSWORD_RESET_TTL":
+			c.PasswordResetTTL = d
+		case "LOGIN_BACKOFF":
+			c.LoginBackoff = d
+		case "LOGIN_LOCKOUT":
+			c.LoginLockout = d
+		case "LOGIN_FAILURE_WINDOW":
+			c.LoginFailureWindow = d
+		default:
+			c.TrashPurgeInterval = d
+		}
+	case "DEFAULT_PAGE_SIZE", "MAX_PAGE_SIZE", "SMTP_PORT", "LOGIN_MAX_FAILURES", "LOGIN_IP_MAX_FAILURES":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		switch key {
+		case "DEFAULT_PAGE_SIZE":
+			c.DefaultPageSize = n
+		case "MAX_PAGE_SIZE":
+			c.MaxPageSize = n
+		case "LOGIN_MAX_FAILURES":
+			c.LoginMaxFailures = n
+		case "LOGIN_IP_MAX_FAILURES":
+			c.LoginIPMaxFailures = n
+		default:
+			c.SMTPPort = n
+		}
+	default:
+		return fmt.Errorf("unknown setting %s", key)
+	}
+	return nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, c); err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		return nil
+	default:
+		return c.loadDotEnv(path, string(data))
+	}
+}
+
+// loadDotEnv reads KEY=VALUE lines, ignoring blanks, comments and unknown keys
+func (c *Config) loadDotEnv(path, data string) error {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		key, value, found := strings.Cut(text, "=")
+		if !found {
+			return fmt.Errorf("invalid config file %s: line %d is not KEY=VALUE", path, line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		for _, s := range settings {
+			if s.env == key {
+				if err := c.set(key, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return scanner.Err()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -11,10 +12,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var DB *mongo.Database
-
-func ConnectDB() {
-	clientOptions := options.Client().ApplyURI("mongodb://localhost:27017")
+// ConnectDB connects to MongoDB, ensures the indexes exist and returns the database
+func ConnectDB(cfg *Config) *mongo.Database {
+	clientOptions := options.Client().ApplyURI(cfg.MongoURI)
 
 	client, err := mongo.Connect(context.TODO(), clientOptions)
 	if err != nil {
@@ -31,37 +31,109 @@ func ConnectDB() {
 
 	fmt.Println("✅ Connected to MongoDB!")
 
-	DB = client.Database("go_fiber")
+	db := client.Database(cfg.MongoDatabase)
 
-	// Define which collections and fields require unique indexes
+	// Define which collections and fields require unique indexes; comma separated
+	// fields share a compound index. Values of soft deletable documents are unique
+	// together with deleted_at: live documents all lack it and collide, while each
+	// deleted one has its own date and keeps no live document from reusing its
+	// value. A partial index cannot be used as it does not support $exists: false.
 	uniqueFields := map[string][]string{
-		"users":      {"email"},
-		"products":   {"name"},
-		"categories": {"category_name"},
-		"customers":  {"email"},
-		"orders":     {"order_number"},
+		"users":           {"email,deleted_at"},
+		"products":        {"name,deleted_at"},
+		"categories":      {"name,deleted_at"},
+		"customers":       {"email"},
+		"orders":          {"order_number"},
+		"sessions":        {"token_hash"},
+		"revoked_tokens":  {"jti"},
+		"stock_levels":    {"product_id,sku,warehouse"},
+		"payments":        {"provider,intent_id"},
+		"exchange_rates":  {"base,currency"},
+		"login_throttles": {"scope,key"},
 		// Add more collections and fields as needed
 	}
 
 	// Automatically create unique indexes based on the map
-	err = createUniqueIndexesForCollections(uniqueFields)
+	err = createUniqueIndexesForCollections(db, uniqueFields, false)
+	if err != nil {
+		log.Fatal("Error creating unique index:", err)
+	}
+
+	// Define unique indexes that only cover the documents having their first field,
+	// so products without variants do not collide on a missing SKU
+	partialUniqueFields := map[string][]string{
+		"products": {"variants.sku,deleted_at"},
+	}
+
+	err = createUniqueIndexesForCollections(db, partialUniqueFields, true)
 	if err != nil {
 		log.Fatal("Error creating unique index:", err)
 	}
+
+	// Define which collections are searchable and the weight of each field
+	textFields := map[string]bson.D{
+		"products": {{"name", 3}, {"description", 1}},
+	}
+
+	err = createTextIndexesForCollections(db, textFields)
+	if err != nil {
+		log.Fatal("Error creating text index:", err)
+	}
+
+	// Define which fields are queried often enough to need a plain index
+	indexedFields := map[string][]string{
+		"products":        {"category_id", "deleted_at"},
+		"categories":      {"deleted_at", "parent_id", "ancestors"},
+		"users":           {"deleted_at"},
+		"orders":          {"customer_id", "status", "created_at"},
+		"carts":           {"user_id", "token_hash"},
+		"stock_movements": {"product_id"},
+		"reservations":    {"order_id", "expires_at"},
+		"payments":        {"order_id"},
+		"user_tokens":     {"user_id"},
+	}
+
+	err = createIndexesForCollections(db, indexedFields)
+	if err != nil {
+		log.Fatal("Error creating index:", err)
+	}
+
+	// Define which collections expire documents once the date in the field has passed
+	ttlFields := map[string]string{
+		"sessions":        "expires_at",
+		"revoked_tokens":  "expires_at",
+		"carts":           "expires_at",
+		"user_tokens":     "expires_at",
+		"login_throttles": "expires_at",
+	}
+
+	err = createTTLIndexesForCollections(db, ttlFields)
+	if err != nil {
+		log.Fatal("Error creating TTL index:", err)
+	}
+
+	return db
 }
 
-func createUniqueIndexesForCollections(uniqueFields map[string][]string) error {
+func createUniqueIndexesForCollections(db *mongo.Database, uniqueFields map[string][]string, partial bool) error {
 	// Loop through the map to get collection names and unique fields
 	for collectionName, fields := range uniqueFields {
 		// Get the collection reference
-		collection := DB.Collection(collectionName)
+		collection := db.Collection(collectionName)
 
 		for _, field := range fields {
-			// Create a unique index for each field
+			// Create a unique index for each field or group of fields
+			keys := bson.D{}
+			for _, key := range strings.Split(field, ",") {
+				keys = append(keys, bson.E{Key: key, Value: 1})
+			}
 			indexModel := mongo.IndexModel{
-				Keys:    bson.M{field: 1}, // Create index on the field
+				Keys:    keys,
 				Options: options.Index().SetUnique(true),
 			}
+			if partial {
+				indexModel.Options.SetPartialFilterExpression(bson.M{keys[0].Key: bson.M{"$exists": true}})
+			}
 
 			// Apply the index to the collection
 			_, err := collection.Indexes().CreateOne(context.TODO(), indexModel)
@@ -74,3 +146,66 @@ func createUniqueIndexesForCollections(uniqueFields map[string][]string) error {
 	}
 	return nil
 }
+
+func createIndexesForCollections(db *mongo.Database, indexedFields map[string][]string) error {
+	for collectionName, fields := range indexedFields {
+		collection := db.Collection(collectionName)
+
+		for _, field := range fields {
+			indexModel := mongo.IndexModel{
+				Keys: bson.M{field: 1},
+			}
+
+			_, err := collection.Indexes().CreateOne(context.TODO(), indexModel)
+			if err != nil {
+				log.Printf("Could not create index for collection %s and field %s: %v", collectionName, field, err)
+				continue
+			}
+			log.Printf("✅ Index on %s field created for collection %s", field, collectionName)
+		}
+	}
+	return nil
+}
+
+func createTextIndexesForCollections(db *mongo.Database, textFields map[string]bson.D) error {
+	for collectionName, weights := range textFields {
+		collection := db.Collection(collectionName)
+
+		// A collection can only have one text index, so all fields go into the same one
+		keys := bson.D{}
+		for _, field := range weights {
+			keys = append(keys, bson.E{Key: field.Key, Value: "text"})
+		}
+		indexModel := mongo.IndexModel{
+			Keys:    keys,
+			Options: options.Index().SetWeights(weights).SetName(collectionName + "_text"),
+		}
+
+		_, err := collection.Indexes().CreateOne(context.TODO(), indexModel)
+		if err != nil {
+			log.Printf("Could not create text index for collection %s: %v", collectionName, err)
+			continue
+		}
+		log.Printf("✅ Text index created for collection %s", collectionName)
+	}
+	return nil
+}
+
+func createTTLIndexesForCollections(db *mongo.Database, ttlFields map[string]string) error {
+	for collectionName, field := range ttlFields {
+		collection := db.Collection(collectionName)
+
+		indexModel := mongo.IndexModel{
+			Keys:    bson.M{field: 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}
+
+		_, err := collection.Indexes().CreateOne(context.TODO(), indexModel)
+		if err != nil {
+			log.Printf("Could not create TTL index for collection %s and field %s: %v", collectionName, field, err)
+			continue
+		}
+		log.Printf("✅ TTL index on %s field created for collection %s", field, collectionName)
+	}
+	return nil
+}
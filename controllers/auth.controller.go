@@ -3,19 +3,31 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fiber/apperrors"
 	"fiber/config"
+	"fiber/dto"
+	"fiber/mail"
+	"fiber/middlewares"
 	"fiber/models"
+	"fiber/repositories"
+	"fiber/storage"
 	"fiber/utils"
 	"fmt"
 	"log"
-	"mime/multipart"
-	"net/http"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/CloudyKit/jet/v6"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -23,17 +35,20 @@ import (
 // Initialize Jet template engine
 var views = jet.NewSet(jet.NewOSFileSystemLoader("./views"), jet.InDevelopmentMode())
 
-func createToken(email string, userId primitive.ObjectID) (string, error) {
+func createToken(cfg *config.Config, user *models.User, sessionId string, jti string, expiresAt time.Time) (string, error) {
 	// Create JWT token
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, config.AuthClaims{
-		Email:  email,
-		UserId: userId.Hex(),
+		Email:     user.Email,
+		UserId:    user.ID.Hex(),
+		Role:      user.Role,
+		SessionId: sessionId,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 	})
 
-	tokenString, err := token.SignedString(config.JwtSecret)
+	tokenString, err := token.SignedString([]byte(cfg.JwtSecret))
 	if err != nil {
 		fmt.Printf("Error generating token string: %v", err)
 		return "", err
@@ -41,108 +56,727 @@ func createToken(email string, userId primitive.ObjectID) (string, error) {
 	return tokenString, nil
 }
 
-func Login(c *fiber.Ctx) error {
+// randomToken returns n random bytes encoded for use in URLs and headers
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how refresh tokens are stored, so a database leak does not leak usable tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// currentUserID returns the ID of the authenticated user set by AuthMiddleware
+func currentUserID(c *fiber.Ctx) (primitive.ObjectID, error) {
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return primitive.NilObjectID, apperrors.Unauthorized("User not authenticated")
+	}
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, apperrors.BadRequest("Invalid user ID")
+	}
+	return id, nil
+}
+
+// tokenPair is returned by login and refresh
+type tokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// AuthController handles registration, login and the token lifecycle
+type AuthController struct {
+	cfg           *config.Config
+	users         repositories.UserRepository
+	sessions      repositories.SessionRepository
+	revokedTokens repositories.RevokedTokenRepository
+	carts         repositories.CartRepository
+	userTokens    repositories.UserTokenRepository
+	throttles     repositories.LoginThrottleRepository
+	lockouts      repositories.LockoutEventRepository
+	mailer        mail.Mailer
+	store         storage.Storage
+	transactor    repositories.Transactor
+}
+
+func NewAuthController(cfg *config.Config, users repositories.UserRepository, sessions repositories.SessionRepository, revokedTokens repositories.RevokedTokenRepository, carts repositories.CartRepository, userTokens repositories.UserTokenRepository, throttles repositories.LoginThrottleRepository, lockouts repositories.LockoutEventRepository, mailer mail.Mailer, store storage.Storage, transactor repositories.Transactor) *AuthController {
+	return &AuthController{cfg: cfg, users: users, sessions: sessions, revokedTokens: revokedTokens, carts: carts, userTokens: userTokens, throttles: throttles, lockouts: lockouts, mailer: mailer, store: store, transactor: transactor}
+}
+
+// issueTokens signs an access token and stores a new refresh token session in the family
+func (h *AuthController) issueTokens(ctx context.Context, user *models.User, sessionID, familyID primitive.ObjectID) (*tokenPair, error) {
+	now := time.Now()
+	accessExpiresAt := now.Add(h.cfg.JwtExpiry)
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	token, err := createToken(h.cfg, user, familyID.Hex(), jti, accessExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	session := models.Session{
+		ID:              sessionID,
+		FamilyID:        familyID,
+		UserID:          user.ID,
+		TokenHash:       hashToken(refreshToken),
+		AccessJTI:       jti,
+		AccessExpiresAt: primitive.NewDateTimeFromTime(accessExpiresAt),
+		CreatedAt:       primitive.NewDateTimeFromTime(now),
+		ExpiresAt:       primitive.NewDateTimeFromTime(now.Add(h.cfg.RefreshTokenExpiry)),
+	}
+	if err := h.sessions.Create(ctx, &session); err != nil {
+		return nil, err
+	}
+
+	return &tokenPair{
+		Token:        token,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(h.cfg.JwtExpiry.Seconds()),
+	}, nil
+}
+
+// revokeFamily revokes every refresh token of a family and the access tokens issued with them
+func (h *AuthController) revokeFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	sessions, err := h.sessions.RevokeFamily(ctx, familyID)
+	if err != nil {
+		return err
+	}
+	return denyAccessTokens(ctx, h.revokedTokens, sessions)
+}
+
+// denyAccessTokens denylists the access tokens of revoked sessions that have not expired yet
+func denyAccessTokens(ctx context.Context, revokedTokens repositories.RevokedTokenRepository, sessions []models.Session) error {
+	now := time.Now()
+	for _, session := range sessions {
+		expiresAt := session.AccessExpiresAt.Time()
+		if session.AccessJTI == "" || !expiresAt.After(now) {
+			continue
+		}
+		if err := revokedTokens.Revoke(ctx, session.AccessJTI, expiresAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *AuthController) Login(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	usersCollection := config.DB.Collection("users")
+	credentials, err := middlewares.Body[dto.UserLoginDTO](c)
+	if err != nil {
+		return err
+	}
 
-	var credentials struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+	now := time.Now()
+	attempt, err := h.startAttempt(ctx, c, credentials.Email, now)
+	if err != nil {
+		return err
 	}
 
-	if err := c.BodyParser(&credentials); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	user, err := h.users.FindByEmail(ctx, credentials.Email)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.Internal("Failed to fetch user").Wrap(err)
 	}
 
-	// // First, try parsing JSON
-	// if err := c.BodyParser(&credentials); err != nil || credentials.Email == "" || credentials.Password == "" {
-	// 	// If parsing fails, try getting form values (for form-data submissions)
-	// 	credentials.Email = c.FormValue("email")
-	// 	credentials.Password = c.FormValue("password")
+	// Unknown emails are checked against a dummy hash, so they take as long to answer
+	// as wrong passwords and get the same answer
+	hash := dummyPasswordHash
+	if user != nil {
+		hash = []byte(user.Password)
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(credentials.Password)); err != nil || user == nil {
+		h.failAttempt(ctx, attempt, user, now)
+		return apperrors.Unauthorized("Invalid email or password")
+	}
+	h.succeedAttempt(ctx, attempt)
 
-	// 	// If form values are also empty, return an error
-	// 	if credentials.Email == "" || credentials.Password == "" {
-	// 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Email and password are required"})
-	// 	}
-	// }
+	if h.cfg.RequireEmailVerification && user.Status == models.UserPending {
+		return apperrors.Forbidden("Email address not verified").WithCode("email_not_verified")
+	}
 
-	var user models.User
-	err := usersCollection.FindOne(ctx, bson.M{"email": credentials.Email}).Decode(&user)
+	// Every login starts a new refresh token family
+	tokens, err := h.issueTokens(ctx, user, primitive.NewObjectID(), primitive.NewObjectID())
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		return apperrors.Internal("Failed to generate token").Wrap(err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password)); err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
+	// The cart filled before logging in joins the user's cart; the login succeeds either way
+	if cartToken := c.Get(CartTokenHeader); cartToken != "" {
+		if err := mergeGuestCart(ctx, h.transactor, h.carts, cartToken, user.ID); err != nil {
+			log.Printf("Failed to merge cart into the cart of user %s: %v", user.ID.Hex(), err)
+		}
 	}
 
-	token, err := createToken(user.Email, user.ID)
+	return c.Status(fiber.StatusOK).JSON(tokens)
+}
+
+// dummyPasswordHash stands in for the password of unknown emails
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+// loginAttempt holds the failed login counters of the IP address and of the email of an attempt
+type loginAttempt struct {
+	ip      *models.LoginThrottle
+	account *models.LoginThrottle
+}
+
+// startAttempt counts the attempt for the IP address and the email before the password
+// is checked. It refuses attempts during a backoff or a lockout, and the attempts that
+// raced past a limit before it was enforced.
+func (h *AuthController) startAttempt(ctx context.Context, c *fiber.Ctx, email string, now time.Time) (*loginAttempt, error) {
+	ip, err := h.throttles.Attempt(ctx, models.ThrottleIP, c.IP(), now, h.cfg.LoginFailureWindow)
+	if errors.Is(err, repositories.ErrThrottled) {
+		return nil, tooManyAttempts(c, ip, now)
+	}
+	if err != nil {
+		return nil, apperrors.Internal("Failed to check login attempts").Wrap(err)
+	}
+
+	account, err := h.throttles.Attempt(ctx, models.ThrottleAccount, loginKey(email), now, h.cfg.LoginFailureWindow)
+	if errors.Is(err, repositories.ErrThrottled) {
+		// No password was checked, so the IP address is not charged for it
+		if err := h.throttles.Release(ctx, models.ThrottleIP, ip.Key); err != nil {
+			log.Printf("Failed to release login attempt of %s: %v", ip.Key, err)
+		}
+		return nil, tooManyAttempts(c, account, now)
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+		return nil, apperrors.Internal("Failed to check login attempts").Wrap(err)
+	}
+
+	attempt := &loginAttempt{ip: ip, account: account}
+	if account.Failures > h.cfg.LoginMaxFailures || ip.Failures > h.cfg.LoginIPMaxFailures {
+		h.failAttempt(ctx, attempt, nil, now)
+		if account.Failures > h.cfg.LoginMaxFailures {
+			return nil, tooManyAttempts(c, account, now)
+		}
+		return nil, tooManyAttempts(c, ip, now)
+	}
+	return attempt, nil
+}
+
+// failAttempt keeps the attempt as a failure: the email waits for a backoff doubling
+// with each failure, and the email or the IP address reaching its limit is locked out.
+// Per IP address failures only count towards the lockout, so users sharing an address
+// are not slowed down by each other's typos.
+func (h *AuthController) failAttempt(ctx context.Context, attempt *loginAttempt, user *models.User, now time.Time) {
+	lockedUntil := now.Add(h.cfg.LoginLockout)
+
+	var retryAt, accountLockedUntil time.Time
+	if attempt.account.Failures >= h.cfg.LoginMaxFailures {
+		retryAt, accountLockedUntil = lockedUntil, lockedUntil
+	} else {
+		retryAt = now.Add(h.loginBackoff(attempt.account.Failures))
+	}
+	if err := h.throttles.Block(ctx, models.ThrottleAccount, attempt.account.Key, retryAt, accountLockedUntil); err != nil {
+		log.Printf("Failed to block logins of %s: %v", attempt.account.Key, err)
+	}
+	// Only the failure reaching the limit is audited, not the concurrent ones over it
+	if attempt.account.Failures == h.cfg.LoginMaxFailures {
+		event := models.LockoutEvent{Scope: models.ThrottleAccount, Key: attempt.account.Key, IP: attempt.ip.Key, Failures: attempt.account.Failures}
+		if user != nil {
+			event.UserID = &user.ID
+		}
+		h.lockedOut(ctx, event, lockedUntil)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"token": token})
+	if attempt.ip.Failures >= h.cfg.LoginIPMaxFailures {
+		if err := h.throttles.Block(ctx, models.ThrottleIP, attempt.ip.Key, lockedUntil, lockedUntil); err != nil {
+			log.Printf("Failed to block logins from %s: %v", attempt.ip.Key, err)
+		}
+		if attempt.ip.Failures == h.cfg.LoginIPMaxFailures {
+			h.lockedOut(ctx, models.LockoutEvent{Scope: models.ThrottleIP, Key: attempt.ip.Key, IP: attempt.ip.Key, Failures: attempt.ip.Failures}, lockedUntil)
+		}
+	}
 }
 
-func Register(c *fiber.Ctx) error {
+// succeedAttempt forgets the failures of the email and takes back the attempt of the IP
+// address, whose failures stay until they expire
+func (h *AuthController) succeedAttempt(ctx context.Context, attempt *loginAttempt) {
+	if _, err := h.throttles.Reset(ctx, models.ThrottleAccount, attempt.account.Key); err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		log.Printf("Failed to reset failed logins of %s: %v", attempt.account.Key, err)
+	}
+	if err := h.throttles.Release(ctx, models.ThrottleIP, attempt.ip.Key); err != nil {
+		log.Printf("Failed to release login attempt of %s: %v", attempt.ip.Key, err)
+	}
+}
+
+// lockedOut audits the start of a lockout
+func (h *AuthController) lockedOut(ctx context.Context, event models.LockoutEvent, lockedUntil time.Time) {
+	log.Printf("Logins of %s %s locked out until %s after %d failures", event.Scope, event.Key, lockedUntil.Format(time.RFC3339), event.Failures)
+
+	until := primitive.NewDateTimeFromTime(lockedUntil)
+	event.Type = models.LockoutLocked
+	event.LockedUntil = &until
+	auditLockout(ctx, h.lockouts, event)
+}
+
+// loginBackoff is the wait after the nth failure for an email, at most a lockout
+func (h *AuthController) loginBackoff(failures int) time.Duration {
+	backoff := h.cfg.LoginBackoff
+	for i := 1; i < failures && backoff < h.cfg.LoginLockout; i++ {
+		backoff *= 2
+	}
+	if backoff > h.cfg.LoginLockout {
+		return h.cfg.LoginLockout
+	}
+	return backoff
+}
+
+// tooManyAttempts refuses a login until the counter allows attempts again
+func tooManyAttempts(c *fiber.Ctx, throttle *models.LoginThrottle, now time.Time) error {
+	until, blocked := throttle.BlockedUntil(now)
+	retryAfter := int64(1)
+	if blocked {
+		retryAfter = int64(math.Ceil(until.Sub(now).Seconds()))
+	}
+	c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+	return apperrors.New(fiber.StatusTooManyRequests, "too_many_attempts", "Too many failed login attempts, try again later").With("retry_after", retryAfter)
+}
 
+// auditLockout records a lockout event, the request succeeds even if it cannot be recorded
+func auditLockout(ctx context.Context, lockouts repositories.LockoutEventRepository, event models.LockoutEvent) {
+	if err := lockouts.Create(ctx, &event); err != nil {
+		log.Printf("Failed to audit %s event of %s %s: %v", event.Type, event.Scope, event.Key, err)
+	}
+}
+
+// loginKey is the key of the failed login counter of an email
+func loginKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (h *AuthController) Refresh(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Allowed file types & max size (5MB)
-	acceptedTypes := []string{"jpg", "jpeg", "png"}
-	maxSize := int64(5 * 1024 * 1024) // 5MB
+	body, err := middlewares.Body[dto.RefreshTokenDTO](c)
+	if err != nil {
+		return err
+	}
+
+	session, err := h.sessions.FindByTokenHash(ctx, hashToken(body.RefreshToken))
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.Unauthorized("Invalid refresh token")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to refresh token").Wrap(err)
+	}
 
-	var image *multipart.FileHeader
+	if session.Revoked {
+		return apperrors.Unauthorized("Refresh token has been revoked")
+	}
+	if !session.ReplacedBy.IsZero() {
+		return h.rejectReuse(c, ctx, session)
+	}
+	if session.ExpiresAt.Time().Before(time.Now()) {
+		return apperrors.Unauthorized("Refresh token expired")
+	}
 
-	// Check if a file was uploaded
-	if file, err := c.FormFile("image"); err == nil {
-		image = file
-		// Validate file only if it exists
-		err := utils.ValidateFile(file, acceptedTypes, maxSize)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-		}
+	user, err := h.users.FindByID(ctx, session.UserID)
+	if err != nil {
+		return apperrors.Unauthorized("Invalid refresh token")
+	}
 
-		// Store file (or move to a folder)
-		log.Printf("Uploaded file: %s", file.Filename)
+	// Rotate: the old token is marked as replaced before the new one exists, so if two
+	// requests race with the same token only one succeeds and the other is treated as reuse
+	newSessionID := primitive.NewObjectID()
+	err = h.sessions.MarkRotated(ctx, session.ID, newSessionID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return h.rejectReuse(c, ctx, session)
 	}
+	if err != nil {
+		return apperrors.Internal("Failed to refresh token").Wrap(err)
+	}
+
+	tokens, err := h.issueTokens(ctx, user, newSessionID, session.FamilyID)
+	if err != nil {
+		return apperrors.Internal("Failed to generate token").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(tokens)
+}
 
-	usersCollection := config.DB.Collection("users")
+// rejectReuse handles a refresh token presented after it was rotated: it may have
+// been stolen, so the whole family is revoked
+func (h *AuthController) rejectReuse(c *fiber.Ctx, ctx context.Context, session *models.Session) error {
+	log.Printf("Refresh token reuse detected for user %s, revoking session family %s", session.UserID.Hex(), session.FamilyID.Hex())
 
-	var user models.User
-	if err := c.BodyParser(&user); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	if err := h.revokeFamily(ctx, session.FamilyID); err != nil {
+		return apperrors.Internal("Failed to revoke session")
 	}
+	return apperrors.Unauthorized("Refresh token reuse detected, session revoked")
+}
 
-	user.ID = primitive.NewObjectID()
-	user.Status = "active"
+func (h *AuthController) Logout(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// If file exists, store the filename
-	if image != nil {
-		user.Image = image.Filename
+	claims, ok := c.Locals("claims").(*config.AuthClaims)
+	if !ok {
+		return apperrors.Unauthorized("User not authenticated")
+	}
+
+	// Deny the current access token right away, even if the session lookup fails
+	if err := h.revokedTokens.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return apperrors.Internal("Failed to logout")
 	}
 
+	familyID, err := primitive.ObjectIDFromHex(claims.SessionId)
+	if err != nil {
+		return apperrors.BadRequest("Invalid session ID")
+	}
+	if err := h.revokeFamily(ctx, familyID); err != nil {
+		return apperrors.Internal("Failed to logout")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Logged out successfully"})
+}
+
+func (h *AuthController) Register(c *fiber.Ctx) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body, err := middlewares.Body[dto.UserRegisterDTO](c)
+	if err != nil {
+		return err
+	}
+
+	// Decode the uploaded image only if it exists
+	image, err := readImage(body.Image)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	user := body.ToModel()
+	user.ID = primitive.NewObjectID()
+	// The account is activated by the link of the verification email
+	user.Status = models.UserPending
+	// Roles are only granted by admins
+	user.Role = models.RoleViewer
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Could not hash password" + err.Error()})
+		return apperrors.Internal("Could not hash password").Wrap(err)
 	}
 	user.Password = string(hashedPassword)
 
-	_, err = usersCollection.InsertOne(ctx, user)
+	// If file exists, store it and keep its key
+	if image != nil {
+		user.Image, user.Images, err = storeImage(ctx, h.store, "users", image)
+		if err != nil {
+			return apperrors.Internal("Failed to store image").Wrap(err)
+		}
+	}
+
+	err = h.users.Create(ctx, &user)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		if user.Image != "" {
+			utils.DeleteImage(ctx, h.store, user.Image)
+		}
+		// A duplicate email becomes a 409 naming the field, other errors are not shown
+		return err
 	}
 
+	h.inBackground("send verification email", func(ctx context.Context) error {
+		return h.sendVerification(ctx, &user)
+	})
+
 	return c.Status(201).JSON(user)
 }
 
-func LoginView(c *fiber.Ctx) error {
-	// Load the login template
-	tmpl, err := views.GetTemplate("login.jet")
+func (h *AuthController) VerifyEmail(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body, err := middlewares.Body[dto.VerifyEmailDTO](c)
+	if err != nil {
+		return err
+	}
+
+	token, err := h.useToken(ctx, body.Token, models.TokenEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	err = h.users.VerifyEmail(ctx, token.UserID, token.Email, time.Now())
+	if errors.Is(err, repositories.ErrNotFound) {
+		// The user changed their email or was deleted since the link was sent
+		return invalidTokenError()
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to verify email").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Email verified successfully"})
+}
+
+// ResendVerification mails a new verification link to a pending account. The answer is
+// the same whether the account exists or not, so it cannot be used to find accounts.
+func (h *AuthController) ResendVerification(c *fiber.Ctx) error {
+	body, err := middlewares.Body[dto.ResendVerificationDTO](c)
+	if err != nil {
+		return err
+	}
+
+	h.inBackground("resend verification email", func(ctx context.Context) error {
+		user, err := h.users.FindByEmail(ctx, body.Email)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if user.Status != models.UserPending {
+			return nil
+		}
+		return h.sendVerification(ctx, user)
+	})
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "If the account is waiting for verification, a new link has been sent"})
+}
+
+// ForgotPassword mails a password reset link. The answer is the same whether the
+// account exists or not, so it cannot be used to find accounts.
+func (h *AuthController) ForgotPassword(c *fiber.Ctx) error {
+	body, err := middlewares.Body[dto.ForgotPasswordDTO](c)
+	if err != nil {
+		return err
+	}
+
+	h.inBackground("send password reset email", func(ctx context.Context) error {
+		user, err := h.users.FindByEmail(ctx, body.Email)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return h.sendPasswordReset(ctx, user)
+	})
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "If an account uses this email, a password reset link has been sent"})
+}
+
+func (h *AuthController) ResetPassword(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body, err := middlewares.Body[dto.ResetPasswordDTO](c)
+	if err != nil {
+		return err
+	}
+
+	token, err := h.useToken(ctx, body.Token, models.TokenPasswordReset)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.users.FindByID(ctx, token.UserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return invalidTokenError()
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch user").Wrap(err)
+	}
+	// The link was sent to an address the user no longer has
+	if user.Email != token.Email {
+		return invalidTokenError()
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return apperrors.Internal("Could not hash password").Wrap(err)
+	}
+	if err := h.users.UpdatePassword(ctx, user.ID, string(hashedPassword)); err != nil {
+		return apperrors.Internal("Failed to reset password").Wrap(err)
+	}
+
+	now := time.Now()
+	// Following the link proves the user reads the mailbox, like a verification link
+	if user.Status == models.UserPending {
+		if err := h.users.VerifyEmail(ctx, user.ID, user.Email, now); err != nil {
+			log.Printf("Failed to verify the email of user %s: %v", user.ID.Hex(), err)
+		}
+	}
+	if err := h.userTokens.Invalidate(ctx, user.ID, models.TokenPasswordReset, now); err != nil {
+		return apperrors.Internal("Failed to reset password").Wrap(err)
+	}
+
+	// Whoever knew the old password is logged out
+	sessions, err := h.sessions.RevokeUser(ctx, user.ID)
+	if err == nil {
+		err = denyAccessTokens(ctx, h.revokedTokens, sessions)
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to revoke sessions").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Password reset successfully, please log in again"})
+}
+
+// sendVerification mails a link verifying the email of the user, older links stop working
+func (h *AuthController) sendVerification(ctx context.Context, user *models.User) error {
+	token, err := h.issueToken(ctx, user, models.TokenEmailVerification, h.cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	return h.sendEmail(ctx, user, "Verify your email", "verify_email", jet.VarMap{}.
+		Set("link", h.link("/auth/verify-email", token)).
+		Set("expiresIn", formatDuration(h.cfg.EmailVerificationTTL)))
+}
+
+// sendPasswordReset mails a link to choose a new password, older links stop working
+func (h *AuthController) sendPasswordReset(ctx context.Context, user *models.User) error {
+	token, err := h.issueToken(ctx, user, models.TokenPasswordReset, h.cfg.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+	return h.sendEmail(ctx, user, "Reset your password", "reset_password", jet.VarMap{}.
+		Set("link", h.link("/auth/reset-password", token)).
+		Set("expiresIn", formatDuration(h.cfg.PasswordResetTTL)))
+}
+
+// issueToken records a single-use token for the user and returns it signed
+func (h *AuthController) issueToken(ctx context.Context, user *models.User, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if err := h.userTokens.Invalidate(ctx, user.ID, purpose, now); err != nil {
+		return "", err
+	}
+
+	token := models.UserToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		CreatedAt: primitive.NewDateTimeFromTime(now),
+		ExpiresAt: primitive.NewDateTimeFromTime(now.Add(ttl)),
+	}
+	if err := h.userTokens.Create(ctx, &token); err != nil {
+		return "", err
+	}
+	return utils.SignToken(h.cfg.JwtSecret, purpose, token.ID.Hex(), token.ExpiresAt.Time()), nil
+}
+
+// useToken checks the signature of a token and marks it used, forged tokens are
+// rejected without reaching the database
+func (h *AuthController) useToken(ctx context.Context, signed, purpose string) (*models.UserToken, error) {
+	now := time.Now()
+	id, err := utils.VerifyToken(h.cfg.JwtSecret, purpose, signed, now)
+	if err != nil {
+		return nil, invalidTokenError()
+	}
+	tokenID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, invalidTokenError()
+	}
+
+	token, err := h.userTokens.Use(ctx, tokenID, purpose, now)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, invalidTokenError()
+	}
+	if err != nil {
+		return nil, apperrors.Internal("Failed to check token").Wrap(err)
+	}
+	return token, nil
+}
+
+func invalidTokenError() *apperrors.Error {
+	return apperrors.BadRequest("Invalid or expired token").WithCode("invalid_token")
+}
+
+// link returns the address of a page of the application receiving the token
+func (h *AuthController) link(path, token string) string {
+	return strings.TrimSuffix(h.cfg.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendEmail renders the HTML and text templates of an email in views/emails and sends it to the user
+func (h *AuthController) sendEmail(ctx context.Context, user *models.User, subject, name string, vars jet.VarMap) error {
+	vars.Set("name", user.Name).Set("email", user.Email)
+
+	html, err := render("emails/"+name+".jet", vars)
+	if err != nil {
+		return err
+	}
+	text, err := render("emails/"+name+".txt.jet", vars)
+	if err != nil {
+		return err
+	}
+	return h.mailer.Send(ctx, mail.Message{To: user.Email, Subject: subject, Text: text, HTML: html})
+}
+
+// inBackground runs fn after the response, so it neither waits for the mail server nor
+// reveals through its timing whether an email was sent
+func (h *AuthController) inBackground(action string, fn func(ctx context.Context) error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := fn(ctx); err != nil {
+			log.Printf("Failed to %s: %v", action, err)
+		}
+	}()
+}
+
+// formatDuration writes the lifetime of a link for people, such as "48 hours" or "30 minutes"
+func formatDuration(d time.Duration) string {
+	n, unit := int64(d/time.Minute), "minute"
+	if d >= time.Hour && d%time.Hour == 0 {
+		n, unit = int64(d/time.Hour), "hour"
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+func render(name string, vars jet.VarMap) (string, error) {
+	tmpl, err := views.GetTemplate(name)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (h *AuthController) VerifyEmailView(c *fiber.Ctx) error {
+	return renderView(c, "verify_email.jet", jet.VarMap{}.Set("token", c.Query("token")))
+}
+
+func (h *AuthController) ResetPasswordView(c *fiber.Ctx) error {
+	return renderView(c, "reset_password.jet", jet.VarMap{}.Set("token", c.Query("token")))
+}
+
+func (h *AuthController) LoginView(c *fiber.Ctx) error {
+	return renderView(c, "login.jet", nil)
+}
+
+// renderView answers with an HTML page of views
+func renderView(c *fiber.Ctx, name string, vars jet.VarMap) error {
+	// Load the template
+	tmpl, err := views.GetTemplate(name)
 	if err != nil {
 		log.Println("Error loading template:", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Template not found")
@@ -150,7 +784,7 @@ func LoginView(c *fiber.Ctx) error {
 
 	// Create a buffer to store the rendered HTML
 	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, nil, nil)
+	err = tmpl.Execute(&buf, vars, nil)
 	if err != nil {
 		log.Println("Error executing template:", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Failed to render template")
@@ -0,0 +1,506 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fiber/apperrors"
+	"fiber/config"
+	"fiber/dto"
+	"fiber/middlewares"
+	"fiber/models"
+	"fiber/money"
+	"fiber/repositories"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// CartTokenHeader carries the token of an anonymous cart. It is issued with the
+	// first item and sent with Login to merge the cart into the user's.
+	CartTokenHeader = "X-Cart-Token"
+
+	maxCartItems    = 50
+	maxCartQuantity = 100
+)
+
+var errCartEmpty = errors.New("cart is empty")
+
+// cartChangedError aborts a checkout whose cart no longer matches the catalogue
+type cartChangedError struct {
+	changes []models.CartChange
+}
+
+func (e *cartChangedError) Error() string {
+	return "cart changed since it was last priced"
+}
+
+// CartController manages the cart of the current user or anonymous visitor and its checkout
+type CartController struct {
+	cfg        *config.Config
+	carts      repositories.CartRepository
+	products   repositories.ProductRepository
+	users      repositories.UserRepository
+	customers  repositories.CustomerRepository
+	orders     repositories.OrderRepository
+	inventory  repositories.InventoryRepository
+	transactor repositories.Transactor
+}
+
+func NewCartController(cfg *config.Config, carts repositories.CartRepository, products repositories.ProductRepository, users repositories.UserRepository, customers repositories.CustomerRepository, orders repositories.OrderRepository, inventory repositories.InventoryRepository, transactor repositories.Transactor) *CartController {
+	return &CartController{cfg: cfg, carts: carts, products: products, users: users, customers: customers, orders: orders, inventory: inventory, transactor: transactor}
+}
+
+// cartView is the cart as returned to the client
+type cartView struct {
+	*models.Cart
+	Total     money.Money         `json:"total"`
+	Changes   []models.CartChange `json:"changes,omitempty"`
+	CartToken string              `json:"cart_token,omitempty"`
+}
+
+// findCart returns the cart of the authenticated user, or else the one of the cart token
+func (h *CartController) findCart(ctx context.Context, c *fiber.Ctx) (*models.Cart, error) {
+	if _, ok := c.Locals("userID").(string); ok {
+		userID, err := currentUserID(c)
+		if err != nil {
+			return nil, err
+		}
+		return h.carts.FindByUser(ctx, userID)
+	}
+	if token := c.Get(CartTokenHeader); token != "" {
+		return h.carts.FindByTokenHash(ctx, hashToken(token))
+	}
+	return nil, repositories.ErrNotFound
+}
+
+// findOrNewCart is findCart creating the cart when there is none yet. Anonymous carts
+// get a new token, returned once, even if the client sent an unknown one.
+func (h *CartController) findOrNewCart(ctx context.Context, c *fiber.Ctx) (*models.Cart, string, error) {
+	cart, err := h.findCart(ctx, c)
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return cart, "", err
+	}
+
+	cart = &models.Cart{Items: []models.CartItem{}, CreatedAt: primitive.NewDateTimeFromTime(time.Now())}
+	if _, ok := c.Locals("userID").(string); ok {
+		userID, err := currentUserID(c)
+		if err != nil {
+			return nil, "", err
+		}
+		cart.UserID = &userID
+		return cart, "", nil
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	cart.TokenHash = hashToken(token)
+	return cart, token, nil
+}
+
+// save stores the cart, pushing back the expiry of anonymous carts
+func (h *CartController) save(ctx context.Context, cart *models.Cart) error {
+	now := time.Now()
+	cart.UpdatedAt = primitive.NewDateTimeFromTime(now)
+	if cart.UserID == nil {
+		expiresAt := primitive.NewDateTimeFromTime(now.Add(h.cfg.CartExpiry))
+		cart.ExpiresAt = &expiresAt
+	}
+	return h.carts.Save(ctx, cart)
+}
+
+// reprice updates the items to the current name and price of their product or variant
+// and removes the products and variants that no longer exist, returning what changed
+func (h *CartController) reprice(ctx context.Context, cart *models.Cart) ([]models.CartChange, error) {
+	var changes []models.CartChange
+	items := make([]models.CartItem, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		product, err := h.products.FindByID(ctx, item.ProductID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			changes = append(changes, models.CartChange{ProductID: item.ProductID, SKU: item.SKU, Name: item.Name, Type: models.CartProductRemoved, OldPrice: item.UnitPrice})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if productVariant(product, item.SKU) != nil {
+			changes = append(changes, models.CartChange{ProductID: item.ProductID, SKU: item.SKU, Name: product.Name, Type: models.CartVariantRemoved, OldPrice: item.UnitPrice})
+			continue
+		}
+
+		price := product.PriceOf(item.SKU)
+		if !price.Equal(item.UnitPrice) {
+			changes = append(changes, models.CartChange{ProductID: item.ProductID, SKU: item.SKU, Name: product.Name, Type: models.CartPriceChanged, OldPrice: item.UnitPrice, NewPrice: &price})
+		}
+		item.Name = product.Name
+		item.UnitPrice = price
+		items = append(items, item)
+	}
+	cart.Items = items
+	return changes, nil
+}
+
+// cartTotal adds up the lines of the cart, in the shop currency
+func (h *CartController) cartTotal(cart *models.Cart) (money.Money, error) {
+	total := money.Zero(h.cfg.Currency)
+	for _, item := range cart.Items {
+		line, err := item.UnitPrice.Mul(item.Quantity)
+		if err != nil {
+			return money.Money{}, err
+		}
+		if total, err = total.Add(line); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func (h *CartController) respond(c *fiber.Ctx, cart *models.Cart, changes []models.CartChange, token string) error {
+	total, err := h.cartTotal(cart)
+	// Totals too large to compute become a 422
+	if errors.Is(err, money.ErrOutOfRange) {
+		return err
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to price cart").Wrap(err)
+	}
+	if token != "" {
+		c.Set(CartTokenHeader, token)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": cartView{Cart: cart, Total: total, Changes: changes, CartToken: token}})
+}
+
+// GetCart returns the cart priced with the current product prices
+func (h *CartController) GetCart(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cart, err := h.findCart(ctx, c)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": fiber.Map{"items": []models.CartItem{}, "total": money.Zero(h.cfg.Currency)}})
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch cart").Wrap(err)
+	}
+
+	changes, err := h.reprice(ctx, cart)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch cart").Wrap(err)
+	}
+	if len(changes) > 0 {
+		if err := h.save(ctx, cart); err != nil {
+			return apperrors.Internal("Failed to update cart").Wrap(err)
+		}
+	}
+
+	return h.respond(c, cart, changes, "")
+}
+
+// AddCartItem puts a product or variant in the cart, adding to the quantity already there
+func (h *CartController) AddCartItem(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body, err := middlewares.Body[dto.CartItemDTO](c)
+	if err != nil {
+		return err
+	}
+
+	product, err := h.products.FindByID(ctx, body.Product())
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.BadRequest("Product not found").WithCode("product_not_found").With("product_id", body.ProductID)
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch product").Wrap(err)
+	}
+	if err := productVariant(product, body.SKU); err != nil {
+		return err
+	}
+
+	cart, token, err := h.findOrNewCart(ctx, c)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch cart").Wrap(err)
+	}
+	changes, err := h.reprice(ctx, cart)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch cart").Wrap(err)
+	}
+
+	if item := cart.Item(product.ID, body.SKU); item != nil {
+		if item.Quantity+body.Quantity > maxCartQuantity {
+			return apperrors.BadRequest("A cart cannot hold more than 100 of a product").WithCode("cart_quantity_exceeded").With("quantity", item.Quantity)
+		}
+		item.Quantity += body.Quantity
+	} else {
+		if len(cart.Items) >= maxCartItems {
+			return apperrors.BadRequest("A cart cannot hold more than 50 products").WithCode("cart_full")
+		}
+		cart.Items = append(cart.Items, models.CartItem{ProductID: product.ID, SKU: body.SKU, Name: product.Name, UnitPrice: product.PriceOf(body.SKU), Quantity: body.Quantity})
+	}
+
+	if err := h.save(ctx, cart); err != nil {
+		return apperrors.Internal("Failed to update cart").Wrap(err)
+	}
+	return h.respond(c, cart, changes, token)
+}
+
+// UpdateCartItem sets the quantity of a product already in the cart, the sku query
+// parameter naming the variant
+func (h *CartController) UpdateCartItem(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	productID, err := primitive.ObjectIDFromHex(c.Params("productId"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid product ID")
+	}
+
+	body, err := middlewares.Body[dto.CartQuantityDTO](c)
+	if err != nil {
+		return err
+	}
+
+	cart, err := h.findCart(ctx, c)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Product is not in the cart")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch cart").Wrap(err)
+	}
+	changes, err := h.reprice(ctx, cart)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch cart").Wrap(err)
+	}
+
+	item := cart.Item(productID, c.Query("sku"))
+	if item == nil {
+		return apperrors.NotFound("Product is not in the cart")
+	}
+	item.Quantity = body.Quantity
+
+	if err := h.save(ctx, cart); err != nil {
+		return apperrors.Internal("Failed to update cart").Wrap(err)
+	}
+	return h.respond(c, cart, changes, "")
+}
+
+func (h *CartController) RemoveCartItem(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	productID, err := primitive.ObjectIDFromHex(c.Params("productId"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid product ID")
+	}
+
+	cart, err := h.findCart(ctx, c)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Product is not in the cart")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch cart").Wrap(err)
+	}
+	if !cart.RemoveItem(productID, c.Query("sku")) {
+		return apperrors.NotFound("Product is not in the cart")
+	}
+
+	changes, err := h.reprice(ctx, cart)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch cart").Wrap(err)
+	}
+	if err := h.save(ctx, cart); err != nil {
+		return apperrors.Internal("Failed to update cart").Wrap(err)
+	}
+	return h.respond(c, cart, changes, "")
+}
+
+func (h *CartController) ClearCart(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cart, err := h.findCart(ctx, c)
+	if err == nil {
+		err = h.carts.Delete(ctx, cart.ID)
+	}
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.Internal("Failed to clear cart").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Cart cleared successfully"})
+}
+
+// Checkout turns the cart of the user into a pending order and empties the cart in
+// one transaction. If a price changed or a product disappeared since the cart was
+// last returned, nothing is ordered and the changes are reported for review. The
+// stock of the order is reserved until it is paid or ReservationTTL passes.
+func (h *CartController) Checkout(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var order models.Order
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		cart, err := h.carts.FindByUser(ctx, userID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errCartEmpty
+		}
+		if err != nil {
+			return err
+		}
+		if len(cart.Items) == 0 {
+			return errCartEmpty
+		}
+
+		changes, err := h.reprice(ctx, cart)
+		if err != nil {
+			return err
+		}
+		if len(changes) > 0 {
+			return &cartChangedError{changes: changes}
+		}
+
+		customer, err := h.customerFor(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		now := primitive.NewDateTimeFromTime(time.Now())
+		order = models.Order{
+			ID:         primitive.NewObjectID(),
+			CustomerID: customer.ID,
+			Items:      make([]models.OrderItem, 0, len(cart.Items)),
+			Status:     models.OrderPending,
+			StatusHistory: []models.OrderStatusChange{
+				{To: models.OrderPending, ChangedAt: now, ChangedBy: userID},
+			},
+			CreatedAt: now,
+			UpdatedAt: now,
+			CreatedBy: userID,
+			UpdatedBy: userID,
+		}
+		for _, item := range cart.Items {
+			lineTotal, err := item.UnitPrice.Mul(item.Quantity)
+			if err != nil {
+				return err
+			}
+			order.Items = append(order.Items, models.OrderItem{
+				ProductID: item.ProductID,
+				SKU:       item.SKU,
+				Name:      item.Name,
+				UnitPrice: item.UnitPrice,
+				Quantity:  item.Quantity,
+				LineTotal: lineTotal,
+			})
+		}
+		if order.Total, err = orderTotal(h.cfg.Currency, order.Items); err != nil {
+			return err
+		}
+
+		if _, err := reserveItems(ctx, h.inventory, order.ID, order.Items, time.Now().Add(h.cfg.ReservationTTL)); err != nil {
+			return err
+		}
+		if err := h.orders.Create(ctx, &order); err != nil {
+			return err
+		}
+		return h.carts.Delete(ctx, cart.ID)
+	})
+
+	var changed *cartChangedError
+	if errors.As(err, &changed) {
+		return apperrors.Conflict("The cart changed since it was last shown, review it before checking out").WithCode("cart_changed").With("changes", changed.changes)
+	}
+	if errors.Is(err, errCartEmpty) {
+		return apperrors.Conflict("The cart is empty").WithCode("cart_empty")
+	}
+	var outOfStock *outOfStockError
+	if errors.As(err, &outOfStock) {
+		return outOfStock.problem()
+	}
+	if errors.Is(err, money.ErrOutOfRange) {
+		return err
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to check out").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Order placed successfully", "data": order})
+}
+
+// customerFor returns the customer with the email of the user, creating it on the
+// user's first order
+func (h *CartController) customerFor(ctx context.Context, userID primitive.ObjectID) (*models.Customer, error) {
+	user, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	customer, err := h.customers.FindByEmail(ctx, user.Email)
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return customer, err
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	customer = &models.Customer{
+		Name:      user.Name,
+		Email:     user.Email,
+		CreatedAt: now,
+		UpdatedAt: now,
+		CreatedBy: userID,
+		UpdatedBy: userID,
+	}
+	if err := h.customers.Create(ctx, customer); err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
+
+// mergeGuestCart moves the anonymous cart of the token into the cart of the user who
+// just logged in. Quantities of products in both carts are added up to the limits.
+// Both carts are read and written in one transaction, so the guest cart is never
+// merged twice nor lost.
+func mergeGuestCart(ctx context.Context, transactor repositories.Transactor, carts repositories.CartRepository, token string, userID primitive.ObjectID) error {
+	return transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		guest, err := carts.FindByTokenHash(ctx, hashToken(token))
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		now := primitive.NewDateTimeFromTime(time.Now())
+		cart, err := carts.FindByUser(ctx, userID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			cart, err = &models.Cart{UserID: &userID, Items: []models.CartItem{}, CreatedAt: now}, nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, item := range guest.Items {
+			if existing := cart.Item(item.ProductID, item.SKU); existing != nil {
+				existing.Quantity += item.Quantity
+				if existing.Quantity > maxCartQuantity {
+					existing.Quantity = maxCartQuantity
+				}
+				continue
+			}
+			if len(cart.Items) < maxCartItems {
+				cart.Items = append(cart.Items, item)
+			}
+		}
+		cart.UpdatedAt = now
+
+		if err := carts.Save(ctx, cart); err != nil {
+			return err
+		}
+		return carts.Delete(ctx, guest.ID)
+	})
+}
@@ -2,124 +2,429 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fiber/apperrors"
 	"fiber/config"
+	"fiber/dto"
+	"fiber/middlewares"
 	"fiber/models"
+	"fiber/repositories"
+	"fiber/utils"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func CreateCategory(c *fiber.Ctx) error {
+// CategoryController handles category CRUD
+type CategoryController struct {
+	cfg        *config.Config
+	categories repositories.CategoryRepository
+	products   repositories.ProductRepository
+	transactor repositories.Transactor
+}
+
+func NewCategoryController(cfg *config.Config, categories repositories.CategoryRepository, products repositories.ProductRepository, transactor repositories.Transactor) *CategoryController {
+	return &CategoryController{cfg: cfg, categories: categories, products: products, transactor: transactor}
+}
+
+// maxListedDependents bounds the products listed when a deletion is restricted
+const maxListedDependents = 20
+
+var errFallbackCategory = errors.New("fallback category is missing, inactive or the category itself")
+
+var (
+	errHasSubcategories = errors.New("category has subcategories")
+	errParentNotFound   = errors.New("parent category not found")
+	errParentDeleted    = errors.New("parent category is deleted")
+	errCategoryCycle    = errors.New("category cannot be moved below its own descendant")
+)
+
+// dependentsError reports the products that still reference a category
+type dependentsError struct {
+	products *repositories.Page[models.Product]
+}
+
+func (e *dependentsError) Error() string {
+	return "category is still used by products"
+}
+
+// releaseProducts applies cfg.CategoryOnDelete to the products of a category that
+// is deleted or disabled. It must run in the same transaction as the category change.
+func (h *CategoryController) releaseProducts(ctx context.Context, categoryID, userID primitive.ObjectID) error {
+	switch h.cfg.CategoryOnDelete {
+	case config.CategoryOnDeleteCascade:
+		_, err := h.products.SoftDeleteByCategory(ctx, categoryID, userID)
+		return err
+
+	case config.CategoryOnDeleteReassign:
+		fallbackID, err := primitive.ObjectIDFromHex(h.cfg.CategoryFallbackID)
+		if err != nil || fallbackID == categoryID {
+			return errFallbackCategory
+		}
+		fallback, err := h.categories.FindByID(ctx, fallbackID)
+		if errors.Is(err, repositories.ErrNotFound) || (err == nil && fallback.Status == models.CategoryInactive) {
+			return errFallbackCategory
+		}
+		if err != nil {
+			return err
+		}
+		_, err = h.products.ReassignCategory(ctx, categoryID, fallbackID, userID)
+		return err
+
+	default:
+		dependents, err := h.products.FindByCategory(ctx, categoryID, repositories.PageRequest{Limit: maxListedDependents})
+		if err != nil {
+			return err
+		}
+		if dependents.Total > 0 {
+			return &dependentsError{products: dependents}
+		}
+		return nil
+	}
+}
+
+// isIntegrityError reports whether releaseProducts refused the change
+func isIntegrityError(err error) bool {
+	var dependents *dependentsError
+	return errors.As(err, &dependents) || errors.Is(err, errFallbackCategory)
+}
+
+// integrityError converts a change refused by releaseProducts into its response
+func integrityError(err error) error {
+	var dependents *dependentsError
+	if errors.As(err, &dependents) {
+		products := make([]fiber.Map, 0, len(dependents.products.Items))
+		for _, p := range dependents.products.Items {
+			products = append(products, fiber.Map{"id": p.ID, "name": p.Name})
+		}
+		return apperrors.Conflict("Category is still used by products").WithCode("category_in_use").With("dependents", fiber.Map{
+			"total":    dependents.products.Total,
+			"products": products,
+		})
+	}
+	return apperrors.Conflict("Products cannot be moved, the fallback category is missing, inactive or the category itself").WithCode("invalid_fallback_category")
+}
+
+// attributeSchema returns the attributes of the variants of the products of a category,
+// the ones inherited from its ancestors included
+func attributeSchema(ctx context.Context, categories repositories.CategoryRepository, category *models.Category) ([]models.Attribute, error) {
+	chain := make([]*models.Category, 0, len(category.Ancestors)+1)
+	for _, id := range category.Ancestors {
+		ancestor, err := categories.FindByID(ctx, id)
+		if errors.Is(err, repositories.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, ancestor)
+	}
+	return models.AttributeSchema(append(chain, category)...), nil
+}
+
+func (h *CategoryController) CreateCategory(c *fiber.Ctx) error {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	categoryCollection := config.DB.Collection("categories")
-
-	var category models.Category
-	if err := c.BodyParser(&category); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	body, err := middlewares.Body[dto.CategoryDTO](c)
+	if err != nil {
+		return err
 	}
+	category := body.ToModel()
 
 	// category.Status = "active"
 
-	_, err := categoryCollection.InsertOne(ctx, category)
+	// The path is derived from the parent
+	if category.ParentID != nil {
+		parent, err := h.categories.FindByID(ctx, *category.ParentID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.BadRequest("Parent category not found")
+		}
+		if err != nil {
+			return apperrors.Internal("Failed to create category").Wrap(err)
+		}
+		category.Ancestors = parent.ChildAncestors()
+	}
+
+	err = h.categories.Create(ctx, &category)
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return err
+	}
 	if err != nil {
-		// fmt.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create category"})
+		return apperrors.Internal("Failed to create category").Wrap(err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Category created successfully"})
 }
 
-func GetCategories(c *fiber.Ctx) error {
+func (h *CategoryController) GetCategories(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	categoryCollection := config.DB.Collection("categories")
+	pageReq, err := utils.ParsePageRequest(c, h.cfg.DefaultPageSize, h.cfg.MaxPageSize)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
 
-	cursor, err := categoryCollection.Find(ctx, bson.M{})
+	categories, err := h.categories.FindAll(ctx, pageReq)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch categories"})
+		return apperrors.Internal("Failed to fetch categories").Wrap(err)
 	}
 
-	var categories []models.Category
-	if err := cursor.All(ctx, &categories); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode categories"})
+	return c.Status(fiber.StatusOK).JSON(utils.NewPageResponse(c, pageReq, categories))
+}
+
+func (h *CategoryController) GetCategoryTree(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	categories, err := h.categories.ListAll(ctx)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch categories").Wrap(err)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": categories})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": utils.BuildCategoryTree(categories)})
 }
 
-func GetCategory(c *fiber.Ctx) error {
+func (h *CategoryController) GetCategory(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	categoryCollection := config.DB.Collection("categories")
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid category ID")
+	}
 
-	id := c.Params("id")
-	category, err := categoryCollection.Find(ctx, map[string]string{"_id": id})
+	category, err := h.categories.FindByID(ctx, objID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Category not found")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+		return apperrors.Internal("Failed to fetch category").Wrap(err)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": category})
+	// The schema products of the category are checked against, inherited attributes included
+	schema, err := attributeSchema(ctx, h.categories, category)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch category").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": category, "attribute_schema": schema})
 }
 
-func UpdateCategory(c *fiber.Ctx) error {
+func (h *CategoryController) UpdateCategory(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	categoryCollection := config.DB.Collection("categories")
-
 	id := c.Params("id")
 
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid category ID"})
+		return apperrors.BadRequest("Invalid category ID")
 	}
 
-	var category models.Category
-	if err := c.BodyParser(&category); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	body, err := middlewares.Body[dto.UpdateCategoryDTO](c)
+	if err != nil {
+		return err
 	}
+	category := body.ToModel()
 
-	update := bson.M{"$set": category}
+	// Get userID from context
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return apperrors.Unauthorized("User not authenticated")
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return apperrors.BadRequest("Invalid user ID")
+	}
 
-	result, err := categoryCollection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	current, err := h.categories.FindByID(ctx, objID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Category not found")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update category"})
+		return apperrors.Internal("Failed to update category").Wrap(err)
 	}
 
-	if result.MatchedCount == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	// Disabling a category affects its products like deleting it
+	disabled := current.Status != models.CategoryInactive && category.Status == models.CategoryInactive
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		if disabled {
+			if err := h.releaseProducts(ctx, objID, userObjectID); err != nil {
+				return err
+			}
+		}
+		return h.categories.Update(ctx, objID, &category)
+	})
+	if isIntegrityError(err) {
+		return integrityError(err)
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Category not found")
+	}
+	// A duplicate name becomes a 409 naming the field
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return err
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to update category").Wrap(err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Category updated successfully"})
 }
 
-func DeleteCategory(c *fiber.Ctx) error {
+func (h *CategoryController) DeleteCategory(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	categoryCollection := config.DB.Collection("categories")
-
 	id := c.Params("id")
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid category ID"})
+		return apperrors.BadRequest("Invalid category ID")
 	}
 
-	result, err := categoryCollection.DeleteOne(ctx, bson.M{"_id": objID})
+	// Get userID from context
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return apperrors.Unauthorized("User not authenticated")
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete category"})
+		return apperrors.BadRequest("Invalid user ID")
+	}
+
+	if _, err := h.categories.FindByID(ctx, objID); errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Category not found")
 	}
 
-	if result.DeletedCount == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	// The category goes to the trash and can be restored until it is purged
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		children, err := h.categories.CountChildren(ctx, objID)
+		if err != nil {
+			return err
+		}
+		if children > 0 {
+			return errHasSubcategories
+		}
+		if err := h.releaseProducts(ctx, objID, userObjectID); err != nil {
+			return err
+		}
+		return h.categories.SoftDelete(ctx, objID, userObjectID)
+	})
+	if errors.Is(err, errHasSubcategories) {
+		return apperrors.Conflict("Category has subcategories, move or delete them first")
+	}
+	if isIntegrityError(err) {
+		return integrityError(err)
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Category not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to delete category").Wrap(err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Category deleted successfully"})
 }
+
+// MoveCategory re-parents a category together with its subtree
+func (h *CategoryController) MoveCategory(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid category ID")
+	}
+
+	body, err := middlewares.Body[dto.MoveCategoryDTO](c)
+	if err != nil {
+		return err
+	}
+
+	parentID := body.Parent()
+	if parentID != nil && *parentID == objID {
+		return apperrors.BadRequest("A category cannot be its own parent")
+	}
+
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		var ancestors []primitive.ObjectID
+		if parentID != nil {
+			parent, err := h.categories.FindByID(ctx, *parentID)
+			if errors.Is(err, repositories.ErrNotFound) {
+				return errParentNotFound
+			}
+			if err != nil {
+				return err
+			}
+			// Moving a category below one of its descendants would detach the subtree
+			for _, ancestor := range parent.Ancestors {
+				if ancestor == objID {
+					return errCategoryCycle
+				}
+			}
+			ancestors = parent.ChildAncestors()
+		}
+		return h.categories.Move(ctx, objID, parentID, ancestors)
+	})
+	if errors.Is(err, errParentNotFound) {
+		return apperrors.BadRequest("Parent category not found")
+	}
+	if errors.Is(err, errCategoryCycle) {
+		return apperrors.BadRequest("A category cannot be moved below its own descendant")
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Category not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to move category").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Category moved successfully"})
+}
+
+func (h *CategoryController) RestoreCategory(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid category ID")
+	}
+
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		category, err := h.categories.FindDeletedByID(ctx, objID)
+		if err != nil {
+			return err
+		}
+		// A subcategory cannot come back under a parent that is still in the trash
+		if category.ParentID != nil {
+			_, err := h.categories.FindByID(ctx, *category.ParentID)
+			if errors.Is(err, repositories.ErrNotFound) {
+				return errParentDeleted
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return h.categories.Restore(ctx, objID)
+	})
+	if errors.Is(err, errParentDeleted) {
+		return apperrors.Conflict("Parent category is deleted, restore it first").WithCode("parent_deleted")
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Deleted category not found")
+	}
+	// A live category took its name meanwhile
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return err
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to restore category").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Category restored successfully"})
+}
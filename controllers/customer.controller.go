@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fiber/apperrors"
+	"fiber/config"
+	"fiber/dto"
+	"fiber/middlewares"
+	"fiber/repositories"
+	"fiber/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CustomerController handles customer CRUD
+type CustomerController struct {
+	cfg        *config.Config
+	customers  repositories.CustomerRepository
+	orders     repositories.OrderRepository
+	transactor repositories.Transactor
+}
+
+func NewCustomerController(cfg *config.Config, customers repositories.CustomerRepository, orders repositories.OrderRepository, transactor repositories.Transactor) *CustomerController {
+	return &CustomerController{cfg: cfg, customers: customers, orders: orders, transactor: transactor}
+}
+
+var errCustomerHasOrders = errors.New("customer has orders")
+
+func (h *CustomerController) CreateCustomer(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body, err := middlewares.Body[dto.CustomerDTO](c)
+	if err != nil {
+		return err
+	}
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	customer := body.ToModel()
+	now := primitive.NewDateTimeFromTime(time.Now())
+	customer.CreatedAt = now
+	customer.UpdatedAt = now
+	customer.CreatedBy = userID
+	customer.UpdatedBy = userID
+
+	if err := h.customers.Create(ctx, &customer); err != nil {
+		if errors.Is(err, repositories.ErrDuplicate) {
+			return err
+		}
+		return apperrors.Internal("Failed to create customer").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Customer created successfully", "data": customer})
+}
+
+func (h *CustomerController) GetCustomers(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pageReq, err := utils.ParsePageRequest(c, h.cfg.DefaultPageSize, h.cfg.MaxPageSize)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	customers, err := h.customers.FindAll(ctx, pageReq)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch customers").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.NewPageResponse(c, pageReq, customers))
+}
+
+func (h *CustomerController) GetCustomer(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid customer ID")
+	}
+
+	customer, err := h.customers.FindByID(ctx, objID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Customer not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch customer").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": customer})
+}
+
+func (h *CustomerController) UpdateCustomer(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid customer ID")
+	}
+
+	body, err := middlewares.Body[dto.UpdateCustomerDTO](c)
+	if err != nil {
+		return err
+	}
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	customer := body.ToModel()
+	customer.ID = objID
+	customer.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	customer.UpdatedBy = userID
+
+	err = h.customers.Update(ctx, &customer)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Customer not found")
+	}
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return err
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to update customer").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Customer updated successfully"})
+}
+
+// DeleteCustomer removes a customer without orders; customers with orders are kept
+// so the order history stays complete
+func (h *CustomerController) DeleteCustomer(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid customer ID")
+	}
+
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		orders, err := h.orders.CountByCustomer(ctx, objID)
+		if err != nil {
+			return err
+		}
+		if orders > 0 {
+			return errCustomerHasOrders
+		}
+		return h.customers.Delete(ctx, objID)
+	})
+	if errors.Is(err, errCustomerHasOrders) {
+		return apperrors.Conflict("Customer has orders and cannot be deleted").WithCode("customer_has_orders")
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Customer not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to delete customer").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Customer deleted successfully"})
+}
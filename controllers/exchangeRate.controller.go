@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fiber/apperrors"
+	"fiber/config"
+	"fiber/dto"
+	"fiber/middlewares"
+	"fiber/models"
+	"fiber/money"
+	"fiber/repositories"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExchangeRateController shows and sets the rates prices are converted with
+type ExchangeRateController struct {
+	cfg   *config.Config
+	rates repositories.ExchangeRateRepository
+}
+
+func NewExchangeRateController(cfg *config.Config, rates repositories.ExchangeRateRepository) *ExchangeRateController {
+	return &ExchangeRateController{cfg: cfg, rates: rates}
+}
+
+// GetExchangeRates lists the rates from the shop currency
+func (h *ExchangeRateController) GetExchangeRates(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rates, err := h.rates.FindAll(ctx, h.cfg.Currency)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch exchange rates").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": fiber.Map{"base": h.cfg.Currency, "rates": rates}})
+}
+
+// currencyParam returns the :currency parameter of the rate endpoints
+func (h *ExchangeRateController) currencyParam(c *fiber.Ctx) (string, error) {
+	// Fiber reuses the buffer behind the parameters, keep a copy that outlives the request
+	currency := strings.Clone(strings.ToUpper(c.Params("currency")))
+	if !money.IsCode(currency) {
+		return "", apperrors.BadRequest("Invalid currency code")
+	}
+	if currency == h.cfg.Currency {
+		return "", apperrors.BadRequest("Prices are in " + currency + ", it needs no exchange rate")
+	}
+	// Rates pulled from a source would overwrite the ones set by hand
+	if h.cfg.ExchangeRateSource != config.ExchangeRateSourceManual {
+		return "", apperrors.Conflict("Exchange rates are pulled from the " + h.cfg.ExchangeRateSource + " source").WithCode("exchange_rates_read_only")
+	}
+	return currency, nil
+}
+
+// SetExchangeRate creates or replaces the rate of a currency
+func (h *ExchangeRateController) SetExchangeRate(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body, err := middlewares.Body[dto.ExchangeRateDTO](c)
+	if err != nil {
+		return err
+	}
+	currency, err := h.currencyParam(c)
+	if err != nil {
+		return err
+	}
+	value, err := money.ParseRate(body.Rate.String())
+	if err != nil {
+		return apperrors.BadRequest("The rate must be greater than 0")
+	}
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	rate := models.ExchangeRate{
+		Base:      h.cfg.Currency,
+		Currency:  currency,
+		Rate:      value,
+		Source:    models.ExchangeRateManual,
+		UpdatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		UpdatedBy: &userID,
+	}
+	if err := h.rates.Save(ctx, &rate); err != nil {
+		return apperrors.Internal("Failed to save exchange rate").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Exchange rate saved successfully", "data": rate})
+}
+
+// DeleteExchangeRate removes the rate of a currency, whose prices are then only
+// shown from the price lists
+func (h *ExchangeRateController) DeleteExchangeRate(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	currency, err := h.currencyParam(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.rates.Delete(ctx, h.cfg.Currency, currency)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Exchange rate not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to delete exchange rate").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Exchange rate deleted successfully"})
+}
+
+// priceConverter shows prices in the currency asked with ?currency=
+type priceConverter struct {
+	currency string
+	rate     *primitive.Decimal128
+	rules    money.Rules
+	// hidePrices removes the price lists that were only selected for the conversion
+	hidePrices bool
+}
+
+// newPriceConverter returns the converter to the ?currency= of the request, or nil
+// when prices stay in the shop currency
+func newPriceConverter(ctx context.Context, c *fiber.Ctx, cfg *config.Config, rates repositories.ExchangeRateRepository) (*priceConverter, error) {
+	currency := strings.ToUpper(c.Query("currency"))
+	if currency == "" || currency == cfg.Currency {
+		return nil, nil
+	}
+	if !money.IsCode(currency) {
+		return nil, currencyNotSupported(currency)
+	}
+
+	rules, err := cfg.PriceRules()
+	if err != nil {
+		return nil, apperrors.Internal("Invalid price rounding").Wrap(err)
+	}
+	converter := &priceConverter{currency: currency, rules: rules}
+
+	rate, err := rates.Find(ctx, cfg.Currency, currency)
+	switch {
+	case err == nil:
+		converter.rate = &rate.Rate
+	case !errors.Is(err, repositories.ErrNotFound):
+		return nil, apperrors.Internal("Failed to fetch exchange rate").Wrap(err)
+	}
+	return converter, nil
+}
+
+func currencyNotSupported(currency string) error {
+	return apperrors.BadRequest("Prices are not available in "+currency).WithCode("currency_not_supported").With("currency", currency)
+}
+
+// price returns the price of a product in the currency: the one of its price list,
+// else its price converted with the exchange rate
+func (p *priceConverter) price(price money.Money, prices []money.Money) (money.Money, error) {
+	for _, listed := range prices {
+		if listed.Currency == p.currency {
+			return listed, nil
+		}
+	}
+	if p.rate == nil {
+		return money.Money{}, currencyNotSupported(p.currency)
+	}
+	return money.Convert(price, p.currency, money.DecimalRat(*p.rate), p.rules)
+}
+
+// selectPrices adds the price list to a projection of the price, which cannot be
+// converted without it
+func (p *priceConverter) selectPrices(query *repositories.ListQuery) {
+	if p == nil || len(query.Fields) == 0 {
+		return
+	}
+	selected := map[string]bool{}
+	for _, field := range query.Fields {
+		selected[field] = true
+	}
+	if selected["price"] && !selected["prices"] {
+		query.Fields = append(query.Fields, "prices")
+		p.hidePrices = true
+	}
+}
+
+// convertDocuments replaces the price of product documents, and the prices of their
+// variants, by their price in the currency. Documents selected without their price
+// are left as they are.
+func (p *priceConverter) convertDocuments(docs []bson.M) error {
+	if p == nil {
+		return nil
+	}
+	for _, doc := range docs {
+		_, hasPrice := doc["price"]
+		_, hasVariants := doc["variants"]
+		if !hasPrice && !hasVariants {
+			continue
+		}
+		var product struct {
+			Price    money.Money      `bson:"price"`
+			Prices   []money.Money    `bson:"prices"`
+			Variants []models.Variant `bson:"variants"`
+		}
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			return apperrors.Internal("Failed to convert price").Wrap(err)
+		}
+		if err := bson.Unmarshal(data, &product); err != nil {
+			return apperrors.Internal("Failed to convert price").Wrap(err)
+		}
+
+		if hasPrice {
+			price, err := p.price(product.Price, product.Prices)
+			if err != nil {
+				return err
+			}
+			doc["price"] = price
+			if p.hidePrices {
+				delete(doc, "prices")
+			}
+		}
+
+		// Variant prices have no price list, they are always converted
+		if hasVariants {
+			for i, variant := range product.Variants {
+				if variant.Price == nil {
+					continue
+				}
+				price, err := p.price(*variant.Price, nil)
+				if err != nil {
+					return err
+				}
+				product.Variants[i].Price = &price
+			}
+			doc["variants"] = product.Variants
+		}
+	}
+	return nil
+}
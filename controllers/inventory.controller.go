@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fiber/apperrors"
+	"fiber/config"
+	"fiber/dto"
+	"fiber/middlewares"
+	"fiber/models"
+	"fiber/repositories"
+	"fiber/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InventoryController shows and changes the stock of products
+type InventoryController struct {
+	cfg       *config.Config
+	inventory repositories.InventoryRepository
+	products  repositories.ProductRepository
+}
+
+func NewInventoryController(cfg *config.Config, inventory repositories.InventoryRepository, products repositories.ProductRepository) *InventoryController {
+	return &InventoryController{cfg: cfg, inventory: inventory, products: products}
+}
+
+// product returns the product of the :productId parameter
+func (h *InventoryController) product(ctx context.Context, c *fiber.Ctx) (*models.Product, error) {
+	productID, err := primitive.ObjectIDFromHex(c.Params("productId"))
+	if err != nil {
+		return nil, apperrors.BadRequest("Invalid product ID")
+	}
+
+	product, err := h.products.FindByID(ctx, productID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, apperrors.NotFound("Product not found")
+	}
+	if err != nil {
+		return nil, apperrors.Internal("Failed to fetch product").Wrap(err)
+	}
+	return product, nil
+}
+
+// GetStock returns the stock of a product in each warehouse with the totals; the
+// sku query parameter narrows it to one variant
+func (h *InventoryController) GetStock(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	product, err := h.product(ctx, c)
+	if err != nil {
+		return err
+	}
+	sku := c.Query("sku")
+	if sku != "" {
+		if err := productVariant(product, sku); err != nil {
+			return err
+		}
+	}
+
+	levels, err := h.inventory.Levels(ctx, product.ID)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch stock").Wrap(err)
+	}
+
+	var onHand, reserved int
+	selected := levels[:0]
+	for _, level := range levels {
+		if sku != "" && level.SKU != sku {
+			continue
+		}
+		selected = append(selected, level)
+		onHand += level.OnHand
+		reserved += level.Reserved
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": fiber.Map{
+		"product_id": product.ID,
+		"on_hand":    onHand,
+		"reserved":   reserved,
+		"available":  onHand - reserved,
+		"warehouses": selected,
+	}})
+}
+
+// GetLowStock lists the stock levels whose available quantity reached their threshold
+func (h *InventoryController) GetLowStock(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pageReq, err := utils.ParsePageRequest(c, h.cfg.DefaultPageSize, h.cfg.MaxPageSize)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	levels, err := h.inventory.LowStock(ctx, pageReq)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch stock").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.NewPageResponse(c, pageReq, levels))
+}
+
+// GetMovements returns the stock ledger of a product
+func (h *InventoryController) GetMovements(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	product, err := h.product(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	pageReq, err := utils.ParsePageRequest(c, h.cfg.DefaultPageSize, h.cfg.MaxPageSize)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	movements, err := h.inventory.Movements(ctx, product.ID, pageReq)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch stock movements").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.NewPageResponse(c, pageReq, movements))
+}
+
+// CreateMovement records a receipt, a return or a manual adjustment of the stock
+func (h *InventoryController) CreateMovement(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body, err := middlewares.Body[dto.StockMovementDTO](c)
+	if err != nil {
+		return err
+	}
+	if body.Type != models.MovementAdjustment && body.Quantity <= 0 {
+		return apperrors.BadRequest("The quantity of a " + body.Type + " must be positive")
+	}
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	product, err := h.product(ctx, c)
+	if err != nil {
+		return err
+	}
+	if err := productVariant(product, body.SKU); err != nil {
+		return err
+	}
+
+	movement := models.StockMovement{
+		ProductID: product.ID,
+		SKU:       body.SKU,
+		Warehouse: warehouseOrDefault(body.Warehouse),
+		Type:      body.Type,
+		Quantity:  body.Quantity,
+		Note:      body.Note,
+		CreatedBy: &userID,
+	}
+	err = h.inventory.Adjust(ctx, &movement)
+	if errors.Is(err, repositories.ErrInsufficientStock) {
+		return apperrors.Conflict("Not enough unreserved stock to remove").WithCode("insufficient_stock").
+			With("warehouse", movement.Warehouse)
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to record stock movement").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Stock updated successfully", "data": movement})
+}
+
+// SetThreshold sets the low stock threshold of a product or variant in a warehouse
+func (h *InventoryController) SetThreshold(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body, err := middlewares.Body[dto.StockThresholdDTO](c)
+	if err != nil {
+		return err
+	}
+
+	product, err := h.product(ctx, c)
+	if err != nil {
+		return err
+	}
+	if err := productVariant(product, body.SKU); err != nil {
+		return err
+	}
+
+	if err := h.inventory.SetThreshold(ctx, product.ID, body.SKU, warehouseOrDefault(body.Warehouse), body.Threshold); err != nil {
+		return apperrors.Internal("Failed to update threshold").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Threshold updated successfully"})
+}
+
+func warehouseOrDefault(warehouse string) string {
+	if warehouse == "" {
+		return models.DefaultWarehouse
+	}
+	return warehouse
+}
+
+// outOfStockError aborts an operation that needs more stock than is available
+type outOfStockError struct {
+	item models.OrderItem
+}
+
+func (e *outOfStockError) Error() string {
+	return "not enough stock of " + e.item.ProductID.Hex()
+}
+
+// problem is the response of the error
+func (e *outOfStockError) problem() error {
+	err := apperrors.Conflict("Not enough stock of "+e.item.Name).WithCode("out_of_stock").
+		With("product_id", e.item.ProductID.Hex()).
+		With("name", e.item.Name)
+	if e.item.SKU != "" {
+		err = err.With("sku", e.item.SKU)
+	}
+	return err
+}
+
+// reserveItems holds the stock of the items for the order until expiresAt. If an item
+// is out of stock, the reservations already made are released.
+func reserveItems(ctx context.Context, inventory repositories.InventoryRepository, orderID primitive.ObjectID, items []models.OrderItem, expiresAt time.Time) ([]models.Reservation, error) {
+	reservations := make([]models.Reservation, 0, len(items))
+	for _, item := range items {
+		reservation := models.Reservation{
+			OrderID:   orderID,
+			ProductID: item.ProductID,
+			SKU:       item.SKU,
+			Quantity:  item.Quantity,
+			ExpiresAt: primitive.NewDateTimeFromTime(expiresAt),
+		}
+		err := inventory.Reserve(ctx, &reservation)
+		if err == nil {
+			reservations = append(reservations, reservation)
+			continue
+		}
+
+		for i := range reservations {
+			inventory.Release(ctx, &reservations[i], nil)
+		}
+		if errors.Is(err, repositories.ErrInsufficientStock) {
+			return nil, &outOfStockError{item: item}
+		}
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// activeReservations returns the reservations of the order still holding stock
+func activeReservations(ctx context.Context, inventory repositories.InventoryRepository, orderID primitive.ObjectID) ([]models.Reservation, error) {
+	reservations, err := inventory.FindReservations(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	active := reservations[:0]
+	for _, reservation := range reservations {
+		if reservation.Status == models.ReservationActive {
+			active = append(active, reservation)
+		}
+	}
+	return active, nil
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fiber/apperrors"
+	"fiber/config"
+	"fiber/models"
+	"fiber/repositories"
+	"fiber/utils"
+	"net"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LockoutController lets admins review and lift login lockouts
+type LockoutController struct {
+	cfg       *config.Config
+	users     repositories.UserRepository
+	throttles repositories.LoginThrottleRepository
+	lockouts  repositories.LockoutEventRepository
+}
+
+func NewLockoutController(cfg *config.Config, users repositories.UserRepository, throttles repositories.LoginThrottleRepository, lockouts repositories.LockoutEventRepository) *LockoutController {
+	return &LockoutController{cfg: cfg, users: users, throttles: throttles, lockouts: lockouts}
+}
+
+// GetLockoutEvents returns the audit log of lockouts, oldest first
+func (h *LockoutController) GetLockoutEvents(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pageReq, err := utils.ParsePageRequest(c, h.cfg.DefaultPageSize, h.cfg.MaxPageSize)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	events, err := h.lockouts.FindAll(ctx, pageReq)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch lockout events").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.NewPageResponse(c, pageReq, events))
+}
+
+// UnlockUser forgets the failed logins of a user's email, ending its backoff or lockout
+func (h *LockoutController) UnlockUser(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid user ID")
+	}
+
+	user, err := h.users.FindByID(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("User not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch user").Wrap(err)
+	}
+
+	event := models.LockoutEvent{Scope: models.ThrottleAccount, Key: loginKey(user.Email), UserID: &user.ID}
+	if err := h.unlock(c, ctx, event); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User unlocked successfully"})
+}
+
+// UnlockIP forgets the failed logins from an IP address, ending its lockout
+func (h *LockoutController) UnlockIP(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ip := net.ParseIP(c.Params("ip"))
+	if ip == nil {
+		return apperrors.BadRequest("Invalid IP address")
+	}
+
+	event := models.LockoutEvent{Scope: models.ThrottleIP, Key: ip.String()}
+	if err := h.unlock(c, ctx, event); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "IP address unlocked successfully"})
+}
+
+// unlock resets the counter of the event and audits it when it was blocking logins
+func (h *LockoutController) unlock(c *fiber.Ctx, ctx context.Context, event models.LockoutEvent) error {
+	adminID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	throttle, err := h.throttles.Reset(ctx, event.Scope, event.Key)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to unlock").Wrap(err)
+	}
+
+	if _, blocked := throttle.BlockedUntil(time.Now()); blocked {
+		event.Type = models.LockoutUnlocked
+		event.Failures = throttle.Failures
+		event.ActorID = &adminID
+		auditLockout(ctx, h.lockouts, event)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fiber/apperrors"
+	"fiber/models"
+	"fiber/storage"
+	"fiber/utils"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MediaController serves uploaded files
+type MediaController struct {
+	store storage.Storage
+}
+
+func NewMediaController(store storage.Storage) *MediaController {
+	return &MediaController{store: store}
+}
+
+func (h *MediaController) GetMedia(c *fiber.Ctx) error {
+	size := c.Query("size", "original")
+	if !validImageSize(size) {
+		return apperrors.BadRequest("invalid size, allowed: " + strings.Join(imageSizeNames(), ", "))
+	}
+
+	// The object is streamed after the handler returns, so the read must not be
+	// bound to a context cancelled on return; backends enforce their own timeouts
+	reader, info, err := h.store.Get(context.Background(), utils.VariantKey(c.Params("*"), size))
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		return apperrors.NotFound("Media not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch media").Wrap(err)
+	}
+
+	// Keys are never reused, so clients may cache the content forever
+	c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	if info.ETag != "" {
+		c.Set(fiber.HeaderETag, info.ETag)
+	}
+	if !info.ModTime.IsZero() {
+		c.Set(fiber.HeaderLastModified, info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if match := c.Get(fiber.HeaderIfNoneMatch); match != "" && match == info.ETag {
+		reader.Close()
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, info.ContentType)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	return c.SendStream(reader, int(info.Size))
+}
+
+// imageUpload is a validated image with its generated variants
+type imageUpload struct {
+	contentType string
+	variants    map[string][]byte
+}
+
+// readImage generates the variants of an optional image whose size and type were
+// checked by the file rules of the DTO
+func readImage(file *multipart.FileHeader) (*imageUpload, error) {
+	if file == nil {
+		return nil, nil
+	}
+
+	contentType, err := utils.DetectFileType(file)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, errors.New("could not read uploaded file")
+	}
+	defer f.Close()
+
+	variants, err := utils.ResizeImage(f, contentType, utils.ImageSizes)
+	if err != nil {
+		return nil, err
+	}
+	return &imageUpload{contentType: contentType, variants: variants}, nil
+}
+
+// storeImage saves every variant of the image under a new key below prefix and
+// returns the key of the original with the URLs of the variants
+func storeImage(ctx context.Context, store storage.Storage, prefix string, image *imageUpload) (string, *models.ImageVariants, error) {
+	key, err := storage.NewKey(prefix, image.contentType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, size := range utils.ImageSizes {
+		data := image.variants[size.Name]
+		if err := store.Put(ctx, utils.VariantKey(key, size.Name), bytes.NewReader(data), int64(len(data)), image.contentType); err != nil {
+			utils.DeleteImage(ctx, store, key)
+			return "", nil, err
+		}
+	}
+
+	return key, &models.ImageVariants{
+		Thumb:    mediaURL(key, "thumb"),
+		Medium:   mediaURL(key, "medium"),
+		Original: mediaURL(key, "original"),
+	}, nil
+}
+
+func mediaURL(key, size string) string {
+	if size == "original" {
+		return "/media/" + key
+	}
+	return "/media/" + key + "?size=" + size
+}
+
+func validImageSize(name string) bool {
+	for _, size := range utils.ImageSizes {
+		if size.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func imageSizeNames() []string {
+	names := make([]string, 0, len(utils.ImageSizes))
+	for _, size := range utils.ImageSizes {
+		names = append(names, size.Name)
+	}
+	return names
+}
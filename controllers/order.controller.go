@@ -0,0 +1,412 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fiber/apperrors"
+	"fiber/config"
+	"fiber/dto"
+	"fiber/middlewares"
+	"fiber/models"
+	"fiber/money"
+	"fiber/repositories"
+	"fiber/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OrderController handles orders and their status transitions
+type OrderController struct {
+	cfg        *config.Config
+	orders     repositories.OrderRepository
+	customers  repositories.CustomerRepository
+	products   repositories.ProductRepository
+	inventory  repositories.InventoryRepository
+	transactor repositories.Transactor
+}
+
+// orderQuerySchema whitelists the filters and sorts of GET /api/orders
+var orderQuerySchema = utils.QuerySchema{
+	Filters: map[string]utils.FilterField{
+		"status":      {Type: utils.StringField, Ops: []string{"eq", "ne"}},
+		"customer_id": {Type: utils.ObjectIDField, Ops: []string{"eq"}},
+		"total":       {Type: utils.DecimalField, Ops: []string{"eq", "gt", "gte", "lt", "lte"}},
+		"created_at":  {Type: utils.TimeField, Ops: []string{"after", "before"}},
+	},
+	Sorts: []string{"order_number", "total", "created_at", "updated_at"},
+	Paths: map[string]string{"total": "total.amount"},
+}
+
+func NewOrderController(cfg *config.Config, orders repositories.OrderRepository, customers repositories.CustomerRepository, products repositories.ProductRepository, inventory repositories.InventoryRepository, transactor repositories.Transactor) *OrderController {
+	return &OrderController{cfg: cfg, orders: orders, customers: customers, products: products, inventory: inventory, transactor: transactor}
+}
+
+// orderTotal adds up the lines of an order, which are all in the currency
+func orderTotal(currency string, items []models.OrderItem) (money.Money, error) {
+	total := money.Zero(currency)
+	for _, item := range items {
+		var err error
+		if total, err = total.Add(item.LineTotal); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// orderItems prices the items of the body with the current name and price of each
+// product or variant; lines repeating one are merged
+func (h *OrderController) orderItems(ctx context.Context, body dto.OrderDTO) ([]models.OrderItem, money.Money, error) {
+	keys, quantities := body.Quantities()
+
+	items := make([]models.OrderItem, 0, len(keys))
+	for _, key := range keys {
+		product, err := h.products.FindByID(ctx, key.ProductID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, money.Money{}, apperrors.BadRequest("Product not found").WithCode("product_not_found").With("product_id", key.ProductID.Hex())
+		}
+		if err != nil {
+			return nil, money.Money{}, apperrors.Internal("Failed to fetch product").Wrap(err)
+		}
+		if err := productVariant(product, key.SKU); err != nil {
+			return nil, money.Money{}, err
+		}
+
+		quantity := quantities[key]
+		price := product.PriceOf(key.SKU)
+		lineTotal, err := price.Mul(quantity)
+		if err != nil {
+			// Too large to compute, a 422
+			return nil, money.Money{}, err
+		}
+		item := models.OrderItem{
+			ProductID: key.ProductID,
+			SKU:       key.SKU,
+			Name:      product.Name,
+			UnitPrice: price,
+			Quantity:  quantity,
+			LineTotal: lineTotal,
+		}
+		items = append(items, item)
+	}
+
+	total, err := orderTotal(h.cfg.Currency, items)
+	if errors.Is(err, money.ErrOutOfRange) {
+		return nil, money.Money{}, err
+	}
+	if err != nil {
+		return nil, money.Money{}, apperrors.Internal("Failed to price order").Wrap(err)
+	}
+	return items, total, nil
+}
+
+// checkCustomer ensures orders are only placed for existing customers
+func (h *OrderController) checkCustomer(ctx context.Context, customerID primitive.ObjectID) error {
+	_, err := h.customers.FindByID(ctx, customerID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.BadRequest("Customer not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch customer").Wrap(err)
+	}
+	return nil
+}
+
+func (h *OrderController) CreateOrder(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body, err := middlewares.Body[dto.OrderDTO](c)
+	if err != nil {
+		return err
+	}
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkCustomer(ctx, body.Customer()); err != nil {
+		return err
+	}
+	items, total, err := h.orderItems(ctx, body)
+	if err != nil {
+		return err
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	order := models.Order{
+		CustomerID: body.Customer(),
+		Items:      items,
+		Total:      total,
+		Status:     models.OrderPending,
+		StatusHistory: []models.OrderStatusChange{
+			{To: models.OrderPending, ChangedAt: now, ChangedBy: userID},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+		CreatedBy: userID,
+		UpdatedBy: userID,
+	}
+
+	if err := h.orders.Create(ctx, &order); err != nil {
+		return apperrors.Internal("Failed to create order").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Order created successfully", "data": order})
+}
+
+func (h *OrderController) GetOrders(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pageReq, err := utils.ParsePageRequest(c, h.cfg.DefaultPageSize, h.cfg.MaxPageSize)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	query, err := utils.ParseListQuery(c, orderQuerySchema)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	orders, err := h.orders.FindAll(ctx, query, pageReq)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch orders").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.NewPageResponse(c, pageReq, orders))
+}
+
+func (h *OrderController) GetOrder(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid order ID")
+	}
+
+	order, err := h.orders.FindByID(ctx, objID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Order not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch order").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": order})
+}
+
+// UpdateOrder replaces the customer and items of a pending order, pricing them again.
+// The stock reserved at checkout is reserved again for the new items.
+func (h *OrderController) UpdateOrder(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid order ID")
+	}
+
+	body, err := middlewares.Body[dto.OrderDTO](c)
+	if err != nil {
+		return err
+	}
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkCustomer(ctx, body.Customer()); err != nil {
+		return err
+	}
+	items, total, err := h.orderItems(ctx, body)
+	if err != nil {
+		return err
+	}
+
+	order := models.Order{
+		ID:         objID,
+		CustomerID: body.Customer(),
+		Items:      items,
+		Total:      total,
+		UpdatedAt:  primitive.NewDateTimeFromTime(time.Now()),
+		UpdatedBy:  userID,
+	}
+
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		reservations, err := activeReservations(ctx, h.inventory, objID)
+		if err != nil {
+			return err
+		}
+		if len(reservations) > 0 {
+			for i := range reservations {
+				if err := h.inventory.Release(ctx, &reservations[i], &userID); err != nil {
+					return err
+				}
+			}
+			if _, err := reserveItems(ctx, h.inventory, objID, items, reservations[0].ExpiresAt.Time()); err != nil {
+				return err
+			}
+		}
+		return h.orders.UpdateItems(ctx, &order)
+	})
+	var outOfStock *outOfStockError
+	if errors.As(err, &outOfStock) {
+		return outOfStock.problem()
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Order not found")
+	}
+	if errors.Is(err, repositories.ErrOrderStatusChanged) || errors.Is(err, repositories.ErrReservationClosed) {
+		return apperrors.Conflict("Only pending orders can be changed").WithCode("order_not_pending")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to update order").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Order updated successfully"})
+}
+
+// UpdateOrderStatus moves an order to another status following models.OrderTransitions
+// and updates the stock in the same transaction: paying sells the reserved stock,
+// cancelling releases it and refunding puts the sold stock back
+func (h *OrderController) UpdateOrderStatus(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid order ID")
+	}
+
+	body, err := middlewares.Body[dto.OrderStatusDTO](c)
+	if err != nil {
+		return err
+	}
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	order, err := h.orders.FindByID(ctx, objID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Order not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch order").Wrap(err)
+	}
+
+	if !models.CanTransition(order.Status, body.Status) {
+		return apperrors.Conflict("Order cannot move from "+order.Status+" to "+body.Status).WithCode("invalid_transition").
+			With("status", order.Status).
+			With("allowed", models.OrderTransitions[order.Status])
+	}
+
+	change := models.OrderStatusChange{
+		From:      order.Status,
+		To:        body.Status,
+		Note:      body.Note,
+		ChangedAt: primitive.NewDateTimeFromTime(time.Now()),
+		ChangedBy: userID,
+	}
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := updateOrderStock(ctx, h.inventory, h.cfg.ReservationTTL, order, body.Status, userID); err != nil {
+			return err
+		}
+		return h.orders.UpdateStatus(ctx, objID, change)
+	})
+	var outOfStock *outOfStockError
+	if errors.As(err, &outOfStock) {
+		return outOfStock.problem()
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Order not found")
+	}
+	if errors.Is(err, repositories.ErrOrderStatusChanged) || errors.Is(err, repositories.ErrReservationClosed) {
+		return apperrors.Conflict("Order status was changed by another request, reload the order and try again")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to update order status").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Order status updated successfully"})
+}
+
+// updateOrderStock applies the stock changes of moving the order to status. Stock
+// reserved again for an unreserved order is held for ttl.
+func updateOrderStock(ctx context.Context, inventory repositories.InventoryRepository, ttl time.Duration, order *models.Order, status string, userID primitive.ObjectID) error {
+	switch status {
+	case models.OrderPaid:
+		return sellOrderStock(ctx, inventory, ttl, order, userID)
+	case models.OrderCancelled:
+		reservations, err := activeReservations(ctx, inventory, order.ID)
+		if err != nil {
+			return err
+		}
+		for i := range reservations {
+			if err := inventory.Release(ctx, &reservations[i], &userID); err != nil {
+				return err
+			}
+		}
+	case models.OrderRefunded:
+		reservations, err := inventory.FindReservations(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		for _, reservation := range reservations {
+			if reservation.Status != models.ReservationCommitted {
+				continue
+			}
+			movement := models.StockMovement{
+				ProductID: reservation.ProductID,
+				SKU:       reservation.SKU,
+				Warehouse: reservation.Warehouse,
+				Type:      models.MovementReturn,
+				Quantity:  reservation.Quantity,
+				OrderID:   &order.ID,
+				Note:      "Refund of order " + order.OrderNumber,
+				CreatedBy: &userID,
+			}
+			if err := inventory.Adjust(ctx, &movement); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sellOrderStock commits the reservations of the order. Items without one, because
+// the order was not placed at checkout or its reservation expired, are reserved
+// first so an order is never paid for stock that is not there.
+func sellOrderStock(ctx context.Context, inventory repositories.InventoryRepository, ttl time.Duration, order *models.Order, userID primitive.ObjectID) error {
+	reservations, err := activeReservations(ctx, inventory, order.ID)
+	if err != nil {
+		return err
+	}
+
+	held := map[models.ItemKey]int{}
+	for _, reservation := range reservations {
+		held[models.ItemKey{ProductID: reservation.ProductID, SKU: reservation.SKU}] += reservation.Quantity
+	}
+	var missing []models.OrderItem
+	for _, item := range order.Items {
+		if quantity := item.Quantity - held[item.Key()]; quantity > 0 {
+			item.Quantity = quantity
+			missing = append(missing, item)
+		}
+	}
+
+	more, err := reserveItems(ctx, inventory, order.ID, missing, time.Now().Add(ttl))
+	if err != nil {
+		return err
+	}
+	reservations = append(reservations, more...)
+	for i := range reservations {
+		if err := inventory.Commit(ctx, &reservations[i], &userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fiber/apperrors"
+	"fiber/config"
+	"fiber/models"
+	"fiber/payments"
+	"fiber/repositories"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	errEventHandled   = errors.New("webhook event already handled")
+	errPaymentUnknown = errors.New("payment not found")
+)
+
+// PaymentController starts, captures and refunds the payments of orders and
+// receives the notifications of the payment provider
+type PaymentController struct {
+	cfg        *config.Config
+	provider   payments.PaymentProvider
+	payments   repositories.PaymentRepository
+	orders     repositories.OrderRepository
+	inventory  repositories.InventoryRepository
+	transactor repositories.Transactor
+}
+
+func NewPaymentController(cfg *config.Config, provider payments.PaymentProvider, paymentRepo repositories.PaymentRepository, orders repositories.OrderRepository, inventory repositories.InventoryRepository, transactor repositories.Transactor) *PaymentController {
+	return &PaymentController{cfg: cfg, provider: provider, payments: paymentRepo, orders: orders, inventory: inventory, transactor: transactor}
+}
+
+// paymentView is a new payment with the secret the client needs to complete it
+type paymentView struct {
+	*models.Payment
+	ClientSecret string `json:"client_secret"`
+}
+
+// CreatePayment starts a payment attempt for the total of a pending order
+func (h *PaymentController) CreatePayment(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	orderID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid order ID")
+	}
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	order, err := h.orders.FindByID(ctx, orderID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Order not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch order").Wrap(err)
+	}
+	if !models.CanTransition(order.Status, models.OrderPaid) {
+		return apperrors.Conflict("Only pending orders can be paid").WithCode("order_not_payable").With("status", order.Status)
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	payment := models.Payment{
+		ID:        primitive.NewObjectID(),
+		OrderID:   order.ID,
+		UserID:    userID,
+		Provider:  h.provider.Name(),
+		Amount:    order.Total,
+		Status:    models.PaymentPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// The payment ID makes a retried intent creation return the same intent
+	intent, err := h.provider.CreateIntent(ctx, payments.IntentRequest{
+		Amount:         payment.Amount,
+		Reference:      order.OrderNumber,
+		IdempotencyKey: payment.ID.Hex(),
+	})
+	if err != nil {
+		return apperrors.Internal("Failed to create payment").Wrap(err)
+	}
+	payment.IntentID = intent.ID
+
+	if err := h.payments.Create(ctx, &payment); err != nil {
+		return apperrors.Internal("Failed to create payment").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Payment created successfully",
+		"data":    paymentView{Payment: &payment, ClientSecret: intent.ClientSecret},
+	})
+}
+
+// GetPayments lists the payment attempts of an order
+func (h *PaymentController) GetPayments(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	orderID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid order ID")
+	}
+
+	attempts, err := h.payments.FindByOrder(ctx, orderID)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch payments").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": attempts})
+}
+
+// findPayment returns the payment of the :id parameter
+func (h *PaymentController) findPayment(ctx context.Context, c *fiber.Ctx) (*models.Payment, error) {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return nil, apperrors.BadRequest("Invalid payment ID")
+	}
+
+	payment, err := h.payments.FindByID(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, apperrors.NotFound("Payment not found")
+	}
+	if err != nil {
+		return nil, apperrors.Internal("Failed to fetch payment").Wrap(err)
+	}
+	return payment, nil
+}
+
+// CapturePayment collects an authorized payment and marks its order as paid
+func (h *PaymentController) CapturePayment(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	payment, err := h.findPayment(ctx, c)
+	if err != nil {
+		return err
+	}
+	if payment.Status != models.PaymentPending {
+		return apperrors.Conflict("Only pending payments can be captured").WithCode("payment_not_capturable").With("status", payment.Status)
+	}
+
+	_, err = h.provider.Capture(ctx, payment.IntentID)
+	if errors.Is(err, payments.ErrInvalidState) || errors.Is(err, payments.ErrIntentNotFound) {
+		return apperrors.Conflict("The payment provider refused the capture").WithCode("payment_not_capturable")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to capture payment").Wrap(err)
+	}
+
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		return h.settle(ctx, payment, models.PaymentSucceeded, "", userID)
+	})
+	if err != nil {
+		return apperrors.Internal("Failed to update payment").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Payment captured successfully", "data": payment})
+}
+
+// RefundPayment gives the whole amount of a succeeded payment back and marks its
+// order as refunded when its status allows it
+func (h *PaymentController) RefundPayment(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	payment, err := h.findPayment(ctx, c)
+	if err != nil {
+		return err
+	}
+	if payment.Status != models.PaymentSucceeded {
+		return apperrors.Conflict("Only succeeded payments can be refunded").WithCode("payment_not_refundable").With("status", payment.Status)
+	}
+
+	refund, err := h.provider.Refund(ctx, payment.IntentID, payment.Amount)
+	if errors.Is(err, payments.ErrInvalidState) || errors.Is(err, payments.ErrIntentNotFound) {
+		return apperrors.Conflict("The payment provider refused the refund").WithCode("payment_not_refundable")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to refund payment").Wrap(err)
+	}
+
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		payment.Status = models.PaymentRefunded
+		payment.RefundID = refund.ID
+		payment.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+		if err := h.payments.UpdateStatus(ctx, payment, models.PaymentSucceeded); err != nil {
+			return err
+		}
+		return h.moveOrder(ctx, payment.OrderID, models.OrderRefunded, "Refunded with payment "+payment.ID.Hex(), userID)
+	})
+	if err != nil {
+		// The money went back already, the records need to be fixed by hand
+		log.Printf("Payment %s was refunded with %s but could not be updated: %v", payment.ID.Hex(), refund.ID, err)
+		return apperrors.Internal("Failed to update payment").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Payment refunded successfully", "data": payment})
+}
+
+// Webhook receives the signed notifications of the payment provider. Each event is
+// applied once: redelivered events and repeated outcomes are acknowledged without
+// changing anything.
+func (h *PaymentController) Webhook(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	event, err := h.provider.VerifyWebhook(c.Body(), c.Get(payments.SignatureHeader))
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		return apperrors.BadRequest("Invalid webhook signature").WithCode("invalid_signature")
+	}
+	if err != nil {
+		return apperrors.BadRequest("Invalid webhook event").WithCode("invalid_event")
+	}
+
+	var status string
+	switch event.Type {
+	case payments.EventPaymentSucceeded:
+		status = models.PaymentSucceeded
+	case payments.EventPaymentFailed:
+		status = models.PaymentFailed
+	default:
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Event ignored"})
+	}
+
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		payment, err := h.payments.FindByIntent(ctx, h.provider.Name(), event.IntentID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errPaymentUnknown
+		}
+		if err != nil {
+			return err
+		}
+
+		err = h.payments.RecordEvent(ctx, &models.PaymentEvent{
+			ID:         event.ID,
+			Provider:   h.provider.Name(),
+			Type:       event.Type,
+			IntentID:   event.IntentID,
+			ReceivedAt: primitive.NewDateTimeFromTime(time.Now()),
+		})
+		if errors.Is(err, repositories.ErrDuplicate) {
+			return errEventHandled
+		}
+		if err != nil {
+			return err
+		}
+
+		return h.settle(ctx, payment, status, event.FailureReason, payment.UserID)
+	})
+	if errors.Is(err, errEventHandled) {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Event already processed"})
+	}
+	if errors.Is(err, errPaymentUnknown) {
+		// Retrying would not help, the provider may notify payments made elsewhere
+		log.Printf("Ignoring %s event %s for unknown payment intent %s", event.Type, event.ID, event.IntentID)
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Event ignored"})
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to process webhook").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Event processed"})
+}
+
+// settle records the outcome of a payment and moves its order to paid or
+// payment_failed. Outcomes the payment already has are ignored, so a capture and
+// the notification that follows it are applied once.
+func (h *PaymentController) settle(ctx context.Context, payment *models.Payment, status, reason string, by primitive.ObjectID) error {
+	from := payment.Status
+	switch {
+	case status == models.PaymentSucceeded && (from == models.PaymentPending || from == models.PaymentFailed):
+	case status == models.PaymentFailed && from == models.PaymentPending:
+	default:
+		return nil
+	}
+
+	payment.Status = status
+	payment.FailureReason = reason
+	payment.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	err := h.payments.UpdateStatus(ctx, payment, from)
+	if errors.Is(err, repositories.ErrPaymentStatusChanged) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if status == models.PaymentSucceeded {
+		return h.moveOrder(ctx, payment.OrderID, models.OrderPaid, "Paid with payment "+payment.ID.Hex(), by)
+	}
+	return h.moveOrder(ctx, payment.OrderID, models.OrderPaymentFailed, "Payment "+payment.ID.Hex()+" failed: "+reason, by)
+}
+
+// moveOrder moves the order to status with its stock changes when its current
+// status allows it. Otherwise, e.g. for an order paid twice, the payment is kept
+// as is and the order is left for a person to sort out.
+func (h *PaymentController) moveOrder(ctx context.Context, orderID primitive.ObjectID, status, note string, by primitive.ObjectID) error {
+	order, err := h.orders.FindByID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if !models.CanTransition(order.Status, status) {
+		log.Printf("Order %s stays %s after a payment change to %s", order.OrderNumber, order.Status, status)
+		return nil
+	}
+
+	err = updateOrderStock(ctx, h.inventory, h.cfg.ReservationTTL, order, status, by)
+	var outOfStock *outOfStockError
+	if errors.As(err, &outOfStock) {
+		log.Printf("Order %s was paid but %s is out of stock, it stays %s", order.OrderNumber, outOfStock.item.Name, order.Status)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return h.orders.UpdateStatus(ctx, order.ID, models.OrderStatusChange{
+		From:      order.Status,
+		To:        status,
+		Note:      note,
+		ChangedAt: primitive.NewDateTimeFromTime(time.Now()),
+		ChangedBy: by,
+	})
+}
@@ -2,220 +2,711 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fiber/apperrors"
 	"fiber/config"
+	"fiber/dto"
+	"fiber/middlewares"
 	"fiber/models"
+	"fiber/repositories"
+	"fiber/storage"
+	"fiber/utils"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func CreateProduct(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// ProductController handles product CRUD
+type ProductController struct {
+	cfg        *config.Config
+	products   repositories.ProductRepository
+	categories repositories.CategoryRepository
+	rates      repositories.ExchangeRateRepository
+	store      storage.Storage
+	transactor repositories.Transactor
+}
+
+var (
+	errCategoryDeleted  = errors.New("category of the product is deleted")
+	errCategoryInactive = errors.New("category of the product is inactive")
+)
+
+// productQuerySchema whitelists the filters, sorts and fields of GET /api/products
+var productQuerySchema = utils.QuerySchema{
+	Filters: map[string]utils.FilterField{
+		"category_id": {Type: utils.ObjectIDField, Ops: []string{"eq", "ne"}},
+		"created_by":  {Type: utils.ObjectIDField, Ops: []string{"eq", "ne"}},
+		"price":       {Type: utils.DecimalField, Ops: []string{"eq", "gt", "gte", "lt", "lte"}},
+		"created_at":  {Type: utils.TimeField, Ops: []string{"after", "before"}},
+		"updated_at":  {Type: utils.TimeField, Ops: []string{"after", "before"}},
+	},
+	Sorts:  []string{"name", "price", "created_at", "updated_at"},
+	Fields: []string{"name", "description", "price", "prices", "image", "images", "category_id", "category", "variants", "created_by", "created_at", "updated_at", "updated_by"},
+	Params: []string{"include_descendants", "currency"},
+	// Attribute filters are parsed by attributeFilter
+	ParamPrefixes: []string{attributePrefix},
+	// Prices are filtered and sorted by their amount in the shop currency
+	Paths: map[string]string{"price": "price.amount"},
+}
+
+// attributePrefix starts the query parameters filtering products by the attributes of
+// their variants: attr.size=M,L lists the products with a variant of size M or L and
+// attr.chest[gte]=40 the ones with a variant of chest 40 or more
+const attributePrefix = "attr."
+
+func NewProductController(cfg *config.Config, products repositories.ProductRepository, categories repositories.CategoryRepository, rates repositories.ExchangeRateRepository, store storage.Storage, transactor repositories.Transactor) *ProductController {
+	return &ProductController{cfg: cfg, products: products, categories: categories, rates: rates, store: store, transactor: transactor}
+}
 
-	productCollection := config.DB.Collection("products")
-	categoryCollection := config.DB.Collection("categories")
+// checkCategory ensures products are only attached to existing, active categories
+// and returns the attribute schema their variants must follow
+func (h *ProductController) checkCategory(ctx context.Context, categoryID primitive.ObjectID) ([]models.Attribute, error) {
+	category, err := h.categories.FindByID(ctx, categoryID)
+	if err != nil {
+		return nil, apperrors.BadRequest("Category not found")
+	}
+	if category.Status == models.CategoryInactive {
+		return nil, apperrors.BadRequest("Category is inactive")
+	}
+	schema, err := attributeSchema(ctx, h.categories, category)
+	if err != nil {
+		return nil, apperrors.Internal("Failed to fetch category").Wrap(err)
+	}
+	return schema, nil
+}
 
-	var product models.Product
-	if err := c.BodyParser(&product); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+// checkVariants validates the attributes of the variants against the schema, converting
+// them to the type of their attribute, and rejects variants with the same attributes
+func checkVariants(schema []models.Attribute, variants []models.Variant) error {
+	fields := map[string][]string{}
+	seen := map[string]int{}
+	for i, variant := range variants {
+		path := fmt.Sprintf("variants[%d].attributes", i)
+		declared := map[string]bool{}
+		for _, attribute := range schema {
+			declared[attribute.Name] = true
+			value, ok := variant.Attributes[attribute.Name]
+			if !ok || value == nil {
+				delete(variant.Attributes, attribute.Name)
+				if attribute.Required {
+					fields[path+"."+attribute.Name] = append(fields[path+"."+attribute.Name], attribute.Name+" is required")
+				}
+				continue
+			}
+			converted, err := attribute.Check(value)
+			if err != nil {
+				fields[path+"."+attribute.Name] = append(fields[path+"."+attribute.Name], err.Error())
+				continue
+			}
+			variant.Attributes[attribute.Name] = converted
+		}
+		for name := range variant.Attributes {
+			if !declared[name] {
+				fields[path+"."+name] = append(fields[path+"."+name], name+" is not an attribute of the category")
+			}
+		}
+
+		if len(variant.Attributes) == 0 {
+			continue
+		}
+		key := attributesKey(variant.Attributes)
+		if first, ok := seen[key]; ok {
+			fields[path] = append(fields[path], fmt.Sprintf("attributes are the same as the ones of variants[%d]", first))
+			continue
+		}
+		seen[key] = i
+	}
+	if len(fields) > 0 {
+		return apperrors.Validation(fields)
 	}
+	return nil
+}
 
-	// Convert ObjectIDs
-	// categoryObjectID, objectIdErr := utils.ConvertToObjectID(c.FormValue("category_id"), "category_id")
-	// if objectIdErr != nil {
-	// 	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": objectIdErr.Error()})
-	// }
+// attributesKey identifies a combination of attribute values
+func attributesKey(attributes map[string]interface{}) string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var key strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&key, "%s=%#v;", name, attributes[name])
+	}
+	return key.String()
+}
 
-	// fmt.Println(categoryObjectID)
+// checkSKUs ensures no other product has a variant with one of the SKUs
+func (h *ProductController) checkSKUs(ctx context.Context, productID primitive.ObjectID, variants []models.Variant) error {
+	for _, variant := range variants {
+		other, err := h.products.FindBySKU(ctx, variant.SKU)
+		if errors.Is(err, repositories.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return apperrors.Internal("Failed to check SKU").Wrap(err)
+		}
+		if other.ID != productID {
+			return apperrors.Conflict("SKU "+variant.SKU+" is already used by another product").WithCode("duplicate_sku").
+				With("sku", variant.SKU).
+				With("product_id", other.ID.Hex())
+		}
+	}
+	return nil
+}
 
-	var category models.Category
-	catErr := categoryCollection.FindOne(ctx, bson.M{"_id": product.CategoryID}).Decode(&category)
-	if catErr != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Category not found"})
+// skuTaken turns a write rejected by the unique index on variant SKUs into the
+// conflict of checkSKUs, for another product taking a SKU after the check
+func skuTaken(err error) error {
+	var duplicate *repositories.DuplicateError
+	if errors.As(err, &duplicate) && duplicate.Field == "variants.sku" {
+		return apperrors.Conflict("A SKU is already used by another product").WithCode("duplicate_sku").Wrap(err)
+	}
+	return err
+}
+
+// productVariant checks that sku selects what is sold of the product: one of its
+// variants, or the product itself with an empty SKU when it has none
+func productVariant(product *models.Product, sku string) error {
+	if len(product.Variants) == 0 {
+		if sku != "" {
+			return apperrors.BadRequest(product.Name+" has no variants").WithCode("variant_not_found").
+				With("product_id", product.ID.Hex()).
+				With("sku", sku)
+		}
+		return nil
+	}
+	if sku == "" {
+		return apperrors.BadRequest("A SKU is required to choose a variant of "+product.Name).WithCode("variant_required").
+			With("product_id", product.ID.Hex())
+	}
+	if product.Variant(sku) == nil {
+		return apperrors.BadRequest(product.Name+" has no variant "+sku).WithCode("variant_not_found").
+			With("product_id", product.ID.Hex()).
+			With("sku", sku)
+	}
+	return nil
+}
+
+// attributeFilter turns the attribute query parameters into one condition that a
+// single variant of each product must satisfy, nil when there are none
+func attributeFilter(c *fiber.Ctx) (*repositories.Condition, error) {
+	filter := bson.M{}
+	for key, value := range c.Queries() {
+		name, ok := strings.CutPrefix(key, attributePrefix)
+		if !ok {
+			continue
+		}
+		op := "eq"
+		if open := strings.Index(name, "["); open != -1 && strings.HasSuffix(name, "]") {
+			name, op = name[:open], name[open+1:len(name)-1]
+		}
+		if name == "" || strings.ContainsAny(name, ".$[]") {
+			return nil, fmt.Errorf("invalid attribute in %s", key)
+		}
+
+		path := "attributes." + name
+		ops, ok := filter[path].(bson.M)
+		if !ok {
+			ops = bson.M{}
+			filter[path] = ops
+		}
+		switch op {
+		case "eq":
+			values := bson.A{}
+			for _, v := range strings.Split(value, ",") {
+				values = append(values, attributeValues(v)...)
+			}
+			ops["$in"] = values
+		case "gt", "gte", "lt", "lte":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s: expected a number", key)
+			}
+			ops["$"+op] = n
+		default:
+			return nil, fmt.Errorf("operator %s is not allowed on attributes", op)
+		}
+	}
+	if len(filter) == 0 {
+		return nil, nil
+	}
+	return &repositories.Condition{Field: "variants", Op: "$elemMatch", Value: filter}, nil
+}
+
+// attributeValues returns the values a query value stands for, since the type of the
+// attribute is not known: attr.size=42 matches both the number and the text
+func attributeValues(value string) bson.A {
+	values := bson.A{value}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		values = append(values, n)
+	}
+	if value == "true" || value == "false" {
+		values = append(values, value == "true")
+	}
+	return values
+}
+
+// withDescendants widens a category_id filter to the subcategories of that category
+func (h *ProductController) withDescendants(ctx context.Context, query repositories.ListQuery) (repositories.ListQuery, error) {
+	for i, cond := range query.Conditions {
+		if cond.Field != "category_id" || cond.Op != "$eq" {
+			continue
+		}
+		categoryID := cond.Value.(primitive.ObjectID)
+		descendants, err := h.categories.FindDescendantIDs(ctx, categoryID)
+		if err != nil {
+			return query, err
+		}
+		ids := bson.A{categoryID}
+		for _, id := range descendants {
+			ids = append(ids, id)
+		}
+		query.Conditions[i] = repositories.Condition{Field: "category_id", Op: "$in", Value: ids}
+		return query, nil
+	}
+	return query, errors.New("include_descendants requires category_id")
+}
+
+func (h *ProductController) CreateProduct(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body, err := middlewares.Body[dto.ProductDTO](c)
+	if err != nil {
+		return err
+	}
+	product, err := body.ToModel(h.cfg.Currency)
+	if err != nil {
+		return apperrors.BadRequest(err.Error()).WithCode("invalid_price")
+	}
+
+	image, err := readImage(body.Image)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	schema, err := h.checkCategory(ctx, product.CategoryID)
+	if err != nil {
+		return err
+	}
+	if err := checkVariants(schema, product.Variants); err != nil {
+		return err
+	}
+	if err := h.checkSKUs(ctx, primitive.NilObjectID, product.Variants); err != nil {
+		return err
 	}
 
 	// Get userID from context
 	userID, ok := c.Locals("userID").(string)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User not authenticated"})
+		return apperrors.Unauthorized("User not authenticated")
 	}
 
-	// product.CategoryID = categoryObjectID
 	product.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
 	product.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
 	userObjectID, idErr := primitive.ObjectIDFromHex(userID)
 	if idErr != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+		return apperrors.BadRequest("Invalid user ID")
 	}
 	product.CreatedBy = userObjectID
 	product.UpdatedBy = userObjectID
 
-	_, err := productCollection.InsertOne(ctx, product)
+	if image != nil {
+		product.Image, product.Images, err = storeImage(ctx, h.store, "products", image)
+		if err != nil {
+			return apperrors.Internal("Failed to store image").Wrap(err)
+		}
+	}
+
+	err = h.products.Create(ctx, &product)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create product"})
+		if product.Image != "" {
+			utils.DeleteImage(ctx, h.store, product.Image)
+		}
+		// A duplicate name or SKU becomes a 409
+		if errors.Is(err, repositories.ErrDuplicate) {
+			return skuTaken(err)
+		}
+		return apperrors.Internal("Failed to create product").Wrap(err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Product created successfully"})
 }
 
-func GetProducts(c *fiber.Ctx) error {
+func (h *ProductController) GetProducts(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	productCollection := config.DB.Collection("products")
+	pageReq, err := utils.ParsePageRequest(c, h.cfg.DefaultPageSize, h.cfg.MaxPageSize)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
 
-	// MongoDB Aggregation Pipeline
-	pipeline := mongo.Pipeline{
-		{{"$lookup", bson.D{
-			{"from", "categories"},
-			{"localField", "category_id"},
-			{"foreignField", "_id"},
-			{"as", "category"},
-		}}},
-		{{"$lookup", bson.D{
-			{"from", "users"},
-			{"localField", "created_by"},
-			{"foreignField", "_id"},
-			{"as", "created_by"},
-		}}},
-		{{"$unwind", bson.D{{"path", "$category"}, {"preserveNullAndEmptyArrays", true}}}},
-		{{"$unwind", bson.D{{"path", "$created_by"}, {"preserveNullAndEmptyArrays", true}}}},
-		// Exclude the password field from the user object
-		{{"$project", bson.D{
-			{"created_by.password", 0}, // Exclude password from created_by (user)
-		}}},
+	query, err := utils.ParseListQuery(c, productQuerySchema)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+	attributes, err := attributeFilter(c)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+	if attributes != nil {
+		query.Conditions = append(query.Conditions, *attributes)
 	}
 
-	// Execute Aggregation Query
-	cursor, err := productCollection.Aggregate(ctx, pipeline)
+	converter, err := newPriceConverter(ctx, c, h.cfg, h.rates)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch products"})
+		return err
 	}
+	converter.selectPrices(&query)
 
-	var products []bson.M // Use bson.M to handle dynamic structure
-	if err := cursor.All(ctx, &products); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode products"})
+	if c.QueryBool("include_descendants") {
+		query, err = h.withDescendants(ctx, query)
+		if err != nil {
+			return apperrors.BadRequest(err.Error())
+		}
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": products})
+	products, err := h.products.FindAllDetailed(ctx, query, pageReq)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch products").Wrap(err)
+	}
+	if err := converter.convertDocuments(products.Items); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.NewPageResponse(c, pageReq, products))
 }
 
-func GetProduct(c *fiber.Ctx) error {
+func (h *ProductController) SearchProducts(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	productCollection := config.DB.Collection("products")
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		return apperrors.BadRequest("q is required")
+	}
 
-	// Get the ID from the URL parameters
-	id := c.Params("id")
+	pageReq, err := utils.ParsePageRequest(c, h.cfg.DefaultPageSize, h.cfg.MaxPageSize)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+	// Results are ordered by relevance, so only page based pagination is possible
+	if !pageReq.After.IsZero() {
+		return apperrors.BadRequest("cursor is not supported for search, use page instead")
+	}
 
-	// Convert the ID from string to ObjectID
-	productID, err := primitive.ObjectIDFromHex(id)
+	converter, err := newPriceConverter(ctx, c, h.cfg, h.rates)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product ID format"})
+		return err
 	}
 
-	// MongoDB Aggregation Pipeline
-	pipeline := mongo.Pipeline{
-		{{"$match", bson.D{{"_id", productID}}}},
-		{{"$lookup", bson.D{
-			{"from", "categories"},
-			{"localField", "category_id"},
-			{"foreignField", "_id"},
-			{"as", "category"},
-		}}},
-		{{"$lookup", bson.D{
-			{"from", "users"},
-			{"localField", "created_by"},
-			{"foreignField", "_id"},
-			{"as", "created_by"},
-		}}},
-		{{"$unwind", bson.D{{"path", "$category"}, {"preserveNullAndEmptyArrays", true}}}},
-		{{"$unwind", bson.D{{"path", "$created_by"}, {"preserveNullAndEmptyArrays", true}}}},
-		// Exclude the password field from the user object
-		{{"$project", bson.D{
-			{"category.password", 0},   // Exclude category-related fields if needed
-			{"created_by.password", 0}, // Exclude password from created_by (user)
-		}}},
+	products, err := h.products.Search(ctx, q, pageReq)
+	if err != nil {
+		return apperrors.Internal("Failed to search products").Wrap(err)
+	}
+	if err := converter.convertDocuments(products.Items); err != nil {
+		return err
+	}
+
+	// Highlight the matched terms in the searchable fields
+	terms, _ := repositories.SearchTerms(q)
+	for _, product := range products.Items {
+		name, _ := product["name"].(string)
+		description, _ := product["description"].(string)
+		product["highlights"] = fiber.Map{
+			"name":        utils.Highlight(name, terms),
+			"description": utils.Highlight(description, terms),
+		}
 	}
 
-	// Execute Aggregation Query
-	cursor, err := productCollection.Aggregate(ctx, pipeline)
+	return c.Status(fiber.StatusOK).JSON(utils.NewPageResponse(c, pageReq, products))
+}
+
+func (h *ProductController) GetProduct(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Get the ID from the URL parameters
+	id := c.Params("id")
+
+	// Convert the ID from string to ObjectID
+	productID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch product"})
+		return apperrors.BadRequest("Invalid product ID format")
 	}
 
-	var products []bson.M // Use bson.M to handle dynamic structure
-	if err := cursor.All(ctx, &products); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode product"})
+	converter, err := newPriceConverter(ctx, c, h.cfg, h.rates)
+	if err != nil {
+		return err
 	}
 
+	product, err := h.products.FindDetailedByID(ctx, productID)
 	// If no product is found
-	if len(products) == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Product not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch product").Wrap(err)
+	}
+	if err := converter.convertDocuments([]bson.M{product}); err != nil {
+		return err
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": products[0]})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": product})
 }
 
-func UpdateProduct(c *fiber.Ctx) error {
+func (h *ProductController) UpdateProduct(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	productCollection := config.DB.Collection("products")
-
 	// Get the ID from the URL parameters
 	id := c.Params("id")
 
 	// Convert the ID from string to ObjectID
 	productID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product ID format"})
+		return apperrors.BadRequest("Invalid product ID format")
 	}
 
 	// Get userID from context
 	userID, ok := c.Locals("userID").(string)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User not authenticated"})
+		return apperrors.Unauthorized("User not authenticated")
 	}
 
 	// Get the product from the request body
-	var product models.Product
-	if err := c.BodyParser(&product); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	body, err := middlewares.Body[dto.ProductDTO](c)
+	if err != nil {
+		return err
+	}
+	product, err := body.ToModel(h.cfg.Currency)
+	if err != nil {
+		return apperrors.BadRequest(err.Error()).WithCode("invalid_price")
+	}
+
+	image, err := readImage(body.Image)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	schema, err := h.checkCategory(ctx, product.CategoryID)
+	if err != nil {
+		return err
+	}
+	if err := checkVariants(schema, product.Variants); err != nil {
+		return err
+	}
+	if err := h.checkSKUs(ctx, productID, product.Variants); err != nil {
+		return err
+	}
+
+	existing, err := h.products.FindByID(ctx, productID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Product not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to update product").Wrap(err)
+	}
+	// Keep the current image unless a new one is uploaded, and the images of the variants kept
+	product.Image, product.Images = existing.Image, existing.Images
+	for i := range product.Variants {
+		if kept := existing.Variant(product.Variants[i].SKU); kept != nil {
+			product.Variants[i].Image, product.Variants[i].Images = kept.Image, kept.Images
+		}
+	}
+	if image != nil {
+		product.Image, product.Images, err = storeImage(ctx, h.store, "products", image)
+		if err != nil {
+			return apperrors.Internal("Failed to store image").Wrap(err)
+		}
 	}
 
 	userObjectID, idErr := primitive.ObjectIDFromHex(userID)
 	if idErr != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+		return apperrors.BadRequest("Invalid user ID")
 	}
 
 	// Set the updated fields
-	updateFields := bson.M{
-		"$set": bson.M{
-			"name":        product.Name,
-			"description": product.Description,
-			"price":       product.Price,
-			"image":       product.Image,
-			"category_id": product.CategoryID,
-			"updated_at":  primitive.NewDateTimeFromTime(time.Now()),
-			"updated_by":  userObjectID, // Use the logged-in user ID to track who updated
-		},
-	}
+	product.ID = productID
+	product.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	product.UpdatedBy = userObjectID // Use the logged-in user ID to track who updated
 
 	// Update the product
-	result, err := productCollection.UpdateOne(ctx, bson.M{"_id": productID}, updateFields)
+	err = h.products.Update(ctx, &product)
+	if err != nil && product.Image != existing.Image {
+		utils.DeleteImage(ctx, h.store, product.Image)
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Product not found")
+	}
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return skuTaken(err)
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update product"})
+		return apperrors.Internal("Failed to update product").Wrap(err)
 	}
 
-	if result.MatchedCount == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	// The replaced image is no longer referenced
+	if existing.Image != "" && product.Image != existing.Image {
+		utils.DeleteImage(ctx, h.store, existing.Image)
+	}
+	for _, variant := range existing.Variants {
+		if variant.Image != "" && product.Variant(variant.SKU) == nil {
+			utils.DeleteImage(ctx, h.store, variant.Image)
+		}
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Product updated successfully"})
 }
+
+// UploadVariantImage sets the image of a variant, replacing the previous one
+func (h *ProductController) UploadVariantImage(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	productID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid product ID format")
+	}
+
+	body, err := middlewares.Body[dto.VariantImageDTO](c)
+	if err != nil {
+		return err
+	}
+	if body.Image == nil {
+		return apperrors.BadRequest("An image is required")
+	}
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	product, err := h.products.FindByID(ctx, productID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Product not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch product").Wrap(err)
+	}
+	// The variants are copied so the stored product is not changed before the update
+	product.Variants = append([]models.Variant(nil), product.Variants...)
+	variant := product.Variant(c.Params("sku"))
+	if variant == nil {
+		return apperrors.NotFound("Variant not found")
+	}
+
+	image, err := readImage(body.Image)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+	previous := variant.Image
+	variant.Image, variant.Images, err = storeImage(ctx, h.store, "products", image)
+	if err != nil {
+		return apperrors.Internal("Failed to store image").Wrap(err)
+	}
+
+	product.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	product.UpdatedBy = userID
+	err = h.products.Update(ctx, product)
+	if err != nil {
+		utils.DeleteImage(ctx, h.store, variant.Image)
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Product not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to update product").Wrap(err)
+	}
+
+	if previous != "" {
+		utils.DeleteImage(ctx, h.store, previous)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Variant image updated successfully", "data": variant})
+}
+
+func (h *ProductController) DeleteProduct(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	productID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid product ID format")
+	}
+
+	// Get userID from context
+	userID, ok := c.Locals("userID").(string)
+	if !ok {
+		return apperrors.Unauthorized("User not authenticated")
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return apperrors.BadRequest("Invalid user ID")
+	}
+
+	// The product goes to the trash, its images are only removed when it is purged
+	err = h.products.SoftDelete(ctx, productID, userObjectID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Product not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to delete product").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Product deleted successfully"})
+}
+
+func (h *ProductController) RestoreProduct(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	productID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid product ID format")
+	}
+
+	err = h.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		product, err := h.products.FindDeletedByID(ctx, productID)
+		if err != nil {
+			return err
+		}
+		// A product cannot come back in a category that is in the trash or inactive
+		category, err := h.categories.FindByID(ctx, product.CategoryID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errCategoryDeleted
+		}
+		if err != nil {
+			return err
+		}
+		if category.Status == models.CategoryInactive {
+			return errCategoryInactive
+		}
+		return h.products.Restore(ctx, productID)
+	})
+	if errors.Is(err, errCategoryDeleted) {
+		return apperrors.Conflict("Category of the product is deleted, restore it first").WithCode("category_deleted")
+	}
+	if errors.Is(err, errCategoryInactive) {
+		return apperrors.Conflict("Category of the product is inactive, activate it first").WithCode("category_inactive")
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Deleted product not found")
+	}
+	// A live product took its name or one of its SKUs meanwhile
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return skuTaken(err)
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to restore product").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Product restored successfully"})
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"fiber/apperrors"
+	"fiber/config"
+	"fiber/models"
+	"fiber/repositories"
+	"fiber/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TrashController lists the soft deleted documents of every resource
+type TrashController struct {
+	cfg        *config.Config
+	users      repositories.UserRepository
+	categories repositories.CategoryRepository
+	products   repositories.ProductRepository
+}
+
+func NewTrashController(cfg *config.Config, users repositories.UserRepository, categories repositories.CategoryRepository, products repositories.ProductRepository) *TrashController {
+	return &TrashController{cfg: cfg, users: users, categories: categories, products: products}
+}
+
+// GetTrash returns one page of deleted documents of the resource given by ?type=
+func (h *TrashController) GetTrash(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pageReq, err := utils.ParsePageRequest(c, h.cfg.DefaultPageSize, h.cfg.MaxPageSize)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	var response utils.PageResponse
+	switch c.Query("type") {
+	case "products":
+		var page *repositories.Page[models.Product]
+		page, err = h.products.FindDeleted(ctx, pageReq)
+		if err == nil {
+			response = utils.NewPageResponse(c, pageReq, page)
+		}
+	case "categories":
+		var page *repositories.Page[models.Category]
+		page, err = h.categories.FindDeleted(ctx, pageReq)
+		if err == nil {
+			response = utils.NewPageResponse(c, pageReq, page)
+		}
+	case "users":
+		var page *repositories.Page[models.User]
+		page, err = h.users.FindDeleted(ctx, pageReq)
+		if err == nil {
+			response = utils.NewPageResponse(c, pageReq, page)
+		}
+	default:
+		return apperrors.BadRequest("type must be one of products, categories, users")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch trash").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
@@ -2,34 +2,170 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fiber/apperrors"
 	"fiber/config"
+	"fiber/dto"
+	"fiber/middlewares"
 	"fiber/models"
-	"log"
+	"fiber/repositories"
+	"fiber/utils"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func GetUsers(c *fiber.Ctx) error {
+// UserController handles user management
+type UserController struct {
+	cfg           *config.Config
+	users         repositories.UserRepository
+	sessions      repositories.SessionRepository
+	revokedTokens repositories.RevokedTokenRepository
+}
+
+func NewUserController(cfg *config.Config, users repositories.UserRepository, sessions repositories.SessionRepository, revokedTokens repositories.RevokedTokenRepository) *UserController {
+	return &UserController{cfg: cfg, users: users, sessions: sessions, revokedTokens: revokedTokens}
+}
+
+func (h *UserController) GetUsers(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pageReq, err := utils.ParsePageRequest(c, h.cfg.DefaultPageSize, h.cfg.MaxPageSize)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	// Find one page of users
+	users, err := h.users.FindAll(ctx, pageReq)
+	if err != nil {
+		return apperrors.Internal("Failed to fetch users").Wrap(err)
+	}
+
+	return c.JSON(utils.NewPageResponse(c, pageReq, users))
+}
+
+func (h *UserController) AssignRole(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid user ID")
+	}
+
+	body, err := middlewares.Body[dto.AssignRoleDTO](c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.users.FindByID(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("User not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch user").Wrap(err)
+	}
+
+	// Never leave the application without an admin
+	if user.Role == models.RoleAdmin && body.Role != models.RoleAdmin {
+		admins, err := h.users.CountByRole(ctx, models.RoleAdmin)
+		if err != nil {
+			return apperrors.Internal("Failed to update role").Wrap(err)
+		}
+		if admins <= 1 {
+			return apperrors.Conflict("Cannot remove the last admin")
+		}
+	}
+
+	if err := h.users.UpdateRole(ctx, userID, body.Role); err != nil {
+		return apperrors.Internal("Failed to update role")
+	}
+
+	// The new role is picked up by the user's next login or token refresh
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Role updated successfully"})
+}
+
+func (h *UserController) DeleteUser(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	usersCollection := config.DB.Collection("users")
+	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid user ID")
+	}
+
+	// Get userID from context
+	currentUserID, ok := c.Locals("userID").(string)
+	if !ok {
+		return apperrors.Unauthorized("User not authenticated")
+	}
+	adminID, err := primitive.ObjectIDFromHex(currentUserID)
+	if err != nil {
+		return apperrors.BadRequest("Invalid user ID")
+	}
+
+	user, err := h.users.FindByID(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("User not found")
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to fetch user").Wrap(err)
+	}
+
+	// Never leave the application without an admin
+	if user.Role == models.RoleAdmin {
+		admins, err := h.users.CountByRole(ctx, models.RoleAdmin)
+		if err != nil {
+			return apperrors.Internal("Failed to delete user").Wrap(err)
+		}
+		if admins <= 1 {
+			return apperrors.Conflict("Cannot remove the last admin")
+		}
+	}
 
-	// Find all users
-	cursor, err := usersCollection.Find(ctx, bson.M{})
+	// Deleted users can no longer log in or refresh their tokens
+	err = h.users.SoftDelete(ctx, userID, adminID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("User not found")
+	}
 	if err != nil {
-		log.Println("Error fetching users:", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Internal Server Error"})
+		return apperrors.Internal("Failed to delete user").Wrap(err)
 	}
-	defer cursor.Close(ctx)
 
-	var users []models.User
-	if err := cursor.All(ctx, &users); err != nil {
-		log.Println("Error decoding users:", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Internal Server Error"})
+	// Sign the user out everywhere, including access tokens that have not expired yet
+	sessions, err := h.sessions.RevokeUser(ctx, userID)
+	if err == nil {
+		err = denyAccessTokens(ctx, h.revokedTokens, sessions)
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to revoke sessions").Wrap(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User deleted successfully"})
+}
+
+func (h *UserController) RestoreUser(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid user ID")
+	}
+
+	err = h.users.Restore(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Deleted user not found")
+	}
+	// A live user took its email meanwhile
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return err
+	}
+	if err != nil {
+		return apperrors.Internal("Failed to restore user").Wrap(err)
 	}
 
-	return c.JSON(users)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User restored successfully"})
 }
@@ -0,0 +1,19 @@
+package dto
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type CartItemDTO struct {
+	ProductID string `json:"product_id" validate:"required,objectid"`
+	// SKU chooses the variant of products with variants
+	SKU      string `json:"sku" validate:"omitempty,sku"`
+	Quantity int    `json:"quantity" validate:"required,min=1,max=100"`
+}
+
+func (d CartItemDTO) Product() primitive.ObjectID {
+	productID, _ := primitive.ObjectIDFromHex(d.ProductID)
+	return productID
+}
+
+type CartQuantityDTO struct {
+	Quantity int `json:"quantity" validate:"required,min=1,max=100"`
+}
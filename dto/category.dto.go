@@ -1,7 +1,88 @@
 package dto
 
+import (
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AttributeDTO declares an attribute of the variants of the category's products
+type AttributeDTO struct {
+	Name     string   `json:"name" validate:"required,attribute_name"`
+	Type     string   `json:"type" validate:"required,oneof=text number boolean enum"`
+	Values   []string `json:"values" validate:"required_if=Type enum,max=100,unique,dive,required,max=50"`
+	Required bool     `json:"required"`
+}
+
+func (d AttributeDTO) ToModel() models.Attribute {
+	attribute := models.Attribute{Name: d.Name, Type: d.Type, Required: d.Required}
+	// Only enums have a list of values
+	if d.Type == models.AttributeEnum {
+		attribute.Values = d.Values
+	}
+	return attribute
+}
+
+// attributes maps the attribute schema of a category
+func attributes(dtos []AttributeDTO) []models.Attribute {
+	attributes := make([]models.Attribute, 0, len(dtos))
+	for _, d := range dtos {
+		attributes = append(attributes, d.ToModel())
+	}
+	return attributes
+}
+
 type CategoryDTO struct {
 	Name        string `json:"name" validate:"required,min=3"`
 	Description string `json:"description"`
 	Status      string `json:"status" validate:"required,oneof=ACTIVE INACTIVE"`
+	ParentID    string `json:"parent_id" validate:"omitempty,objectid"`
+	// Attributes are added to the ones inherited from the parent, or override them by name
+	Attributes []AttributeDTO `json:"attributes" validate:"max=30,unique=Name,dive"`
+}
+
+// ToModel maps the DTO to a new category; ancestors are filled in by the controller
+func (d CategoryDTO) ToModel() models.Category {
+	category := models.Category{
+		Name:        d.Name,
+		Description: d.Description,
+		Status:      d.Status,
+		Attributes:  attributes(d.Attributes),
+	}
+	if d.ParentID != "" {
+		parentID, _ := primitive.ObjectIDFromHex(d.ParentID)
+		category.ParentID = &parentID
+	}
+	return category
+}
+
+// UpdateCategoryDTO changes a category in place, use MoveCategoryDTO to change its parent
+type UpdateCategoryDTO struct {
+	Name        string         `json:"name" validate:"required,min=3"`
+	Description string         `json:"description"`
+	Status      string         `json:"status" validate:"required,oneof=ACTIVE INACTIVE"`
+	Attributes  []AttributeDTO `json:"attributes" validate:"max=30,unique=Name,dive"`
+}
+
+func (d UpdateCategoryDTO) ToModel() models.Category {
+	return models.Category{
+		Name:        d.Name,
+		Description: d.Description,
+		Status:      d.Status,
+		Attributes:  attributes(d.Attributes),
+	}
+}
+
+// MoveCategoryDTO moves a category below another one, or to the root when ParentID is empty
+type MoveCategoryDTO struct {
+	ParentID string `json:"parent_id" validate:"omitempty,objectid"`
+}
+
+// Parent returns the new parent, nil for the root
+func (d MoveCategoryDTO) Parent() *primitive.ObjectID {
+	if d.ParentID == "" {
+		return nil
+	}
+	parentID, _ := primitive.ObjectIDFromHex(d.ParentID)
+	return &parentID
 }
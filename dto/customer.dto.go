@@ -0,0 +1,59 @@
+package dto
+
+import "fiber/models"
+
+type AddressDTO struct {
+	Line1      string `json:"line1" validate:"required,max=200"`
+	Line2      string `json:"line2" validate:"max=200"`
+	City       string `json:"city" validate:"required,max=100"`
+	PostalCode string `json:"postal_code" validate:"required,max=20"`
+	Country    string `json:"country" validate:"required,len=2,alpha"`
+}
+
+type CustomerDTO struct {
+	Name    string      `json:"name" validate:"required,min=2"`
+	Email   string      `json:"email" validate:"required,email,unique_in_collection=customers.email"`
+	Phone   string      `json:"phone" validate:"max=32"`
+	Address *AddressDTO `json:"address"`
+}
+
+// ToModel maps the client editable fields; ownership and timestamps are set by the controller
+func (d CustomerDTO) ToModel() models.Customer {
+	return models.Customer{
+		Name:    d.Name,
+		Email:   d.Email,
+		Phone:   d.Phone,
+		Address: d.Address.toModel(),
+	}
+}
+
+// UpdateCustomerDTO is CustomerDTO without the uniqueness check, which would reject
+// the customer's own email; a taken email is reported by the unique index instead
+type UpdateCustomerDTO struct {
+	Name    string      `json:"name" validate:"required,min=2"`
+	Email   string      `json:"email" validate:"required,email"`
+	Phone   string      `json:"phone" validate:"max=32"`
+	Address *AddressDTO `json:"address"`
+}
+
+func (d UpdateCustomerDTO) ToModel() models.Customer {
+	return models.Customer{
+		Name:    d.Name,
+		Email:   d.Email,
+		Phone:   d.Phone,
+		Address: d.Address.toModel(),
+	}
+}
+
+func (d *AddressDTO) toModel() *models.Address {
+	if d == nil {
+		return nil
+	}
+	return &models.Address{
+		Line1:      d.Line1,
+		Line2:      d.Line2,
+		City:       d.City,
+		PostalCode: d.PostalCode,
+		Country:    d.Country,
+	}
+}
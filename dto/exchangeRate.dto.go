@@ -0,0 +1,8 @@
+package dto
+
+import "encoding/json"
+
+// ExchangeRateDTO sets how many units of a currency one unit of the shop currency buys
+type ExchangeRateDTO struct {
+	Rate json.Number `json:"rate" validate:"required,amount"`
+}
@@ -0,0 +1,19 @@
+package dto
+
+type StockMovementDTO struct {
+	// SKU names the variant, it is required for products with variants
+	SKU string `json:"sku" validate:"omitempty,sku"`
+	// Warehouse defaults to models.DefaultWarehouse
+	Warehouse string `json:"warehouse" validate:"omitempty,alphanum,max=50"`
+	// Quantity is added to the stock on hand; receipts and returns must be positive
+	Quantity int    `json:"quantity" validate:"required,min=-100000,max=100000"`
+	Type     string `json:"type" validate:"required,oneof=receipt adjustment return"`
+	Note     string `json:"note" validate:"max=500"`
+}
+
+type StockThresholdDTO struct {
+	SKU       string `json:"sku" validate:"omitempty,sku"`
+	Warehouse string `json:"warehouse" validate:"omitempty,alphanum,max=50"`
+	// Threshold is the available quantity at which the level is reported as low, 0 disables it
+	Threshold int `json:"threshold" validate:"min=0,max=100000"`
+}
@@ -0,0 +1,48 @@
+package dto
+
+import (
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OrderItemDTO struct {
+	ProductID string `json:"product_id" validate:"required,objectid"`
+	// SKU chooses the variant of products with variants
+	SKU      string `json:"sku" validate:"omitempty,sku"`
+	Quantity int    `json:"quantity" validate:"required,min=1,max=1000"`
+}
+
+// OrderDTO places an order, or replaces the customer and items of a pending one.
+// Names and prices are taken from the products, never from the client.
+type OrderDTO struct {
+	CustomerID string         `json:"customer_id" validate:"required,objectid"`
+	Items      []OrderItemDTO `json:"items" validate:"required,min=1,max=100,dive"`
+}
+
+func (d OrderDTO) Customer() primitive.ObjectID {
+	customerID, _ := primitive.ObjectIDFromHex(d.CustomerID)
+	return customerID
+}
+
+// Quantities returns the ordered quantity of each product or variant in the order of
+// first appearance, adding up lines that repeat one
+func (d OrderDTO) Quantities() ([]models.ItemKey, map[models.ItemKey]int) {
+	var keys []models.ItemKey
+	quantities := map[models.ItemKey]int{}
+	for _, item := range d.Items {
+		productID, _ := primitive.ObjectIDFromHex(item.ProductID)
+		key := models.ItemKey{ProductID: productID, SKU: item.SKU}
+		if _, ok := quantities[key]; !ok {
+			keys = append(keys, key)
+		}
+		quantities[key] += item.Quantity
+	}
+	return keys, quantities
+}
+
+// OrderStatusDTO moves an order to another status, see models.OrderTransitions
+type OrderStatusDTO struct {
+	Status string `json:"status" validate:"required,oneof=pending paid shipped delivered cancelled refunded"`
+	Note   string `json:"note" validate:"max=500"`
+}
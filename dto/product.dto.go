@@ -1,9 +1,95 @@
 package dto
 
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+
+	"fiber/models"
+	"fiber/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PriceDTO is the price of a product in another currency than the shop's
+type PriceDTO struct {
+	Currency string      `json:"currency" validate:"required,iso4217"`
+	Amount   json.Number `json:"amount" validate:"required,amount"`
+}
+
+// VariantDTO is a variant of a product; its attributes are checked against the
+// attribute schema of the category by the controller
+type VariantDTO struct {
+	SKU        string                 `json:"sku" validate:"required,sku"`
+	Attributes map[string]interface{} `json:"attributes" validate:"max=30"`
+	// Price overrides the price of the product when set
+	Price json.Number `json:"price" validate:"omitempty,amount"`
+}
+
 type ProductDTO struct {
-	Name        string  `json:"name" validate:"required"`
-	Description string  `json:"description" validate:"required"`
-	Price       float64 `json:"price" validate:"required"`
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description" validate:"required"`
+	// Amounts are decimal strings or JSON numbers, never decoded as floats
+	Price  json.Number `json:"price" validate:"required,amount"`
+	Prices []PriceDTO  `json:"prices" validate:"max=20,dive"`
+	// Variants replace the variants of the product, the images of the SKUs kept are kept too
+	Variants []VariantDTO `json:"variants" validate:"max=100,unique=SKU,dive"`
 	// Image       string  `json:"image" validate:"required"`
-	CategoryID string `json:"category_id" validate:"required"` // Ensure JSON key is lowercase
+	CategoryID string `json:"category_id" validate:"required,objectid"` // Ensure JSON key is lowercase
+	// Image can only be uploaded with multipart/form-data
+	Image *multipart.FileHeader `json:"-" form:"image" file:"max=5MB,types=image/jpeg image/png image/webp"`
+}
+
+// ToModel maps the client editable fields; ownership and timestamps are set by the controller.
+// The price is in currency, the shop currency, which the price list may not repeat.
+func (d ProductDTO) ToModel(currency string) (models.Product, error) {
+	categoryID, _ := primitive.ObjectIDFromHex(d.CategoryID)
+	price, err := money.Parse(d.Price.String(), currency)
+	if err != nil {
+		return models.Product{}, fmt.Errorf("price: %w", err)
+	}
+
+	prices := []money.Money{}
+	seen := map[string]bool{currency: true}
+	for i, p := range d.Prices {
+		if seen[p.Currency] {
+			return models.Product{}, fmt.Errorf("prices[%d]: %s is already priced", i, p.Currency)
+		}
+		seen[p.Currency] = true
+		amount, err := money.Parse(p.Amount.String(), p.Currency)
+		if err != nil {
+			return models.Product{}, fmt.Errorf("prices[%d]: %w", i, err)
+		}
+		prices = append(prices, amount)
+	}
+
+	variants := make([]models.Variant, 0, len(d.Variants))
+	for i, v := range d.Variants {
+		variant := models.Variant{SKU: v.SKU, Attributes: v.Attributes}
+		if variant.Attributes == nil {
+			variant.Attributes = map[string]interface{}{}
+		}
+		if v.Price != "" {
+			price, err := money.Parse(v.Price.String(), currency)
+			if err != nil {
+				return models.Product{}, fmt.Errorf("variants[%d].price: %w", i, err)
+			}
+			variant.Price = &price
+		}
+		variants = append(variants, variant)
+	}
+
+	return models.Product{
+		Name:        d.Name,
+		Description: d.Description,
+		Price:       price,
+		Prices:      prices,
+		CategoryID:  categoryID,
+		Variants:    variants,
+	}, nil
+}
+
+// VariantImageDTO uploads the image of a variant with multipart/form-data
+type VariantImageDTO struct {
+	Image *multipart.FileHeader `json:"-" form:"image" file:"max=5MB,types=image/jpeg image/png image/webp"`
 }
@@ -1,13 +1,55 @@
 package dto
 
+import (
+	"mime/multipart"
+
+	"fiber/models"
+)
+
 type UserRegisterDTO struct {
 	Name     string `json:"name" validate:"required,min=3"`
-	Email    string `json:"email" validate:"required,email"`
+	Email    string `json:"email" validate:"required,email,unique_in_collection=users.email"`
 	Password string `json:"password" validate:"required,min=6"`
-	Status   string `json:"status" validate:"required,oneof=ACTIVE INACTIVE"`
+	// Image can only be uploaded with multipart/form-data
+	Image *multipart.FileHeader `json:"-" form:"image" file:"max=5MB,types=image/jpeg image/png image/webp"`
+}
+
+// ToModel maps the DTO to a new user. Status and role are decided by the server
+// and the password still has to be hashed.
+func (d UserRegisterDTO) ToModel() models.User {
+	return models.User{
+		Name:     d.Name,
+		Email:    d.Email,
+		Password: d.Password,
+	}
 }
 
 type UserLoginDTO struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
 }
+
+type RefreshTokenDTO struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type AssignRoleDTO struct {
+	Role string `json:"role" validate:"required,oneof=admin editor viewer"`
+}
+
+type VerifyEmailDTO struct {
+	Token string `json:"token" validate:"required,max=200"`
+}
+
+type ResendVerificationDTO struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ForgotPasswordDTO struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordDTO struct {
+	Token    string `json:"token" validate:"required,max=200"`
+	Password string `json:"password" validate:"required,min=6"`
+}
@@ -0,0 +1,102 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPOptions configures an SMTPMailer
+type SMTPOptions struct {
+	Host string
+	// Port 465 uses implicit TLS, other ports upgrade with STARTTLS when the server offers it
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPMailer sends emails through an SMTP relay
+type SMTPMailer struct {
+	opts SMTPOptions
+	// sender is the envelope address taken from From, which may include a display name
+	sender string
+}
+
+func NewSMTPMailer(opts SMTPOptions) (*SMTPMailer, error) {
+	if opts.Host == "" {
+		return nil, errors.New("smtp host is required")
+	}
+	from, err := netmail.ParseAddress(opts.From)
+	if err != nil {
+		return nil, err
+	}
+	return &SMTPMailer{opts: opts, sender: from.Address}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	data, err := msg.Bytes(m.opts.From, time.Now())
+	if err != nil {
+		return err
+	}
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.opts.Host, strconv.Itoa(m.opts.Port)))
+	if err != nil {
+		return err
+	}
+	// The whole conversation is bound by the deadline of the context
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tlsConfig := &tls.Config{ServerName: m.opts.Host}
+	if m.opts.Port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, m.opts.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && m.opts.Port != 465 {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	// PlainAuth refuses to send the password over an unencrypted connection to a remote host
+	if m.opts.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.opts.Username, m.opts.Password, m.opts.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(m.sender); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"log"
+	"os"
+
+	"fiber/apperrors"
 	"fiber/config"
+	"fiber/mail"
+	"fiber/payments"
+	"fiber/repositories"
 	"fiber/routes"
+	"fiber/storage"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/swagger"
 
 	// docs are generated by Swag CLI, you have to import them.
@@ -13,13 +22,67 @@ import (
 )
 
 func main() {
-	app := fiber.New()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("❌ Invalid configuration: ", err)
+	}
+
+	app := fiber.New(fiber.Config{
+		// Leave room for 5MB image uploads and the other form fields
+		BodyLimit: 8 * 1024 * 1024,
+		// Every error is answered with an RFC 7807 problem document
+		ErrorHandler: apperrors.Handler,
+	})
+
+	// The request ID is echoed in the X-Request-ID header and in problem documents
+	app.Use(requestid.New())
 
-	config.ConnectDB()
+	db := config.ConnectDB(cfg)
 
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
-	routes.SetupRoutes(app)
+	if err := migrateMoney(cfg, db); err != nil {
+		log.Fatal("❌ Failed to migrate prices: ", err)
+	}
+	if err := dropLegacyIndexes(db); err != nil {
+		log.Fatal("❌ Failed to drop legacy indexes: ", err)
+	}
+
+	// Only development may run on a standalone MongoDB, without atomic multi-document changes
+	repos, err := repositories.NewMongoRepositories(db, cfg.IsDev())
+	if err != nil {
+		log.Fatal("❌ Failed to initialize repositories: ", err)
+	}
+
+	if err := seedAdmin(cfg, repos.Users); err != nil {
+		log.Fatal("❌ Failed to seed admin: ", err)
+	}
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatal("❌ Failed to initialize storage: ", err)
+	}
+
+	provider, err := payments.New(cfg)
+	if err != nil {
+		log.Fatal("❌ Failed to initialize payment provider: ", err)
+	}
+
+	mailer, err := mail.New(cfg)
+	if err != nil {
+		log.Fatal("❌ Failed to initialize mailer: ", err)
+	}
+
+	rateSource, err := newRateSource(cfg)
+	if err != nil {
+		log.Fatal("❌ Failed to initialize exchange rate source: ", err)
+	}
+
+	startTrashPurger(cfg, repos, store)
+	startReservationSweeper(cfg, repos)
+	startRateRefresher(cfg, repos, rateSource)
+
+	routes.SetupRoutes(app, cfg, repos, store, provider, mailer)
 
-	app.Listen(":4000")
+	log.Fatal(app.Listen(":" + cfg.Port))
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fiber/apperrors"
+	"fiber/config"
+	"fiber/mail"
+	"fiber/models"
+	"fiber/money"
+	"fiber/payments"
+	"fiber/repositories"
+	"fiber/routes"
+	"fiber/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	testAdminEmail    = "admin@example.com"
+	testAdminPassword = "secret1"
+)
+
+// testServer is the application wired to in-memory repositories, the fake payment
+// gateway and the memory mailer
+type testServer struct {
+	app      *fiber.App
+	cfg      *config.Config
+	repos    *repositories.Repositories
+	provider *payments.FakeProvider
+	mailer   *mail.MemoryMailer
+	// adminToken is the access token of the seeded admin
+	adminToken string
+}
+
+// newTestServer starts from the default configuration, ignoring any local .env,
+// and lets configure adjust it before the routes are set up
+func newTestServer(t *testing.T, configure func(cfg *config.Config)) *testServer {
+	t.Helper()
+
+	configFile := filepath.Join(t.TempDir(), "test.env")
+	if err := os.WriteFile(configFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load([]string{"-config", configFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AdminEmail, cfg.AdminPassword = testAdminEmail, testAdminPassword
+	if configure != nil {
+		configure(cfg)
+	}
+
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{
+		app:      fiber.New(fiber.Config{ErrorHandler: apperrors.Handler}),
+		cfg:      cfg,
+		repos:    repositories.NewMemoryRepositories(),
+		provider: payments.NewFakeProvider(cfg.PaymentWebhookSecret),
+		mailer:   mail.NewMemoryMailer(),
+	}
+	if err := seedAdmin(cfg, s.repos.Users); err != nil {
+		t.Fatal(err)
+	}
+	routes.SetupRoutes(s.app, cfg, s.repos, store, s.provider, s.mailer)
+
+	s.adminToken = s.login(t, testAdminEmail, testAdminPassword)
+	return s
+}
+
+// request sends a JSON request and decodes the JSON response
+func (s *testServer) request(t *testing.T, method, path, token string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return s.send(t, req)
+}
+
+func (s *testServer) send(t *testing.T, req *http.Request) (int, map[string]interface{}) {
+	t.Helper()
+
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && err != io.EOF {
+		t.Fatalf("%s %s: invalid JSON response: %v", req.Method, req.URL.Path, err)
+	}
+	return resp.StatusCode, result
+}
+
+// login returns the access token of the user
+func (s *testServer) login(t *testing.T, email, password string) string {
+	t.Helper()
+
+	status, body := s.request(t, "POST", "/api/auth/login", "", fiber.Map{"email": email, "password": password})
+	if status != fiber.StatusOK {
+		t.Fatalf("login %s: %d %v", email, status, body)
+	}
+	return body["token"].(string)
+}
+
+// register creates a viewer account and returns its access token
+func (s *testServer) register(t *testing.T, name, email string) string {
+	t.Helper()
+
+	status, body := s.request(t, "POST", "/api/auth/register", "", fiber.Map{"name": name, "email": email, "password": "secret1"})
+	if status != fiber.StatusCreated {
+		t.Fatalf("register %s: %d %v", email, status, body)
+	}
+	return s.login(t, email, "secret1")
+}
+
+// createProduct stores an active category and a product priced in the shop currency
+func (s *testServer) createProduct(t *testing.T, name, price string) *models.Product {
+	t.Helper()
+	ctx := context.Background()
+
+	category := models.Category{Name: name + " category", Status: models.CategoryActive}
+	if err := s.repos.Categories.Create(ctx, &category); err != nil {
+		t.Fatal(err)
+	}
+	amount, err := money.Parse(price, s.cfg.Currency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	product := models.Product{Name: name, Description: name, Price: amount, CategoryID: category.ID}
+	if err := s.repos.Products.Create(ctx, &product); err != nil {
+		t.Fatal(err)
+	}
+	return &product
+}
+
+// receiveStock adds quantity to the stock of the product
+func (s *testServer) receiveStock(t *testing.T, product *models.Product, quantity int) {
+	t.Helper()
+
+	path := "/api/inventory/" + product.ID.Hex() + "/movements"
+	status, body := s.request(t, "POST", path, s.adminToken, fiber.Map{"type": "receipt", "quantity": quantity})
+	if status != fiber.StatusCreated {
+		t.Fatalf("receive stock: %d %v", status, body)
+	}
+}
+
+// addToCart puts quantity of the product in the cart of the user
+func (s *testServer) addToCart(t *testing.T, token string, product *models.Product, quantity int) {
+	t.Helper()
+
+	body := fiber.Map{"product_id": product.ID.Hex(), "quantity": quantity}
+	if status, resp := s.request(t, "POST", "/api/cart/items", token, body); status != fiber.StatusOK {
+		t.Fatalf("add to cart: %d %v", status, resp)
+	}
+}
+
+// expectProblem fails unless the response is a problem with the status and code
+func expectProblem(t *testing.T, status int, body map[string]interface{}, wantStatus int, wantCode string) {
+	t.Helper()
+
+	if status != wantStatus || body["code"] != wantCode {
+		t.Fatalf("got %d %v, want %d with code %q", status, body, wantStatus, wantCode)
+	}
+}
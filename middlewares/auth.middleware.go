@@ -1,38 +1,76 @@
 package middlewares
 
 import (
+	"context"
 	"strings"
+	"time"
 
+	"fiber/apperrors"
 	"fiber/config" // Update with the correct import path
+	"fiber/repositories"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// AuthMiddleware validates the JWT token
-func AuthMiddleware(c *fiber.Ctx) error {
-	authHeader := c.Get("Authorization")
-	if authHeader == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Authorization token is required"})
+// AuthMiddleware validates the JWT token and rejects revoked tokens
+func AuthMiddleware(cfg *config.Config, revokedTokens repositories.RevokedTokenRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") == "" {
+			return apperrors.Unauthorized("Authorization token is required")
+		}
+		if err := authenticate(c, cfg, revokedTokens); err != nil {
+			return err
+		}
+
+		// Proceed to the next middleware or handler
+		return c.Next()
 	}
+}
+
+// OptionalAuthMiddleware authenticates the request when it carries a token and lets
+// anonymous requests through without a user in the context
+func OptionalAuthMiddleware(cfg *config.Config, revokedTokens repositories.RevokedTokenRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") != "" {
+			if err := authenticate(c, cfg, revokedTokens); err != nil {
+				return err
+			}
+		}
+		return c.Next()
+	}
+}
 
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+// authenticate verifies the bearer token and stores the user in the context
+func authenticate(c *fiber.Ctx, cfg *config.Config, revokedTokens repositories.RevokedTokenRepository) error {
+	tokenString := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
 	token, err := jwt.ParseWithClaims(tokenString, &config.AuthClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return config.JwtSecret, nil
-	})
+		return []byte(cfg.JwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token"})
+		return apperrors.Unauthorized("Invalid token")
 	}
 
 	claims, ok := token.Claims.(*config.AuthClaims)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token claims"})
+	if !ok || claims.ID == "" {
+		return apperrors.Unauthorized("Invalid token claims")
 	}
 
-	// Add email to context
-	c.Locals("userID", claims.UserId)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	// Proceed to the next middleware or handler
-	return c.Next()
+	revoked, err := revokedTokens.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return apperrors.Internal("Failed to verify token").Wrap(err)
+	}
+	if revoked {
+		return apperrors.Unauthorized("Token has been revoked")
+	}
+
+	// Add user ID, role and claims to context
+	c.Locals("userID", claims.UserId)
+	c.Locals("role", claims.Role)
+	c.Locals("claims", claims)
+	return nil
 }
@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"fiber/apperrors"
+	"fiber/policies"
+	"fiber/repositories"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OwnerLoader returns the owner of the resource with the given ID
+type OwnerLoader func(ctx context.Context, id primitive.ObjectID) (primitive.ObjectID, error)
+
+// Authorize checks the policy for the resource identified by the :id route
+// parameter. It must run after AuthMiddleware.
+func Authorize(policy policies.Policy, action string, loadOwner OwnerLoader) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(string)
+		if !ok {
+			return apperrors.Unauthorized("User not authenticated")
+		}
+		userObjectID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			return apperrors.BadRequest("Invalid user ID")
+		}
+		role, _ := c.Locals("role").(string)
+
+		resourceID, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return apperrors.BadRequest("Invalid " + policy.Resource + " ID")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		ownerID, err := loadOwner(ctx, resourceID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			name := strings.ToUpper(policy.Resource[:1]) + policy.Resource[1:]
+			return apperrors.NotFound(name + " not found")
+		}
+		if err != nil {
+			return apperrors.Internal("Failed to authorize request").Wrap(err)
+		}
+
+		subject := policies.Subject{UserID: userObjectID, Role: role}
+		var denied *policies.DeniedError
+		if err := policy.Authorize(subject, action, ownerID); errors.As(err, &denied) {
+			return apperrors.Forbidden("Forbidden").WithCode("policy_denied").
+				With("resource", denied.Resource).
+				With("action", denied.Action).
+				With("reason", denied.Reason)
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"fiber/apperrors"
+	"fiber/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequirePermission only lets through users whose role grants the permission.
+// It must run after AuthMiddleware.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, ok := c.Locals("role").(string)
+		if !ok {
+			return apperrors.Unauthorized("User not authenticated")
+		}
+
+		if !models.HasPermission(role, permission) {
+			return apperrors.Forbidden("Insufficient permissions").WithCode("permission_denied").With("permission", permission)
+		}
+
+		return c.Next()
+	}
+}
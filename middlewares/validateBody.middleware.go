@@ -1,76 +1,21 @@
 package middlewares
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"reflect"
-	"strconv"
 	"strings"
+	"time"
+
+	"fiber/apperrors"
+	"fiber/utils"
+	"fiber/validation"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// Global validator instance
-var validate = validator.New()
-
-// Function to get JSON field name from struct
-func getJSONFieldName[T any](fieldName string) string {
-	var t T
-	typ := reflect.TypeOf(t)
-	field, found := typ.FieldByName(fieldName)
-	if !found {
-		return fieldName
-	}
-	jsonTag := field.Tag.Get("json")
-	if jsonTag == "" {
-		return fieldName
-	}
-	return strings.Split(jsonTag, ",")[0]
-}
-
-// Parse form-data manually
-func parseFormData[T any](c *fiber.Ctx) (T, error) {
-	var body T
-	typ := reflect.TypeOf(body)
-	val := reflect.New(typ).Elem()
-
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		jsonTag := field.Tag.Get("json")
-		formKey := strings.Split(jsonTag, ",")[0] // Get JSON/form field name
-		formValue := c.FormValue(formKey)         // Get form-data value
-
-		if formValue != "" {
-			switch field.Type.Kind() {
-			case reflect.String:
-				val.Field(i).SetString(formValue)
-
-			case reflect.Int, reflect.Int64:
-				if intValue, err := strconv.ParseInt(formValue, 10, 64); err == nil {
-					val.Field(i).SetInt(intValue)
-				}
-
-			case reflect.Float64:
-				if floatValue, err := strconv.ParseFloat(formValue, 64); err == nil {
-					val.Field(i).SetFloat(floatValue)
-				}
-
-			case reflect.Struct:
-				// Handle MongoDB ObjectID conversion
-				if field.Type == reflect.TypeOf(primitive.ObjectID{}) {
-					objID, err := primitive.ObjectIDFromHex(formValue)
-					if err == nil {
-						val.Field(i).Set(reflect.ValueOf(objID))
-					}
-				}
-			}
-		}
-	}
-
-	return val.Interface().(T), nil
-}
-
 // Generic validation middleware
 func ValidateBody[T any]() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -79,25 +24,74 @@ func ValidateBody[T any]() fiber.Handler {
 
 		contentType := c.Get("Content-Type")
 		if strings.Contains(contentType, "application/json") {
-			err = c.BodyParser(&body)
-		} else if strings.Contains(contentType, "multipart/form-data") {
-			body, err = parseFormData[T](c)
+			err = decodeJSON(c.Body(), &body)
+		} else if strings.Contains(contentType, fiber.MIMEMultipartForm) || strings.Contains(contentType, fiber.MIMEApplicationForm) {
+			err = utils.BindForm(c, &body)
 		}
 
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		// Messages follow Accept-Language, falling back to English
+		locale := c.AcceptsLanguages(validation.Locales()...)
+		if locale == "" {
+			locale = validation.DefaultLocale
 		}
 
-		if err := validate.Struct(body); err != nil {
-			errors := make(map[string]string)
-			for _, e := range err.(validator.ValidationErrors) {
-				jsonField := getJSONFieldName[T](e.StructField())
-				errors[jsonField] = fmt.Sprintf("%s is required", jsonField)
+		var unknown *unknownFieldError
+		var bindErrors utils.BindErrors
+		switch {
+		case errors.As(err, &unknown):
+			return apperrors.BadRequest("Unknown field "+unknown.field).WithCode("unknown_field").With("field", unknown.field)
+		case errors.As(err, &bindErrors):
+			fields := map[string][]string{}
+			for _, e := range bindErrors {
+				fields[e.Path] = append(fields[e.Path], validation.Message(locale, e.Rule, e.Kind, e.Field, e.Param))
 			}
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errors})
+			c.Set(fiber.HeaderContentLanguage, locale)
+			return apperrors.Validation(fields)
+		case err != nil:
+			return apperrors.BadRequest("Invalid request body")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if fields := validation.Struct(ctx, body, locale); fields != nil {
+			c.Set(fiber.HeaderContentLanguage, locale)
+			return apperrors.Validation(fields)
 		}
 
 		c.Locals("body", body)
 		return c.Next()
 	}
 }
+
+// Body returns the DTO validated by ValidateBody[T]
+func Body[T any](c *fiber.Ctx) (T, error) {
+	body, ok := c.Locals("body").(T)
+	if !ok {
+		return body, apperrors.BadRequest("Invalid input")
+	}
+	return body, nil
+}
+
+type unknownFieldError struct {
+	field string
+}
+
+func (e *unknownFieldError) Error() string {
+	return "unknown field " + e.field
+}
+
+// decodeJSON decodes a single JSON value, rejecting fields the DTO does not declare
+// so clients cannot set server-managed fields
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err != nil && strings.HasPrefix(err.Error(), "json: unknown field ") {
+		return &unknownFieldError{field: strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)}
+	}
+	if err == nil && decoder.More() {
+		return fmt.Errorf("unexpected data after the JSON body")
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"fiber/config"
+	"fiber/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// numeric matches the amounts stored as numbers before prices became decimal money
+var numeric = bson.M{"$type": bson.A{"double", "int", "long"}}
+
+// moneyMigration converts the amounts of one collection; convert returns the
+// update of a document
+type moneyMigration struct {
+	collection string
+	filter     bson.M
+	convert    func(doc bson.M, currency string) bson.M
+}
+
+var moneyMigrations = []moneyMigration{
+	{
+		collection: "products",
+		filter:     bson.M{"price": numeric},
+		convert: func(doc bson.M, currency string) bson.M {
+			return bson.M{"$set": bson.M{"price": floatMoney(doc["price"], currency)}}
+		},
+	},
+	{
+		collection: "orders",
+		filter:     bson.M{"$or": bson.A{bson.M{"total": numeric}, bson.M{"items.unit_price": numeric}, bson.M{"items.line_total": numeric}}},
+		convert: func(doc bson.M, currency string) bson.M {
+			return bson.M{"$set": bson.M{
+				"total": floatMoney(doc["total"], currency),
+				"items": convertItems(doc["items"], currency, "unit_price", "line_total"),
+			}}
+		},
+	},
+	{
+		collection: "carts",
+		filter:     bson.M{"items.unit_price": numeric},
+		convert: func(doc bson.M, currency string) bson.M {
+			return bson.M{"$set": bson.M{"items": convertItems(doc["items"], currency, "unit_price")}}
+		},
+	},
+	{
+		// The currency of payments moves into their amount
+		collection: "payments",
+		filter:     bson.M{"amount": numeric},
+		convert: func(doc bson.M, currency string) bson.M {
+			if c, ok := doc["currency"].(string); ok && c != "" {
+				currency = c
+			}
+			return bson.M{
+				"$set":   bson.M{"amount": floatMoney(doc["amount"], currency)},
+				"$unset": bson.M{"currency": ""},
+			}
+		},
+	},
+}
+
+// migrateMoney converts the amounts stored as floating point numbers into decimal
+// money of the shop currency. Converted documents no longer match the filters, so
+// running it again does nothing.
+func migrateMoney(cfg *config.Config, db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	for _, migration := range moneyMigrations {
+		collection := db.Collection(migration.collection)
+		cursor, err := collection.Find(ctx, migration.filter)
+		if err != nil {
+			return fmt.Errorf("%s: %w", migration.collection, err)
+		}
+
+		converted := 0
+		for cursor.Next(ctx) {
+			var doc bson.M
+			if err := cursor.Decode(&doc); err != nil {
+				cursor.Close(ctx)
+				return fmt.Errorf("%s: %w", migration.collection, err)
+			}
+			if _, err := collection.UpdateOne(ctx, bson.M{"_id": doc["_id"]}, migration.convert(doc, cfg.Currency)); err != nil {
+				cursor.Close(ctx)
+				return fmt.Errorf("%s: %w", migration.collection, err)
+			}
+			converted++
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", migration.collection, err)
+		}
+
+		if converted > 0 {
+			log.Printf("💱 Converted the amounts of %d %s to decimal money", converted, migration.collection)
+		}
+	}
+	return nil
+}
+
+// floatMoney converts a numeric amount, leaving amounts already converted as they
+// are. Amounts that cannot be money, such as NaN, are kept and logged.
+func floatMoney(value interface{}, currency string) interface{} {
+	var amount float64
+	switch n := value.(type) {
+	case float64:
+		amount = n
+	case int32:
+		amount = float64(n)
+	case int64:
+		amount = float64(n)
+	default:
+		return value
+	}
+	m, err := money.FromFloat(amount, currency)
+	if err != nil {
+		log.Printf("Keeping amount %v, it cannot be converted to money: %v", value, err)
+		return value
+	}
+	return m
+}
+
+// convertItems converts the amount fields of each item of an array
+func convertItems(value interface{}, currency string, fields ...string) interface{} {
+	items, ok := value.(bson.A)
+	if !ok {
+		return value
+	}
+	for _, item := range items {
+		if doc, ok := item.(bson.M); ok {
+			for _, field := range fields {
+				if amount, ok := doc[field]; ok {
+					doc[field] = floatMoney(amount, currency)
+				}
+			}
+		}
+	}
+	return items
+}
+
+// legacyIndexes are indexes replaced by wider ones, which they would keep from
+// being used or make redundant: stock levels became unique per variant, the names
+// and emails of documents in the trash no longer block live ones, and variant
+// SKUs became unique. category_name_1 was created on a field categories never
+// had, so every category counted as a null name and only one could be stored.
+var legacyIndexes = map[string][]string{
+	"stock_levels": {"product_id_1_warehouse_1"},
+	"users":        {"email_1"},
+	"products":     {"name_1", "variants.sku_1"},
+	"categories":   {"name_1", "category_name_1"},
+}
+
+// dropLegacyIndexes drops the legacy indexes that still exist
+func dropLegacyIndexes(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for collection, names := range legacyIndexes {
+		for _, name := range names {
+			_, err := db.Collection(collection).Indexes().DropOne(ctx, name)
+			var cmdErr mongo.CommandError
+			if errors.As(err, &cmdErr) && (cmdErr.Name == "IndexNotFound" || cmdErr.Name == "NamespaceNotFound") {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", collection, err)
+			}
+			log.Printf("🧹 Dropped index %s of %s", name, collection)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Attribute types
+const (
+	AttributeText    = "text"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+	AttributeEnum    = "enum"
+)
+
+// Attribute describes a property of the variants of the products of a category,
+// such as the sizes of apparel. Values lists the choices of an enum attribute.
+type Attribute struct {
+	Name     string   `bson:"name" json:"name"`
+	Type     string   `bson:"type" json:"type"`
+	Values   []string `bson:"values,omitempty" json:"values,omitempty"`
+	Required bool     `bson:"required" json:"required"`
+}
+
+// Check returns the value converted to the type of the attribute, or an error
+// explaining why it does not fit. Numbers and booleans may be given as text, as
+// they are by forms.
+func (a Attribute) Check(value interface{}) (interface{}, error) {
+	switch a.Type {
+	case AttributeText:
+		if s, ok := value.(string); ok && len(s) <= 200 {
+			return s, nil
+		}
+		return nil, fmt.Errorf("%s must be a text of at most 200 characters", a.Name)
+	case AttributeNumber:
+		if s, ok := value.(string); ok {
+			if n, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				value = n
+			}
+		}
+		if n, ok := value.(float64); ok && !math.IsNaN(n) && !math.IsInf(n, 0) {
+			return n, nil
+		}
+		return nil, fmt.Errorf("%s must be a number", a.Name)
+	case AttributeBoolean:
+		if s, ok := value.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				value = b
+			}
+		}
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("%s must be true or false", a.Name)
+	case AttributeEnum:
+		if s, ok := value.(string); ok {
+			for _, v := range a.Values {
+				if v == s {
+					return s, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("%s must be one of %s", a.Name, strings.Join(a.Values, ", "))
+	}
+	return nil, fmt.Errorf("%s has an unknown type %q", a.Name, a.Type)
+}
+
+// AttributeSchema merges the attributes of a category with the ones inherited from
+// its ancestors, given from the root down. A category overrides an inherited
+// attribute by declaring one with the same name.
+func AttributeSchema(chain ...*Category) []Attribute {
+	schema := []Attribute{}
+	index := map[string]int{}
+	for _, category := range chain {
+		for _, attribute := range category.Attributes {
+			if i, ok := index[attribute.Name]; ok {
+				schema[i] = attribute
+				continue
+			}
+			index[attribute.Name] = len(schema)
+			schema = append(schema, attribute)
+		}
+	}
+	return schema
+}
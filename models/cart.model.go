@@ -0,0 +1,68 @@
+package models
+
+import (
+	"fiber/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	CartPriceChanged   = "price_changed"
+	CartProductRemoved = "product_removed"
+	// CartVariantRemoved is reported when the variant is gone or the product is now
+	// sold by variant, the item must be chosen again
+	CartVariantRemoved = "variant_removed"
+)
+
+// CartItem is a product, or a variant of it, in a cart with the price it had when
+// the cart was last priced
+type CartItem struct {
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
+	SKU       string             `bson:"sku,omitempty" json:"sku,omitempty"`
+	Name      string             `bson:"name" json:"name"`
+	UnitPrice money.Money        `bson:"unit_price" json:"unit_price"`
+	Quantity  int                `bson:"quantity" json:"quantity"`
+}
+
+// Cart belongs either to a user or, before login, to the holder of a cart token.
+// Anonymous carts expire, user carts are kept until checkout.
+type Cart struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID    *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	TokenHash string              `bson:"token_hash,omitempty" json:"-"`
+	Items     []CartItem          `bson:"items" json:"items"`
+	CreatedAt primitive.DateTime  `bson:"created_at" json:"created_at"`
+	UpdatedAt primitive.DateTime  `bson:"updated_at" json:"updated_at"`
+	ExpiresAt *primitive.DateTime `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+}
+
+// CartChange tells the client that an item changed since it was put in the cart
+type CartChange struct {
+	ProductID primitive.ObjectID `json:"product_id"`
+	SKU       string             `json:"sku,omitempty"`
+	Name      string             `json:"name"`
+	Type      string             `json:"type"`
+	OldPrice  money.Money        `json:"old_price"`
+	NewPrice  *money.Money       `json:"new_price,omitempty"`
+}
+
+// Item returns the line of the product or variant, or nil
+func (c *Cart) Item(productID primitive.ObjectID, sku string) *CartItem {
+	for i := range c.Items {
+		if c.Items[i].ProductID == productID && c.Items[i].SKU == sku {
+			return &c.Items[i]
+		}
+	}
+	return nil
+}
+
+// RemoveItem removes the line of the product or variant and reports whether it was in the cart
+func (c *Cart) RemoveItem(productID primitive.ObjectID, sku string) bool {
+	for i := range c.Items {
+		if c.Items[i].ProductID == productID && c.Items[i].SKU == sku {
+			c.Items = append(c.Items[:i], c.Items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
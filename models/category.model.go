@@ -2,9 +2,38 @@ package models
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+const (
+	CategoryActive   = "ACTIVE"
+	CategoryInactive = "INACTIVE"
+)
+
 type Category struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name        string             `bson:"name" json:"name"`
 	Description string             `bson:"description" json:"description"`
 	Status      string             `bson:"status" json:"status"`
+
+	// ParentID is nil for root categories. Ancestors is the materialized path
+	// from the root down to the parent, so a subtree is found with one query.
+	ParentID  *primitive.ObjectID  `bson:"parent_id,omitempty" json:"parent_id"`
+	Ancestors []primitive.ObjectID `bson:"ancestors,omitempty" json:"ancestors"`
+
+	// Attributes is the schema of the variants of the products of the category,
+	// subcategories inherit it
+	Attributes []Attribute `bson:"attributes,omitempty" json:"attributes,omitempty"`
+
+	SoftDelete `bson:",inline"`
+}
+
+// CategoryNode is a category with its children, as returned by GET /api/categories/tree
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// ChildAncestors returns the materialized path of a child of this category
+func (c *Category) ChildAncestors() []primitive.ObjectID {
+	path := make([]primitive.ObjectID, 0, len(c.Ancestors)+1)
+	path = append(path, c.Ancestors...)
+	return append(path, c.ID)
 }
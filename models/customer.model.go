@@ -0,0 +1,24 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Address is a postal address of a customer; Country is an ISO 3166-1 alpha-2 code
+type Address struct {
+	Line1      string `bson:"line1" json:"line1"`
+	Line2      string `bson:"line2,omitempty" json:"line2,omitempty"`
+	City       string `bson:"city" json:"city"`
+	PostalCode string `bson:"postal_code" json:"postal_code"`
+	Country    string `bson:"country" json:"country"`
+}
+
+type Customer struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	Email     string             `bson:"email" json:"email"`
+	Phone     string             `bson:"phone,omitempty" json:"phone,omitempty"`
+	Address   *Address           `bson:"address,omitempty" json:"address,omitempty"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+	UpdatedAt primitive.DateTime `bson:"updated_at" json:"updated_at"`
+	CreatedBy primitive.ObjectID `bson:"created_by" json:"created_by"`
+	UpdatedBy primitive.ObjectID `bson:"updated_by" json:"updated_by"`
+}
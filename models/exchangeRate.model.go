@@ -0,0 +1,18 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// ExchangeRateManual is the source of the rates set through the API
+const ExchangeRateManual = "manual"
+
+// ExchangeRate is the number of units of Currency that one unit of Base buys
+type ExchangeRate struct {
+	ID       primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Base     string               `bson:"base" json:"base"`
+	Currency string               `bson:"currency" json:"currency"`
+	Rate     primitive.Decimal128 `bson:"rate" json:"rate"`
+	// Source is manual or the name of the rate source it was pulled from
+	Source    string              `bson:"source" json:"source"`
+	UpdatedAt primitive.DateTime  `bson:"updated_at" json:"updated_at"`
+	UpdatedBy *primitive.ObjectID `bson:"updated_by,omitempty" json:"updated_by,omitempty"`
+}
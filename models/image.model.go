@@ -0,0 +1,8 @@
+package models
+
+// ImageVariants holds the URLs of the generated sizes of an uploaded image
+type ImageVariants struct {
+	Thumb    string `bson:"thumb" json:"thumb"`
+	Medium   string `bson:"medium" json:"medium"`
+	Original string `bson:"original" json:"original"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// DefaultWarehouse holds the stock of shops with a single location
+const DefaultWarehouse = "main"
+
+// Stock movement types
+const (
+	MovementReceipt     = "receipt"
+	MovementAdjustment  = "adjustment"
+	MovementReturn      = "return"
+	MovementSale        = "sale"
+	MovementReservation = "reservation"
+	MovementRelease     = "release"
+)
+
+const (
+	ReservationActive    = "active"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+)
+
+// StockLevel is the stock of a product, or of one of its variants, in one warehouse.
+// Reserved units are held for pending orders and cannot be sold to anyone else.
+type StockLevel struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
+	// SKU is the variant the stock belongs to, empty for products without variants
+	SKU       string `bson:"sku,omitempty" json:"sku,omitempty"`
+	Warehouse string `bson:"warehouse" json:"warehouse"`
+	OnHand    int    `bson:"on_hand" json:"on_hand"`
+	Reserved  int    `bson:"reserved" json:"reserved"`
+	// LowStockThreshold flags the level as low once the available quantity drops
+	// to it; 0 disables the alert
+	LowStockThreshold int                `bson:"low_stock_threshold" json:"low_stock_threshold"`
+	UpdatedAt         primitive.DateTime `bson:"updated_at" json:"updated_at"`
+}
+
+// Available is the quantity that can still be reserved or sold
+func (s StockLevel) Available() int {
+	return s.OnHand - s.Reserved
+}
+
+// IsLow reports whether the available quantity reached the low stock threshold
+func (s StockLevel) IsLow() bool {
+	return s.LowStockThreshold > 0 && s.Available() <= s.LowStockThreshold
+}
+
+// StockMovement is an entry of the stock ledger. Every change of a stock level is
+// recorded with the change of the quantity on hand and of the reserved quantity.
+type StockMovement struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	ProductID      primitive.ObjectID  `bson:"product_id" json:"product_id"`
+	SKU            string              `bson:"sku,omitempty" json:"sku,omitempty"`
+	Warehouse      string              `bson:"warehouse" json:"warehouse"`
+	Type           string              `bson:"type" json:"type"`
+	Quantity       int                 `bson:"quantity" json:"quantity"`
+	ReservedChange int                 `bson:"reserved_change" json:"reserved_change"`
+	OrderID        *primitive.ObjectID `bson:"order_id,omitempty" json:"order_id,omitempty"`
+	Note           string              `bson:"note,omitempty" json:"note,omitempty"`
+	CreatedAt      primitive.DateTime  `bson:"created_at" json:"created_at"`
+	// CreatedBy is nil for changes made by the system, such as expired reservations
+	CreatedBy *primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+}
+
+// Reservation holds stock for a pending order until it is paid, cancelled or expires
+type Reservation struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrderID   primitive.ObjectID `bson:"order_id" json:"order_id"`
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
+	SKU       string             `bson:"sku,omitempty" json:"sku,omitempty"`
+	Warehouse string             `bson:"warehouse" json:"warehouse"`
+	Quantity  int                `bson:"quantity" json:"quantity"`
+	Status    string             `bson:"status" json:"status"`
+	ExpiresAt primitive.DateTime `bson:"expires_at" json:"expires_at"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+}
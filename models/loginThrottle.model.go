@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Scopes of the failed login counters
+const (
+	ThrottleAccount = "account"
+	ThrottleIP      = "ip"
+)
+
+// LoginThrottle counts the failed logins of an email address or of an IP address.
+// Counters exist for unknown addresses too, so they do not reveal which accounts exist.
+type LoginThrottle struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Scope    string             `bson:"scope" json:"scope"`
+	Key      string             `bson:"key" json:"key"`
+	Failures int                `bson:"failures" json:"failures"`
+	// RetryAt ends the backoff after the last failure, LockedUntil ends a lockout
+	RetryAt     *primitive.DateTime `bson:"retry_at,omitempty" json:"retry_at,omitempty"`
+	LockedUntil *primitive.DateTime `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	UpdatedAt   primitive.DateTime  `bson:"updated_at" json:"updated_at"`
+	// ExpiresAt is when the failures are forgotten
+	ExpiresAt primitive.DateTime `bson:"expires_at" json:"expires_at"`
+}
+
+// BlockedUntil returns when attempts are allowed again, or false if they are now
+func (t *LoginThrottle) BlockedUntil(now time.Time) (time.Time, bool) {
+	var until time.Time
+	for _, at := range []*primitive.DateTime{t.RetryAt, t.LockedUntil} {
+		if at != nil && at.Time().After(until) {
+			until = at.Time()
+		}
+	}
+	return until, until.After(now)
+}
+
+// Types of lockout events
+const (
+	LockoutLocked   = "locked"
+	LockoutUnlocked = "unlocked"
+)
+
+// LockoutEvent is the audit record of a lockout starting or being lifted by an admin
+type LockoutEvent struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type  string             `bson:"type" json:"type"`
+	Scope string             `bson:"scope" json:"scope"`
+	Key   string             `bson:"key" json:"key"`
+	// UserID is the account whose email is the key, if there is one
+	UserID *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	// IP is the address of the attempt that caused the lockout
+	IP          string              `bson:"ip,omitempty" json:"ip,omitempty"`
+	Failures    int                 `bson:"failures,omitempty" json:"failures,omitempty"`
+	LockedUntil *primitive.DateTime `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	// ActorID is the admin who lifted the lockout
+	ActorID   *primitive.ObjectID `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	CreatedAt primitive.DateTime  `bson:"created_at" json:"created_at"`
+}
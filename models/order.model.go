@@ -0,0 +1,92 @@
+package models
+
+import (
+	"fmt"
+
+	"fiber/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	OrderPending       = "pending"
+	OrderPaymentFailed = "payment_failed"
+	OrderPaid          = "paid"
+	OrderShipped       = "shipped"
+	OrderDelivered     = "delivered"
+	OrderCancelled     = "cancelled"
+	OrderRefunded      = "refunded"
+)
+
+// OrderTransitions lists the statuses an order can move to from each status.
+// Cancelled and refunded orders are final; an order whose payment failed can
+// still be paid with another attempt.
+var OrderTransitions = map[string][]string{
+	OrderPending:       {OrderPaid, OrderPaymentFailed, OrderCancelled},
+	OrderPaymentFailed: {OrderPaid, OrderCancelled},
+	OrderPaid:          {OrderShipped, OrderRefunded},
+	OrderShipped:       {OrderDelivered},
+	OrderDelivered:     {OrderRefunded},
+	OrderCancelled:     {},
+	OrderRefunded:      {},
+}
+
+// CanTransition reports whether an order in status from can move to status to
+func CanTransition(from, to string) bool {
+	for _, status := range OrderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderNumber formats the sequence number of an order for display, e.g. ORD-000042
+func OrderNumber(seq int64) string {
+	return fmt.Sprintf("ORD-%06d", seq)
+}
+
+// OrderItem is a line of an order. Name and UnitPrice are copied from the product
+// when the order is placed, so later product changes do not alter past orders.
+type OrderItem struct {
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
+	// SKU is the variant ordered, empty for products without variants
+	SKU       string      `bson:"sku,omitempty" json:"sku,omitempty"`
+	Name      string      `bson:"name" json:"name"`
+	UnitPrice money.Money `bson:"unit_price" json:"unit_price"`
+	Quantity  int         `bson:"quantity" json:"quantity"`
+	LineTotal money.Money `bson:"line_total" json:"line_total"`
+}
+
+// ItemKey identifies what a line of an order or a cart sells: a product, or one of its variants
+type ItemKey struct {
+	ProductID primitive.ObjectID
+	SKU       string
+}
+
+func (i OrderItem) Key() ItemKey {
+	return ItemKey{ProductID: i.ProductID, SKU: i.SKU}
+}
+
+// OrderStatusChange records one transition of an order
+type OrderStatusChange struct {
+	From      string             `bson:"from,omitempty" json:"from,omitempty"`
+	To        string             `bson:"to" json:"to"`
+	Note      string             `bson:"note,omitempty" json:"note,omitempty"`
+	ChangedAt primitive.DateTime `bson:"changed_at" json:"changed_at"`
+	ChangedBy primitive.ObjectID `bson:"changed_by" json:"changed_by"`
+}
+
+type Order struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	OrderNumber   string              `bson:"order_number" json:"order_number"`
+	CustomerID    primitive.ObjectID  `bson:"customer_id" json:"customer_id"`
+	Items         []OrderItem         `bson:"items" json:"items"`
+	Total         money.Money         `bson:"total" json:"total"`
+	Status        string              `bson:"status" json:"status"`
+	StatusHistory []OrderStatusChange `bson:"status_history" json:"status_history"`
+	CreatedAt     primitive.DateTime  `bson:"created_at" json:"created_at"`
+	UpdatedAt     primitive.DateTime  `bson:"updated_at" json:"updated_at"`
+	CreatedBy     primitive.ObjectID  `bson:"created_by" json:"created_by"`
+	UpdatedBy     primitive.ObjectID  `bson:"updated_by" json:"updated_by"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fiber/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PaymentPending   = "pending"
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+	PaymentRefunded  = "refunded"
+)
+
+// Payment is an attempt to pay an order through the payment provider. An order may
+// have several attempts, e.g. after a declined card.
+type Payment struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrderID primitive.ObjectID `bson:"order_id" json:"order_id"`
+	// UserID is the user who started the attempt
+	UserID   primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Provider string             `bson:"provider" json:"provider"`
+	// IntentID is the reference of the payment at the provider
+	IntentID      string             `bson:"intent_id" json:"intent_id"`
+	Amount        money.Money        `bson:"amount" json:"amount"`
+	Status        string             `bson:"status" json:"status"`
+	FailureReason string             `bson:"failure_reason,omitempty" json:"failure_reason,omitempty"`
+	RefundID      string             `bson:"refund_id,omitempty" json:"refund_id,omitempty"`
+	CreatedAt     primitive.DateTime `bson:"created_at" json:"created_at"`
+	UpdatedAt     primitive.DateTime `bson:"updated_at" json:"updated_at"`
+}
+
+// PaymentEvent is a webhook notification already handled, kept so redelivered
+// notifications are ignored
+type PaymentEvent struct {
+	// ID is the ID of the event at the provider
+	ID         string             `bson:"_id" json:"id"`
+	Provider   string             `bson:"provider" json:"provider"`
+	Type       string             `bson:"type" json:"type"`
+	IntentID   string             `bson:"intent_id" json:"intent_id"`
+	ReceivedAt primitive.DateTime `bson:"received_at" json:"received_at"`
+}
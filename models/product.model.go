@@ -1,16 +1,58 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"fiber/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Product struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name        string             `bson:"name" json:"name"`
 	Description string             `bson:"description" json:"description"`
-	Price       float64            `bson:"price" json:"price"`
-	Image       string             `bson:"image" json:"image"`
-	CategoryID  primitive.ObjectID `bson:"category_id" json:"category_id"`
-	CreatedAt   primitive.DateTime `bson:"created_at" json:"created_at"`
-	UpdatedAt   primitive.DateTime `bson:"updated_at" json:"updated_at"`
-	CreatedBy   primitive.ObjectID `bson:"created_by" json:"created_by"`
-	UpdatedBy   primitive.ObjectID `bson:"updated_by" json:"updated_by"`
+	// Price is in the shop currency; Prices lists the prices set for other
+	// currencies, which take precedence over converting Price
+	Price      money.Money        `bson:"price" json:"price"`
+	Prices     []money.Money      `bson:"prices,omitempty" json:"prices,omitempty"`
+	Image      string             `bson:"image" json:"image"`
+	Images     *ImageVariants     `bson:"images,omitempty" json:"images,omitempty"`
+	CategoryID primitive.ObjectID `bson:"category_id" json:"category_id"`
+	// Variants are the versions of the product that can be bought, a product
+	// without variants is sold as is
+	Variants  []Variant          `bson:"variants,omitempty" json:"variants,omitempty"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+	UpdatedAt primitive.DateTime `bson:"updated_at" json:"updated_at"`
+	CreatedBy primitive.ObjectID `bson:"created_by" json:"created_by"`
+	UpdatedBy primitive.ObjectID `bson:"updated_by" json:"updated_by"`
+
+	SoftDelete `bson:",inline"`
+}
+
+// Variant is a version of a product, such as a size or a color, with its own SKU and
+// stock. Its attributes follow the attribute schema of the category of the product.
+type Variant struct {
+	SKU        string                 `bson:"sku" json:"sku"`
+	Attributes map[string]interface{} `bson:"attributes" json:"attributes"`
+	// Price overrides the price of the product in the shop currency
+	Price  *money.Money   `bson:"price,omitempty" json:"price,omitempty"`
+	Image  string         `bson:"image,omitempty" json:"image,omitempty"`
+	Images *ImageVariants `bson:"images,omitempty" json:"images,omitempty"`
+}
+
+// Variant returns the variant with the SKU, nil if there is none
+func (p *Product) Variant(sku string) *Variant {
+	for i := range p.Variants {
+		if p.Variants[i].SKU == sku {
+			return &p.Variants[i]
+		}
+	}
+	return nil
+}
+
+// PriceOf returns the price of a variant, or of the product when the variant has no price of its own
+func (p *Product) PriceOf(sku string) money.Money {
+	if variant := p.Variant(sku); variant != nil && variant.Price != nil {
+		return *variant.Price
+	}
+	return p.Price
 }
@@ -0,0 +1,46 @@
+package models
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// RolePermissions lists what each role is allowed to do
+var RolePermissions = map[string][]string{
+	RoleAdmin: {
+		"users:read", "users:write",
+		"categories:read", "categories:write",
+		"products:read", "products:write",
+		"customers:read", "customers:write",
+		"orders:read", "orders:write",
+		"inventory:read", "inventory:write",
+		"pricing:write",
+		"trash:manage",
+	},
+	RoleEditor: {
+		"categories:read", "categories:write",
+		"products:read", "products:write",
+		"customers:read", "customers:write",
+		"orders:read", "orders:write",
+		"inventory:read", "inventory:write",
+	},
+	RoleViewer: {
+		"categories:read",
+		"products:read",
+	},
+}
+
+// HasPermission reports whether the role grants the permission. Users created
+// before roles existed have no role and are treated as viewers.
+func HasPermission(role, permission string) bool {
+	if role == "" {
+		role = RoleViewer
+	}
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Session is one refresh token. Rotating a refresh token creates a new session
+// in the same family; presenting a rotated token again revokes the whole family.
+type Session struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FamilyID        primitive.ObjectID `bson:"family_id" json:"family_id"`
+	UserID          primitive.ObjectID `bson:"user_id" json:"user_id"`
+	TokenHash       string             `bson:"token_hash" json:"-"`
+	AccessJTI       string             `bson:"access_jti" json:"-"`
+	AccessExpiresAt primitive.DateTime `bson:"access_expires_at" json:"-"`
+	ReplacedBy      primitive.ObjectID `bson:"replaced_by,omitempty" json:"replaced_by,omitempty"`
+	Revoked         bool               `bson:"revoked" json:"revoked"`
+	CreatedAt       primitive.DateTime `bson:"created_at" json:"created_at"`
+	ExpiresAt       primitive.DateTime `bson:"expires_at" json:"expires_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// SoftDelete records when and by whom a document was moved to the trash.
+// Both fields are absent while the document is live.
+type SoftDelete struct {
+	DeletedAt *primitive.DateTime `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy *primitive.ObjectID `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+}
+
+func (s SoftDelete) IsDeleted() bool {
+	return s.DeletedAt != nil
+}
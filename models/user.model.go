@@ -2,11 +2,23 @@ package models
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+// Statuses of a user account, registered accounts are pending until their email is verified
+const (
+	UserPending = "pending"
+	UserActive  = "active"
+)
+
 type User struct {
 	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name     string             `bson:"name" json:"name"`
 	Email    string             `bson:"email" json:"email"`
-	Password string             `bson:"password" json:"password"`
+	Password string             `bson:"password" json:"-"`
 	Status   string             `bson:"status" json:"status"`
+	Role     string             `bson:"role" json:"role"`
 	Image    string             `bson:"image" json:"image"`
+	Images   *ImageVariants     `bson:"images,omitempty" json:"images,omitempty"`
+	// EmailVerifiedAt is when a verification link sent to Email was followed
+	EmailVerifiedAt *primitive.DateTime `bson:"email_verified_at,omitempty" json:"email_verified_at,omitempty"`
+
+	SoftDelete `bson:",inline"`
 }
@@ -0,0 +1,22 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Purposes of the tokens mailed to users
+const (
+	TokenEmailVerification = "email_verification"
+	TokenPasswordReset     = "password_reset"
+)
+
+// UserToken records a single-use token mailed to a user. The token itself is not
+// stored: it is signed and carries the ID of its record, which is marked used once.
+type UserToken struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID  primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Purpose string             `bson:"purpose" json:"purpose"`
+	// Email is the address the token was sent to, it only works while the user has it
+	Email     string              `bson:"email" json:"email"`
+	CreatedAt primitive.DateTime  `bson:"created_at" json:"created_at"`
+	ExpiresAt primitive.DateTime  `bson:"expires_at" json:"expires_at"`
+	UsedAt    *primitive.DateTime `bson:"used_at,omitempty" json:"used_at,omitempty"`
+}
@@ -0,0 +1,184 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+	// ErrOutOfRange is returned when a result needs more than the 34 digits of a Decimal128
+	ErrOutOfRange = errors.New("amount out of range")
+)
+
+// Limits of the amounts and rates read by ParseDecimal. Totals of many lines of
+// large quantities stay far within the 34 digits of a Decimal128.
+const (
+	MaxIntegerDigits = 15
+	MaxDecimals      = 12
+)
+
+// Money is an exact amount of a currency. The amount is a Decimal128, so it is
+// stored without the rounding errors of floating point numbers and sent to clients
+// as a string: {"amount": "12.50", "currency": "EUR"}.
+type Money struct {
+	Amount   primitive.Decimal128 `bson:"amount" json:"amount"`
+	Currency string               `bson:"currency" json:"currency"`
+}
+
+// Parse reads an amount such as "12.50" of the currency. It refuses negative
+// amounts and more decimals than the minor unit of the currency.
+func Parse(amount, currency string) (Money, error) {
+	d, err := ParseDecimal(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	coef, scale := parts(d)
+	units := MinorUnits(currency)
+	if scale > units {
+		return Money{}, fmt.Errorf("%w: %s has %d decimals", ErrInvalidAmount, currency, units)
+	}
+	// Amounts are kept with every decimal of the currency: 12.5 USD is 12.50
+	return fromParts(coef.Mul(coef, pow10(units-scale)), units, currency)
+}
+
+// ParseDecimal reads a finite, non-negative decimal number with at most
+// MaxIntegerDigits digits before the decimal point and MaxDecimals after it
+func ParseDecimal(s string) (primitive.Decimal128, error) {
+	d, err := primitive.ParseDecimal128(strings.TrimSpace(s))
+	if err != nil || d.IsNaN() || d.IsInf() != 0 {
+		return primitive.Decimal128{}, ErrInvalidAmount
+	}
+	coef, scale := parts(d)
+	if coef.Sign() < 0 {
+		return primitive.Decimal128{}, ErrInvalidAmount
+	}
+	if scale > MaxDecimals || coef.Cmp(new(big.Int).Mul(pow10(MaxIntegerDigits), pow10(scale))) >= 0 {
+		return primitive.Decimal128{}, fmt.Errorf("%w: at most %d digits before the decimal point and %d after", ErrInvalidAmount, MaxIntegerDigits, MaxDecimals)
+	}
+	return d, nil
+}
+
+// Zero is no money in the currency, with its decimals, e.g. 0.00 USD
+func Zero(currency string) Money {
+	// Zero always fits
+	m, _ := fromParts(new(big.Int), MinorUnits(currency), currency)
+	return m
+}
+
+// FromFloat converts a floating point amount to the nearest amount of the currency
+func FromFloat(amount float64, currency string) (Money, error) {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return Money{}, ErrInvalidAmount
+	}
+	r := new(big.Rat)
+	r.SetFloat64(amount)
+	return Round(r, currency, Rules{Mode: HalfUp})
+}
+
+// Rat returns the exact value of the amount
+func (m Money) Rat() *big.Rat {
+	coef, scale := parts(m.Amount)
+	return new(big.Rat).SetFrac(coef, pow10(scale))
+}
+
+// Mul multiplies the amount by a quantity, exactly
+func (m Money) Mul(quantity int) (Money, error) {
+	coef, scale := parts(m.Amount)
+	return fromParts(coef.Mul(coef, big.NewInt(int64(quantity))), scale, m.Currency)
+}
+
+// Add returns the sum of two amounts of the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	a, b, scale := align(m.Amount, other.Amount)
+	return fromParts(a.Add(a, b), scale, m.Currency)
+}
+
+// Sub returns the difference of two amounts of the same currency
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	a, b, scale := align(m.Amount, other.Amount)
+	return fromParts(a.Sub(a, b), scale, m.Currency)
+}
+
+// Cmp compares the amounts, ignoring the currencies, returning -1, 0 or 1
+func (m Money) Cmp(other Money) int {
+	a, b, _ := align(m.Amount, other.Amount)
+	return a.Cmp(b)
+}
+
+// Equal reports whether both are the same amount of the same currency; 2.5 equals 2.50
+func (m Money) Equal(other Money) bool {
+	return m.Currency == other.Currency && m.Cmp(other) == 0
+}
+
+func (m Money) String() string {
+	return m.Amount.String() + " " + m.Currency
+}
+
+// Sum adds amounts of the currency
+func Sum(currency string, amounts ...Money) (Money, error) {
+	total := Zero(currency)
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// parts returns the coefficient and the number of decimals of d. The zero value
+// of Decimal128, which has the smallest exponent, is read as 0.
+func parts(d primitive.Decimal128) (*big.Int, int) {
+	if d.IsZero() {
+		return new(big.Int), 0
+	}
+	coef, exp, err := d.BigInt()
+	if err != nil {
+		return new(big.Int), 0
+	}
+	if exp > 0 {
+		return coef.Mul(coef, pow10(exp)), 0
+	}
+	return coef, -exp
+}
+
+// align returns both coefficients at the larger number of decimals
+func align(x, y primitive.Decimal128) (*big.Int, *big.Int, int) {
+	a, scaleA := parts(x)
+	b, scaleB := parts(y)
+	switch {
+	case scaleA < scaleB:
+		a.Mul(a, pow10(scaleB-scaleA))
+		return a, b, scaleB
+	case scaleB < scaleA:
+		b.Mul(b, pow10(scaleA-scaleB))
+	}
+	return a, b, scaleA
+}
+
+// fromParts builds the amount coef × 10^-scale, or fails with ErrOutOfRange
+// beyond the 34 digits of a Decimal128
+func fromParts(coef *big.Int, scale int, currency string) (Money, error) {
+	d, ok := primitive.ParseDecimal128FromBigInt(coef, -scale)
+	if !ok {
+		return Money{}, ErrOutOfRange
+	}
+	return Money{Amount: d, Currency: currency}, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,128 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     string
+	}{
+		{"12.5", "USD", "12.50"},
+		{" 12.50 ", "USD", "12.50"},
+		{"0", "USD", "0.00"},
+		{"1500", "JPY", "1500"},
+		{"1.234", "KWD", "1.234"},
+		{"999999999999999.99", "USD", "999999999999999.99"},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.amount, tt.currency)
+		if err != nil {
+			t.Errorf("Parse(%q, %s): %v", tt.amount, tt.currency, err)
+			continue
+		}
+		if got.Amount.String() != tt.want || got.Currency != tt.currency {
+			t.Errorf("Parse(%q, %s) = %s %s, want %s", tt.amount, tt.currency, got.Amount, got.Currency, tt.want)
+		}
+	}
+
+	invalid := []struct{ amount, currency string }{
+		{"", "USD"},
+		{"abc", "USD"},
+		{"-1", "USD"},
+		{"NaN", "USD"},
+		{"Infinity", "USD"},
+		{"1.001", "USD"},
+		{"1.5", "JPY"},
+		{"1000000000000000", "USD"},
+		{"1234567890123456789012345678901234", "USD"},
+		{"1E15", "USD"},
+	}
+	for _, tt := range invalid {
+		if _, err := Parse(tt.amount, tt.currency); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Parse(%q, %s) = %v, want ErrInvalidAmount", tt.amount, tt.currency, err)
+		}
+	}
+}
+
+func TestParseDecimalLimits(t *testing.T) {
+	if _, err := ParseDecimal("0.000000000001"); err != nil {
+		t.Errorf("%d decimals: %v", MaxDecimals, err)
+	}
+	if _, err := ParseDecimal("0.0000000000001"); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("%d decimals: got %v, want ErrInvalidAmount", MaxDecimals+1, err)
+	}
+	if _, err := ParseDecimal("999999999999999.999999999999"); err != nil {
+		t.Errorf("largest decimal: %v", err)
+	}
+	if _, err := ParseDecimal("1000000000000000"); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("%d integer digits: got %v, want ErrInvalidAmount", MaxIntegerDigits+1, err)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, _ := Parse("0.1", "USD")
+	b, _ := Parse("0.2", "USD")
+
+	sum, err := a.Add(b)
+	if err != nil || sum.Amount.String() != "0.30" {
+		t.Fatalf("0.1 + 0.2 = %s, %v", sum.Amount, err)
+	}
+	diff, err := a.Sub(b)
+	if err != nil || diff.Amount.String() != "-0.10" {
+		t.Fatalf("0.1 - 0.2 = %s, %v", diff.Amount, err)
+	}
+	product, err := sum.Mul(3)
+	if err != nil || product.Amount.String() != "0.90" {
+		t.Fatalf("0.30 × 3 = %s, %v", product.Amount, err)
+	}
+	if _, err := a.Add(Zero("EUR")); err != ErrCurrencyMismatch {
+		t.Fatalf("USD + EUR: got %v, want ErrCurrencyMismatch", err)
+	}
+	if z := Zero("JPY"); z.Amount.String() != "0" || z.Currency != "JPY" {
+		t.Fatalf("Zero(JPY) = %s %s", z.Amount, z.Currency)
+	}
+}
+
+func TestOutOfRange(t *testing.T) {
+	// The largest price fits with any realistic quantity
+	max, err := Parse("999999999999999.99", "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := max.Mul(1000000)
+	if err != nil {
+		t.Fatalf("max × 10^6: %v", err)
+	}
+	if _, err := line.Add(line); err != nil {
+		t.Fatalf("adding lines: %v", err)
+	}
+
+	// 17 digits × 18 digits needs 35, one more than a Decimal128 holds
+	if _, err := max.Mul(math.MaxInt64); err != ErrOutOfRange {
+		t.Fatalf("max × MaxInt64: got %v, want ErrOutOfRange", err)
+	}
+	full, err := max.Mul(99999999999999999)
+	if err != nil {
+		t.Fatalf("34 digit line: %v", err)
+	}
+	if _, err := full.Add(full); err != ErrOutOfRange {
+		t.Fatalf("adding 34 digit amounts: got %v, want ErrOutOfRange", err)
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	got, err := FromFloat(19.999, "USD")
+	if err != nil || got.Amount.String() != "20.00" {
+		t.Fatalf("FromFloat(19.999) = %s, %v", got.Amount, err)
+	}
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := FromFloat(f, "USD"); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("FromFloat(%v): got %v, want ErrInvalidAmount", f, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package money
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RateSource provides exchange rates from a base currency. A rate is the number
+// of units of the currency one unit of the base buys.
+type RateSource interface {
+	Name() string
+	Rates(ctx context.Context, base string) (map[string]primitive.Decimal128, error)
+}
+
+// StaticSource serves fixed rates, such as the ones set in the configuration
+type StaticSource struct {
+	rates map[string]primitive.Decimal128
+}
+
+func NewStaticSource(rates map[string]primitive.Decimal128) *StaticSource {
+	return &StaticSource{rates: rates}
+}
+
+func (s *StaticSource) Name() string {
+	return "static"
+}
+
+func (s *StaticSource) Rates(ctx context.Context, base string) (map[string]primitive.Decimal128, error) {
+	rates := make(map[string]primitive.Decimal128, len(s.rates))
+	for currency, rate := range s.rates {
+		if currency != base {
+			rates[currency] = rate
+		}
+	}
+	return rates, nil
+}
+
+// ParseRate reads a positive exchange rate such as "0.92"
+func ParseRate(s string) (primitive.Decimal128, error) {
+	rate, err := ParseDecimal(s)
+	if err != nil || DecimalRat(rate).Sign() == 0 {
+		return primitive.Decimal128{}, fmt.Errorf("invalid exchange rate %q", s)
+	}
+	return rate, nil
+}
+
+// ParseRates reads rates such as "EUR:0.92,GBP:0.79"
+func ParseRates(s string) (map[string]primitive.Decimal128, error) {
+	pairs, err := parsePairs(s)
+	if err != nil {
+		return nil, err
+	}
+	rates := make(map[string]primitive.Decimal128, len(pairs))
+	for currency, value := range pairs {
+		if rates[currency], err = ParseRate(value); err != nil {
+			return nil, fmt.Errorf("%s: %w", currency, err)
+		}
+	}
+	return rates, nil
+}
+
+// DecimalRat returns the exact value of a decimal, such as a stored rate
+func DecimalRat(d primitive.Decimal128) *big.Rat {
+	coef, scale := parts(d)
+	return new(big.Rat).SetFrac(coef, pow10(scale))
+}
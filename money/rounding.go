@@ -0,0 +1,144 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// RoundingMode decides which way an amount between two representable amounts goes
+type RoundingMode string
+
+const (
+	// HalfUp rounds to the nearest amount, halves away from zero
+	HalfUp RoundingMode = "half_up"
+	// HalfEven rounds to the nearest amount, halves to the even one (banker's rounding)
+	HalfEven RoundingMode = "half_even"
+	// Down rounds toward zero
+	Down RoundingMode = "down"
+	// Up rounds away from zero
+	Up RoundingMode = "up"
+)
+
+// Rules is how converted prices are rounded
+type Rules struct {
+	Mode RoundingMode
+	// Increments are the steps of currencies not priced in their minor unit,
+	// e.g. CHF is commonly rounded to 0.05
+	Increments map[string]*big.Rat
+}
+
+// ParseRules reads a rounding mode and increments such as "CHF:0.05,SEK:1"
+func ParseRules(mode, increments string) (Rules, error) {
+	rules := Rules{Mode: RoundingMode(mode), Increments: map[string]*big.Rat{}}
+	switch rules.Mode {
+	case HalfUp, HalfEven, Down, Up:
+	default:
+		return Rules{}, fmt.Errorf("invalid rounding mode %q, allowed: half_up, half_even, down, up", mode)
+	}
+
+	pairs, err := parsePairs(increments)
+	if err != nil {
+		return Rules{}, err
+	}
+	for currency, value := range pairs {
+		increment, ok := new(big.Rat).SetString(value)
+		if !ok || increment.Sign() <= 0 {
+			return Rules{}, fmt.Errorf("invalid rounding increment %q for %s", value, currency)
+		}
+		// The increment must be a multiple of the minor unit, or rounded prices could not be stored
+		if !new(big.Rat).Mul(increment, new(big.Rat).SetInt(pow10(MinorUnits(currency)))).IsInt() {
+			return Rules{}, fmt.Errorf("rounding increment %s of %s is finer than its minor unit", value, currency)
+		}
+		rules.Increments[currency] = increment
+	}
+	return rules, nil
+}
+
+// Round returns the exact value as an amount of the currency, rounded to its
+// increment or minor unit
+func Round(value *big.Rat, currency string, rules Rules) (Money, error) {
+	scale := MinorUnits(currency)
+	step := new(big.Rat).SetFrac(big.NewInt(1), pow10(scale))
+	if increment, ok := rules.Increments[currency]; ok {
+		step = increment
+	}
+
+	// Number of steps, rounded to an integer
+	q := new(big.Rat).Quo(value, step)
+	steps := roundInt(q, rules.Mode)
+
+	// steps * step, expressed in minor units
+	units := new(big.Rat).Mul(new(big.Rat).SetInt(steps), step)
+	units.Mul(units, new(big.Rat).SetInt(pow10(scale)))
+	return fromParts(new(big.Int).Set(units.Num()), scale, currency)
+}
+
+// Convert converts an amount with rate units of the target currency per unit of
+// the amount's currency
+func Convert(m Money, currency string, rate *big.Rat, rules Rules) (Money, error) {
+	return Round(new(big.Rat).Mul(m.Rat(), rate), currency, rules)
+}
+
+// roundInt rounds a rational number to an integer
+func roundInt(q *big.Rat, mode RoundingMode) *big.Int {
+	// Truncated quotient and remainder: q = n + r/d with |r| < d
+	n, r := new(big.Int).QuoRem(q.Num(), q.Denom(), new(big.Int))
+	if r.Sign() == 0 {
+		return n
+	}
+
+	away := big.NewInt(int64(q.Sign()))
+	// Compare the fraction to one half: 2|r| against d
+	half := new(big.Int).Abs(r)
+	half.Mul(half, big.NewInt(2))
+	cmp := half.Cmp(q.Denom())
+
+	switch mode {
+	case Down:
+		return n
+	case Up:
+		return n.Add(n, away)
+	case HalfEven:
+		if cmp > 0 || (cmp == 0 && n.Bit(0) == 1) {
+			return n.Add(n, away)
+		}
+		return n
+	default:
+		if cmp >= 0 {
+			return n.Add(n, away)
+		}
+		return n
+	}
+}
+
+// parsePairs reads a list such as "EUR:0.92,GBP:0.79" keyed by currency code
+func parsePairs(s string) (map[string]string, error) {
+	pairs := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		currency, value, ok := strings.Cut(pair, ":")
+		currency = strings.TrimSpace(currency)
+		if !ok || !IsCode(currency) {
+			return nil, fmt.Errorf("invalid entry %q, expected CODE:value", pair)
+		}
+		pairs[currency] = strings.TrimSpace(value)
+	}
+	return pairs, nil
+}
+
+// IsCode reports whether s looks like an ISO 4217 code: three uppercase letters
+func IsCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
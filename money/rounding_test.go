@@ -0,0 +1,131 @@
+package money
+
+import (
+	"math/big"
+	"testing"
+)
+
+func rat(t *testing.T, s string) *big.Rat {
+	t.Helper()
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		t.Fatalf("invalid rational %q", s)
+	}
+	return r
+}
+
+func TestRoundInt(t *testing.T) {
+	tests := []struct {
+		value string
+		mode  RoundingMode
+		want  int64
+	}{
+		{"2.5", HalfUp, 3},
+		{"-2.5", HalfUp, -3},
+		{"2.4", HalfUp, 2},
+		{"-2.6", HalfUp, -3},
+		{"2.5", HalfEven, 2},
+		{"3.5", HalfEven, 4},
+		{"-2.5", HalfEven, -2},
+		{"-3.5", HalfEven, -4},
+		{"2.51", HalfEven, 3},
+		{"2.9", Down, 2},
+		{"-2.9", Down, -2},
+		{"2.1", Up, 3},
+		{"-2.1", Up, -3},
+		{"7", Up, 7},
+		{"-7", Down, -7},
+		{"0", HalfUp, 0},
+	}
+	for _, tt := range tests {
+		if got := roundInt(rat(t, tt.value), tt.mode); got.Int64() != tt.want {
+			t.Errorf("roundInt(%s, %s) = %s, want %d", tt.value, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	chf, err := ParseRules("half_up", "CHF:0.05")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value    string
+		currency string
+		rules    Rules
+		want     string
+	}{
+		{"2.345", "USD", Rules{Mode: HalfUp}, "2.35"},
+		{"-2.345", "USD", Rules{Mode: HalfUp}, "-2.35"},
+		{"2.344", "USD", Rules{Mode: HalfUp}, "2.34"},
+		{"2.345", "USD", Rules{Mode: HalfEven}, "2.34"},
+		{"2.355", "USD", Rules{Mode: HalfEven}, "2.36"},
+		{"-2.345", "USD", Rules{Mode: HalfEven}, "-2.34"},
+		{"2.349", "USD", Rules{Mode: Down}, "2.34"},
+		{"-2.349", "USD", Rules{Mode: Down}, "-2.34"},
+		{"2.341", "USD", Rules{Mode: Up}, "2.35"},
+		{"-2.341", "USD", Rules{Mode: Up}, "-2.35"},
+		{"12", "USD", Rules{Mode: HalfUp}, "12.00"},
+		{"1234.5", "JPY", Rules{Mode: HalfUp}, "1235"},
+		{"1234.5", "JPY", Rules{Mode: HalfEven}, "1234"},
+		{"1234.9", "JPY", Rules{Mode: Down}, "1234"},
+		{"1.23456", "KWD", Rules{Mode: HalfUp}, "1.235"},
+		{"1.23", "CHF", chf, "1.25"},
+		{"1.22", "CHF", chf, "1.20"},
+		{"1.225", "CHF", chf, "1.25"},
+		{"-1.225", "CHF", chf, "-1.25"},
+		{"1.23", "EUR", chf, "1.23"},
+	}
+	for _, tt := range tests {
+		got, err := Round(rat(t, tt.value), tt.currency, tt.rules)
+		if err != nil {
+			t.Errorf("Round(%s %s): %v", tt.value, tt.currency, err)
+			continue
+		}
+		if got.Amount.String() != tt.want || got.Currency != tt.currency {
+			t.Errorf("Round(%s %s, %s) = %s %s, want %s", tt.value, tt.currency, tt.rules.Mode, got.Amount, got.Currency, tt.want)
+		}
+	}
+}
+
+func TestRoundOutOfRange(t *testing.T) {
+	// 36 significant digits, a Decimal128 holds 34
+	huge := rat(t, "1234567890123456789012345678901234.57")
+	if _, err := Round(huge, "USD", Rules{Mode: HalfUp}); err != ErrOutOfRange {
+		t.Fatalf("got %v, want ErrOutOfRange", err)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("half_even", " CHF:0.05 , SEK:1 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Mode != HalfEven {
+		t.Errorf("mode = %s, want half_even", rules.Mode)
+	}
+	if got := rules.Increments["CHF"]; got == nil || got.Cmp(rat(t, "0.05")) != 0 {
+		t.Errorf("CHF increment = %v, want 0.05", got)
+	}
+	if got := rules.Increments["SEK"]; got == nil || got.Cmp(rat(t, "1")) != 0 {
+		t.Errorf("SEK increment = %v, want 1", got)
+	}
+
+	invalid := []struct{ mode, increments string }{
+		{"nearest", ""},
+		{"half_up", "CHF"},
+		{"half_up", "chf:0.05"},
+		{"half_up", "CHF:abc"},
+		{"half_up", "CHF:0"},
+		{"half_up", "CHF:-0.05"},
+		{"half_up", "CHF:0.005"},
+		{"half_up", "JPY:0.5"},
+	}
+	for _, tt := range invalid {
+		if _, err := ParseRules(tt.mode, tt.increments); err == nil {
+			t.Errorf("ParseRules(%q, %q) succeeded, want an error", tt.mode, tt.increments)
+		}
+	}
+}
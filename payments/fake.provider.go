@@ -0,0 +1,153 @@
+package payments
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"fiber/money"
+)
+
+// Statuses of the intents of the fake provider
+const (
+	FakeRequiresCapture = "requires_capture"
+	FakeSucceeded       = "succeeded"
+	FakeRefunded        = "refunded"
+)
+
+// FakeProvider authorizes every payment without contacting anyone. It keeps its
+// intents in memory and signs its notifications like a real gateway, for tests
+// and local runs.
+type FakeProvider struct {
+	secret string
+
+	mu      sync.Mutex
+	intents map[string]*fakeIntent
+	// keys maps idempotency keys to the intent they created
+	keys map[string]string
+}
+
+type fakeIntent struct {
+	Intent
+	amount   money.Money
+	refunded money.Money
+}
+
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{secret: secret, intents: map[string]*fakeIntent{}, keys: map[string]string{}}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.keys[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		intent := p.intents[id].Intent
+		return &intent, nil
+	}
+
+	id, err := fakeID("pi_")
+	if err != nil {
+		return nil, err
+	}
+	secret, err := fakeID(id + "_secret_")
+	if err != nil {
+		return nil, err
+	}
+	p.intents[id] = &fakeIntent{Intent: Intent{ID: id, Status: FakeRequiresCapture, ClientSecret: secret}, amount: req.Amount, refunded: money.Zero(req.Amount.Currency)}
+	if req.IdempotencyKey != "" {
+		p.keys[req.IdempotencyKey] = id
+	}
+
+	intent := p.intents[id].Intent
+	return &intent, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, intentID string) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	if intent.Status != FakeRequiresCapture {
+		return nil, ErrInvalidState
+	}
+	intent.Status = FakeSucceeded
+
+	captured := intent.Intent
+	return &captured, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, intentID string, amount money.Money) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	refunded, err := intent.refunded.Add(amount)
+	if err != nil || intent.Status != FakeSucceeded || refunded.Cmp(intent.amount) > 0 {
+		return nil, ErrInvalidState
+	}
+	intent.refunded = refunded
+	if refunded.Cmp(intent.amount) == 0 {
+		intent.Status = FakeRefunded
+	}
+
+	id, err := fakeID("re_")
+	if err != nil {
+		return nil, err
+	}
+	return &Refund{ID: id, Amount: amount}, nil
+}
+
+func (p *FakeProvider) VerifyWebhook(payload []byte, signature string) (*Event, error) {
+	if err := Verify(p.secret, payload, signature, time.Now()); err != nil {
+		return nil, err
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil || event.ID == "" || event.IntentID == "" {
+		return nil, ErrInvalidEvent
+	}
+	return &event, nil
+}
+
+// Notify builds the signed webhook a real gateway would send once the customer
+// completed or abandoned the payment of the intent; a success also settles the intent
+func (p *FakeProvider) Notify(intentID, eventType, failureReason string) (payload []byte, signature string, err error) {
+	if eventType == EventPaymentSucceeded {
+		p.mu.Lock()
+		if intent, ok := p.intents[intentID]; ok && intent.Status == FakeRequiresCapture {
+			intent.Status = FakeSucceeded
+		}
+		p.mu.Unlock()
+	}
+
+	id, err := fakeID("evt_")
+	if err != nil {
+		return nil, "", err
+	}
+	payload, err = json.Marshal(Event{ID: id, Type: eventType, IntentID: intentID, FailureReason: failureReason})
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, Sign(p.secret, payload, time.Now()), nil
+}
+
+func fakeID(prefix string) (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,130 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiber/config"
+	"fiber/money"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidEvent     = errors.New("invalid webhook event")
+	ErrIntentNotFound   = errors.New("payment intent not found")
+	// ErrInvalidState is returned when the intent cannot be captured or refunded in its current state
+	ErrInvalidState = errors.New("payment intent is not in a state allowing this operation")
+)
+
+// Webhook event types
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+)
+
+// SignatureHeader carries the signature of webhook payloads, such as "t=1700000000,v1=5f2b..."
+const SignatureHeader = "X-Payment-Signature"
+
+// signatureTolerance bounds the age of a webhook, so captured requests cannot be replayed later
+const signatureTolerance = 5 * time.Minute
+
+// IntentRequest asks the provider to prepare the payment of an amount
+type IntentRequest struct {
+	Amount money.Money
+	// Reference identifies the payment in the shop
+	Reference string
+	// IdempotencyKey makes a retried request return the intent of the first one
+	IdempotencyKey string
+}
+
+// Intent is a payment at the provider
+type Intent struct {
+	ID     string
+	Status string
+	// ClientSecret lets the client confirm the payment with the provider directly
+	ClientSecret string
+}
+
+type Refund struct {
+	ID     string
+	Amount money.Money
+}
+
+// Event is a notification sent by the provider when a payment completes
+type Event struct {
+	ID            string `json:"id"`
+	Type          string `json:"type"`
+	IntentID      string `json:"intent_id"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+// PaymentProvider is a payment gateway
+type PaymentProvider interface {
+	// Name is stored with the payments to know where they were made
+	Name() string
+	CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error)
+	// Capture collects an authorized intent
+	Capture(ctx context.Context, intentID string) (*Intent, error)
+	Refund(ctx context.Context, intentID string, amount money.Money) (*Refund, error)
+	// VerifyWebhook checks the signature of a webhook payload and decodes its event,
+	// returning ErrInvalidSignature for forged or stale notifications and
+	// ErrInvalidEvent for payloads it cannot read
+	VerifyWebhook(payload []byte, signature string) (*Event, error)
+}
+
+// New returns the payment provider selected by the configuration
+func New(cfg *config.Config) (PaymentProvider, error) {
+	switch cfg.PaymentProvider {
+	case "fake":
+		return NewFakeProvider(cfg.PaymentWebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.PaymentProvider)
+	}
+}
+
+// Sign returns the signature header of a payload sent at t: the HMAC-SHA256 of
+// "<unix time>.<payload>" keyed with the webhook secret
+func Sign(secret string, payload []byte, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + signature(secret, timestamp, payload)
+}
+
+// Verify checks a signature header produced by Sign
+func Verify(secret string, payload []byte, header string, now time.Time) error {
+	var timestamp, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			sig = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, timestamp, payload))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func signature(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,12 @@
+package policies
+
+import "fiber/models"
+
+// Order lets users pay the orders they placed while staff can handle the payments of any order
+var Order = Policy{
+	Resource: "order",
+	Rules: map[string]Rule{
+		"pay":           {Owner: true, Roles: []string{models.RoleAdmin, models.RoleEditor}},
+		"read_payments": {Owner: true, Roles: []string{models.RoleAdmin, models.RoleEditor}},
+	},
+}
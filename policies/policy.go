@@ -0,0 +1,75 @@
+package policies
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Subject is the authenticated user performing an action
+type Subject struct {
+	UserID primitive.ObjectID
+	Role   string
+}
+
+// Rule describes who may perform one action on a resource
+type Rule struct {
+	// Owner allows the user that owns the resource
+	Owner bool
+	// Roles may perform the action on any resource
+	Roles []string
+}
+
+// Policy declares the rules of every action on a resource type.
+// Actions without a rule are denied.
+type Policy struct {
+	Resource string
+	Rules    map[string]Rule
+}
+
+// DeniedError explains why a subject may not perform an action
+type DeniedError struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	Reason   string `json:"reason"`
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("%s %s denied: %s", e.Action, e.Resource, e.Reason)
+}
+
+// Authorize returns a *DeniedError unless the subject may perform the action
+// on the resource owned by ownerID
+func (p Policy) Authorize(subject Subject, action string, ownerID primitive.ObjectID) error {
+	rule, ok := p.Rules[action]
+	if !ok {
+		return &DeniedError{Resource: p.Resource, Action: action, Reason: "action is not allowed"}
+	}
+
+	for _, role := range rule.Roles {
+		if subject.Role == role {
+			return nil
+		}
+	}
+	if rule.Owner && !ownerID.IsZero() && subject.UserID == ownerID {
+		return nil
+	}
+
+	return &DeniedError{Resource: p.Resource, Action: action, Reason: rule.describe(p.Resource, action)}
+}
+
+// describe turns a rule into a human readable reason
+func (r Rule) describe(resource, action string) string {
+	var allowed []string
+	if r.Owner {
+		allowed = append(allowed, "the owner")
+	}
+	for _, role := range r.Roles {
+		allowed = append(allowed, "an "+role)
+	}
+	if len(allowed) == 0 {
+		return "action is not allowed"
+	}
+	return fmt.Sprintf("only %s can %s this %s", strings.Join(allowed, " or "), action, resource)
+}
@@ -0,0 +1,12 @@
+package policies
+
+import "fiber/models"
+
+// Product lets editors change their own products while admins can change any product
+var Product = Policy{
+	Resource: "product",
+	Rules: map[string]Rule{
+		"update": {Owner: true, Roles: []string{models.RoleAdmin}},
+		"delete": {Owner: true, Roles: []string{models.RoleAdmin}},
+	},
+}
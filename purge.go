@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"fiber/config"
+	"fiber/repositories"
+	"fiber/storage"
+	"fiber/utils"
+)
+
+// startTrashPurger periodically removes the documents that stayed in the trash
+// longer than the configured retention
+func startTrashPurger(cfg *config.Config, repos *repositories.Repositories, store storage.Storage) {
+	go func() {
+		ticker := time.NewTicker(cfg.TrashPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			purgeTrash(cfg, repos, store)
+			<-ticker.C
+		}
+	}()
+}
+
+func purgeTrash(cfg *config.Config, repos *repositories.Repositories, store storage.Storage) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	before := time.Now().Add(-cfg.TrashRetention)
+
+	products, err := repos.Products.Purge(ctx, before)
+	if err != nil {
+		log.Println("❌ Failed to purge products:", err)
+	}
+	for _, product := range products {
+		if product.Image != "" {
+			utils.DeleteImage(ctx, store, product.Image)
+		}
+		for _, variant := range product.Variants {
+			if variant.Image != "" {
+				utils.DeleteImage(ctx, store, variant.Image)
+			}
+		}
+	}
+
+	categories, err := repos.Categories.Purge(ctx, before)
+	if err != nil {
+		log.Println("❌ Failed to purge categories:", err)
+	}
+
+	users, err := repos.Users.Purge(ctx, before)
+	if err != nil {
+		log.Println("❌ Failed to purge users:", err)
+	}
+	for _, user := range users {
+		if user.Image != "" {
+			utils.DeleteImage(ctx, store, user.Image)
+		}
+	}
+
+	if len(products)+len(categories)+len(users) > 0 {
+		log.Printf("🗑️ Purged %d products, %d categories and %d users from the trash", len(products), len(categories), len(users))
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"fiber/config"
+	"fiber/models"
+	"fiber/money"
+	"fiber/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newRateSource returns the source of exchange rates, or nil when rates are only
+// set through the API
+func newRateSource(cfg *config.Config) (money.RateSource, error) {
+	if cfg.ExchangeRateSource != config.ExchangeRateSourceStatic {
+		return nil, nil
+	}
+	rates, err := money.ParseRates(cfg.ExchangeRates)
+	if err != nil {
+		return nil, err
+	}
+	return money.NewStaticSource(rates), nil
+}
+
+// startRateRefresher periodically copies the rates of the source into the
+// exchange rate table the prices are converted with
+func startRateRefresher(cfg *config.Config, repos *repositories.Repositories, source money.RateSource) {
+	if source == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(cfg.ExchangeRateRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			refreshRates(cfg, repos, source)
+			<-ticker.C
+		}
+	}()
+}
+
+func refreshRates(cfg *config.Config, repos *repositories.Repositories, source money.RateSource) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	rates, err := source.Rates(ctx, cfg.Currency)
+	if err != nil {
+		// The previous rates stay in use until the source answers again
+		log.Printf("❌ Failed to fetch exchange rates from %s: %v", source.Name(), err)
+		return
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	for currency, value := range rates {
+		rate := models.ExchangeRate{Base: cfg.Currency, Currency: currency, Rate: value, Source: source.Name(), UpdatedAt: now}
+		if err := repos.Rates.Save(ctx, &rate); err != nil {
+			log.Printf("❌ Failed to save the %s exchange rate: %v", currency, err)
+		}
+	}
+}
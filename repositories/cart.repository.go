@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CartRepository interface {
+	FindByUser(ctx context.Context, userID primitive.ObjectID) (*models.Cart, error)
+	FindByTokenHash(ctx context.Context, tokenHash string) (*models.Cart, error)
+	// Save creates the cart or replaces it entirely
+	Save(ctx context.Context, cart *models.Cart) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// MongoCartRepository stores carts in the "carts" collection
+type MongoCartRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoCartRepository(db *mongo.Database) *MongoCartRepository {
+	return &MongoCartRepository{collection: db.Collection("carts")}
+}
+
+func (r *MongoCartRepository) FindByUser(ctx context.Context, userID primitive.ObjectID) (*models.Cart, error) {
+	return r.findOne(ctx, bson.M{"user_id": userID})
+}
+
+func (r *MongoCartRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.Cart, error) {
+	return r.findOne(ctx, bson.M{"token_hash": tokenHash, "user_id": bson.M{"$exists": false}})
+}
+
+func (r *MongoCartRepository) findOne(ctx context.Context, filter bson.M) (*models.Cart, error) {
+	var cart models.Cart
+	if err := r.collection.FindOne(ctx, filter).Decode(&cart); err != nil {
+		return nil, mongoError(err)
+	}
+	return &cart, nil
+}
+
+func (r *MongoCartRepository) Save(ctx context.Context, cart *models.Cart) error {
+	if cart.ID.IsZero() {
+		cart.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": cart.ID}, cart, options.Replace().SetUpsert(true))
+	return mongoError(err)
+}
+
+func (r *MongoCartRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MemoryCartRepository keeps carts in memory; expired anonymous carts are ignored
+// instead of being removed by a TTL index
+type MemoryCartRepository struct {
+	mu    sync.RWMutex
+	carts []models.Cart
+}
+
+func NewMemoryCartRepository() *MemoryCartRepository {
+	return &MemoryCartRepository{}
+}
+
+func (r *MemoryCartRepository) FindByUser(ctx context.Context, userID primitive.ObjectID) (*models.Cart, error) {
+	return r.find(func(c models.Cart) bool { return c.UserID != nil && *c.UserID == userID })
+}
+
+func (r *MemoryCartRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.Cart, error) {
+	return r.find(func(c models.Cart) bool { return c.UserID == nil && c.TokenHash == tokenHash })
+}
+
+func (r *MemoryCartRepository) find(match func(models.Cart) bool) (*models.Cart, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	for _, c := range r.carts {
+		if match(c) && (c.ExpiresAt == nil || *c.ExpiresAt > now) {
+			c.Items = append([]models.CartItem{}, c.Items...)
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryCartRepository) Save(ctx context.Context, cart *models.Cart) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cart.ID.IsZero() {
+		cart.ID = primitive.NewObjectID()
+	}
+	saved := *cart
+	saved.Items = append([]models.CartItem{}, cart.Items...)
+	for i, c := range r.carts {
+		if c.ID == cart.ID {
+			r.carts[i] = saved
+			return nil
+		}
+	}
+	r.carts = append(r.carts, saved)
+	return nil
+}
+
+func (r *MemoryCartRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, c := range r.carts {
+		if c.ID == id {
+			r.carts = append(r.carts[:i], r.carts[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,318 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CategoryRepository interface {
+	Create(ctx context.Context, category *models.Category) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Category, error)
+	FindAll(ctx context.Context, page PageRequest) (*Page[models.Category], error)
+	Update(ctx context.Context, id primitive.ObjectID, category *models.Category) error
+	// ListAll returns every live category, it is used to build the tree
+	ListAll(ctx context.Context) ([]models.Category, error)
+	FindDescendantIDs(ctx context.Context, id primitive.ObjectID) ([]primitive.ObjectID, error)
+	CountChildren(ctx context.Context, id primitive.ObjectID) (int64, error)
+	// Move sets the parent of a category and rewrites the ancestors of its whole subtree
+	Move(ctx context.Context, id primitive.ObjectID, parentID *primitive.ObjectID, ancestors []primitive.ObjectID) error
+	Trash[models.Category]
+}
+
+// MongoCategoryRepository stores categories in the "categories" collection
+type MongoCategoryRepository struct {
+	mongoTrash[models.Category]
+	collection *mongo.Collection
+}
+
+func NewMongoCategoryRepository(db *mongo.Database) *MongoCategoryRepository {
+	collection := db.Collection("categories")
+	return &MongoCategoryRepository{
+		mongoTrash: mongoTrash[models.Category]{collection: collection, id: categoryID},
+		collection: collection,
+	}
+}
+
+func (r *MongoCategoryRepository) Create(ctx context.Context, category *models.Category) error {
+	if category.ID.IsZero() {
+		category.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, category)
+	return mongoError(err)
+}
+
+func (r *MongoCategoryRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Category, error) {
+	var category models.Category
+	if err := r.collection.FindOne(ctx, notDeleted(bson.M{"_id": id})).Decode(&category); err != nil {
+		return nil, mongoError(err)
+	}
+	return &category, nil
+}
+
+func (r *MongoCategoryRepository) FindAll(ctx context.Context, page PageRequest) (*Page[models.Category], error) {
+	total, err := r.collection.CountDocuments(ctx, notDeleted(bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Find(ctx, page.withCursor(notDeleted(bson.M{})), page.findOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var categories []models.Category
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return newPage(categories, total, page.Limit, false, categoryID), nil
+}
+
+func (r *MongoCategoryRepository) Update(ctx context.Context, id primitive.ObjectID, category *models.Category) error {
+	update := bson.M{"$set": bson.M{
+		"name":        category.Name,
+		"description": category.Description,
+		"status":      category.Status,
+		"attributes":  category.Attributes,
+	}}
+
+	result, err := r.collection.UpdateOne(ctx, notDeleted(bson.M{"_id": id}), update)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MongoCategoryRepository) ListAll(ctx context.Context) ([]models.Category, error) {
+	cursor, err := r.collection.Find(ctx, notDeleted(bson.M{}), options.Find().SetSort(bson.D{{"name", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	categories := []models.Category{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *MongoCategoryRepository) FindDescendantIDs(ctx context.Context, id primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := r.collection.Find(ctx, notDeleted(bson.M{"ancestors": id}), options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var descendants []models.Category
+	if err := cursor.All(ctx, &descendants); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(descendants))
+	for _, c := range descendants {
+		ids = append(ids, c.ID)
+	}
+	return ids, nil
+}
+
+func (r *MongoCategoryRepository) CountChildren(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, notDeleted(bson.M{"parent_id": id}))
+}
+
+func (r *MongoCategoryRepository) Move(ctx context.Context, id primitive.ObjectID, parentID *primitive.ObjectID, ancestors []primitive.ObjectID) error {
+	// A nil slice would be stored as null and break $concatArrays below
+	if ancestors == nil {
+		ancestors = []primitive.ObjectID{}
+	}
+	set := bson.M{"ancestors": ancestors}
+	update := bson.M{"$set": set}
+	if parentID != nil {
+		set["parent_id"] = *parentID
+	} else {
+		update["$unset"] = bson.M{"parent_id": ""}
+	}
+
+	result, err := r.collection.UpdateOne(ctx, notDeleted(bson.M{"_id": id}), update)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	// Descendants keep their path below the moved category and get its new ancestors in front.
+	// Deleted descendants are moved as well so they are restored at the right place.
+	_, err = r.collection.UpdateMany(ctx, bson.M{"ancestors": id}, mongo.Pipeline{
+		{{"$set", bson.M{"ancestors": bson.M{"$concatArrays": bson.A{
+			ancestors,
+			bson.M{"$slice": bson.A{"$ancestors", bson.M{"$indexOfArray": bson.A{"$ancestors", id}}, bson.M{"$size": "$ancestors"}}},
+		}}}}},
+	})
+	return mongoError(err)
+}
+
+// MemoryCategoryRepository keeps categories in memory, enforcing the same unique name index
+type MemoryCategoryRepository struct {
+	memoryTrash[models.Category]
+	mu         sync.RWMutex
+	categories []models.Category
+}
+
+func NewMemoryCategoryRepository() *MemoryCategoryRepository {
+	r := &MemoryCategoryRepository{}
+	r.memoryTrash = memoryTrash[models.Category]{mu: &r.mu, items: &r.categories, id: categoryID, deletion: categoryDeletion, conflict: categoryConflict}
+	return r
+}
+
+func (r *MemoryCategoryRepository) Create(ctx context.Context, category *models.Category) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.categories {
+		if (c.Name == category.Name && !c.IsDeleted()) || c.ID == category.ID {
+			return &DuplicateError{Field: "name"}
+		}
+	}
+	if category.ID.IsZero() {
+		category.ID = primitive.NewObjectID()
+	}
+	r.categories = append(r.categories, *category)
+	return nil
+}
+
+func (r *MemoryCategoryRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.categories {
+		if c.ID == id && !c.IsDeleted() {
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryCategoryRepository) FindAll(ctx context.Context, page PageRequest) (*Page[models.Category], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return paginate(live(r.categories, categoryDeletion), page, categoryID), nil
+}
+
+func (r *MemoryCategoryRepository) ListAll(ctx context.Context) ([]models.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	categories := live(r.categories, categoryDeletion)
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+	return categories, nil
+}
+
+func (r *MemoryCategoryRepository) FindDescendantIDs(ctx context.Context, id primitive.ObjectID) ([]primitive.ObjectID, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := []primitive.ObjectID{}
+	for _, c := range live(r.categories, categoryDeletion) {
+		if hasAncestor(c, id) {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *MemoryCategoryRepository) CountChildren(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, c := range live(r.categories, categoryDeletion) {
+		if c.ParentID != nil && *c.ParentID == id {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *MemoryCategoryRepository) Move(ctx context.Context, id primitive.ObjectID, parentID *primitive.ObjectID, ancestors []primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	moved := false
+	for i, c := range r.categories {
+		if c.ID == id && !c.IsDeleted() {
+			r.categories[i].ParentID = parentID
+			r.categories[i].Ancestors = ancestors
+			moved = true
+		}
+	}
+	if !moved {
+		return ErrNotFound
+	}
+
+	for i, c := range r.categories {
+		for j, ancestor := range c.Ancestors {
+			if ancestor == id {
+				path := append([]primitive.ObjectID{}, ancestors...)
+				r.categories[i].Ancestors = append(path, c.Ancestors[j:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func hasAncestor(c models.Category, id primitive.ObjectID) bool {
+	for _, ancestor := range c.Ancestors {
+		if ancestor == id {
+			return true
+		}
+	}
+	return false
+}
+
+func categoryID(c models.Category) primitive.ObjectID { return c.ID }
+
+func categoryDeletion(c *models.Category) *models.SoftDelete { return &c.SoftDelete }
+
+// categoryConflict mirrors the unique index on the name of live categorys
+func categoryConflict(a, b *models.Category) error {
+	if a.Name == b.Name {
+		return &DuplicateError{Field: "name"}
+	}
+	return nil
+}
+
+func (r *MemoryCategoryRepository) Update(ctx context.Context, id primitive.ObjectID, category *models.Category) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.categories {
+		if c.Name == category.Name && c.ID != id && !c.IsDeleted() {
+			return &DuplicateError{Field: "name"}
+		}
+	}
+	for i, c := range r.categories {
+		if c.ID == id && !c.IsDeleted() {
+			r.categories[i].Name = category.Name
+			r.categories[i].Description = category.Description
+			r.categories[i].Status = category.Status
+			r.categories[i].Attributes = category.Attributes
+			return nil
+		}
+	}
+	return ErrNotFound
+}
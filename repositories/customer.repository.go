@@ -0,0 +1,216 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type CustomerRepository interface {
+	Create(ctx context.Context, customer *models.Customer) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Customer, error)
+	FindByEmail(ctx context.Context, email string) (*models.Customer, error)
+	FindAll(ctx context.Context, page PageRequest) (*Page[models.Customer], error)
+	Update(ctx context.Context, customer *models.Customer) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// MongoCustomerRepository stores customers in the "customers" collection
+type MongoCustomerRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoCustomerRepository(db *mongo.Database) *MongoCustomerRepository {
+	return &MongoCustomerRepository{collection: db.Collection("customers")}
+}
+
+func (r *MongoCustomerRepository) Create(ctx context.Context, customer *models.Customer) error {
+	if customer.ID.IsZero() {
+		customer.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, customer)
+	return mongoError(err)
+}
+
+func (r *MongoCustomerRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Customer, error) {
+	var customer models.Customer
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&customer); err != nil {
+		return nil, mongoError(err)
+	}
+	return &customer, nil
+}
+
+func (r *MongoCustomerRepository) FindByEmail(ctx context.Context, email string) (*models.Customer, error) {
+	var customer models.Customer
+	if err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&customer); err != nil {
+		return nil, mongoError(err)
+	}
+	return &customer, nil
+}
+
+func (r *MongoCustomerRepository) FindAll(ctx context.Context, page PageRequest) (*Page[models.Customer], error) {
+	total, err := r.collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Find(ctx, page.withCursor(bson.M{}), page.findOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var customers []models.Customer
+	if err := cursor.All(ctx, &customers); err != nil {
+		return nil, err
+	}
+	return newPage(customers, total, page.Limit, false, customerID), nil
+}
+
+func (r *MongoCustomerRepository) Update(ctx context.Context, customer *models.Customer) error {
+	update := bson.M{"$set": bson.M{
+		"name":       customer.Name,
+		"email":      customer.Email,
+		"phone":      customer.Phone,
+		"address":    customer.Address,
+		"updated_at": customer.UpdatedAt,
+		"updated_by": customer.UpdatedBy,
+	}}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": customer.ID}, update)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MongoCustomerRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MemoryCustomerRepository keeps customers in memory, enforcing the same unique email index
+type MemoryCustomerRepository struct {
+	mu        sync.RWMutex
+	customers []models.Customer
+}
+
+func NewMemoryCustomerRepository() *MemoryCustomerRepository {
+	return &MemoryCustomerRepository{}
+}
+
+func customerID(c models.Customer) primitive.ObjectID { return c.ID }
+
+func (r *MemoryCustomerRepository) Create(ctx context.Context, customer *models.Customer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.customers {
+		if c.Email == customer.Email || c.ID == customer.ID {
+			return &DuplicateError{Field: "email"}
+		}
+	}
+	if customer.ID.IsZero() {
+		customer.ID = primitive.NewObjectID()
+	}
+	r.customers = append(r.customers, *customer)
+	return nil
+}
+
+func (r *MemoryCustomerRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Customer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.customers {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryCustomerRepository) FindByEmail(ctx context.Context, email string) (*models.Customer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.customers {
+		if c.Email == email {
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryCustomerRepository) FindAll(ctx context.Context, page PageRequest) (*Page[models.Customer], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	customers := append([]models.Customer{}, r.customers...)
+	return paginate(customers, page, customerID), nil
+}
+
+func (r *MemoryCustomerRepository) Update(ctx context.Context, customer *models.Customer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.customers {
+		if c.Email == customer.Email && c.ID != customer.ID {
+			return &DuplicateError{Field: "email"}
+		}
+	}
+	for i, c := range r.customers {
+		if c.ID == customer.ID {
+			r.customers[i].Name = customer.Name
+			r.customers[i].Email = customer.Email
+			r.customers[i].Phone = customer.Phone
+			r.customers[i].Address = customer.Address
+			r.customers[i].UpdatedAt = customer.UpdatedAt
+			r.customers[i].UpdatedBy = customer.UpdatedBy
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryCustomerRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, c := range r.customers {
+		if c.ID == id {
+			r.customers = append(r.customers[:i], r.customers[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// documents converts every customer into a document for MemoryUniqueChecker
+func (r *MemoryCustomerRepository) documents() ([]bson.M, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	docs := []bson.M{}
+	for _, c := range r.customers {
+		doc, err := toDocument(c)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
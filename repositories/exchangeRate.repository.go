@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExchangeRateRepository interface {
+	// FindAll returns the rates from base, ordered by currency
+	FindAll(ctx context.Context, base string) ([]models.ExchangeRate, error)
+	Find(ctx context.Context, base, currency string) (*models.ExchangeRate, error)
+	// Save creates or replaces the rate between its base and currency
+	Save(ctx context.Context, rate *models.ExchangeRate) error
+	Delete(ctx context.Context, base, currency string) error
+}
+
+// MongoExchangeRateRepository stores rates in the "exchange_rates" collection
+type MongoExchangeRateRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoExchangeRateRepository(db *mongo.Database) *MongoExchangeRateRepository {
+	return &MongoExchangeRateRepository{collection: db.Collection("exchange_rates")}
+}
+
+func (r *MongoExchangeRateRepository) FindAll(ctx context.Context, base string) ([]models.ExchangeRate, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"base": base}, options.Find().SetSort(bson.D{{"currency", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rates := []models.ExchangeRate{}
+	if err := cursor.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (r *MongoExchangeRateRepository) Find(ctx context.Context, base, currency string) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	if err := r.collection.FindOne(ctx, bson.M{"base": base, "currency": currency}).Decode(&rate); err != nil {
+		return nil, mongoError(err)
+	}
+	return &rate, nil
+}
+
+func (r *MongoExchangeRateRepository) Save(ctx context.Context, rate *models.ExchangeRate) error {
+	update := bson.M{
+		"$set": bson.M{
+			"rate":       rate.Rate,
+			"source":     rate.Source,
+			"updated_at": rate.UpdatedAt,
+			"updated_by": rate.UpdatedBy,
+		},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"base": rate.Base, "currency": rate.Currency}, update, opts).Decode(rate)
+	return mongoError(err)
+}
+
+func (r *MongoExchangeRateRepository) Delete(ctx context.Context, base, currency string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"base": base, "currency": currency})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MemoryExchangeRateRepository keeps rates in memory
+type MemoryExchangeRateRepository struct {
+	mu    sync.RWMutex
+	rates []models.ExchangeRate
+}
+
+func NewMemoryExchangeRateRepository() *MemoryExchangeRateRepository {
+	return &MemoryExchangeRateRepository{}
+}
+
+func (r *MemoryExchangeRateRepository) FindAll(ctx context.Context, base string) ([]models.ExchangeRate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rates := []models.ExchangeRate{}
+	for _, rate := range r.rates {
+		if rate.Base == base {
+			rates = append(rates, rate)
+		}
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Currency < rates[j].Currency })
+	return rates, nil
+}
+
+func (r *MemoryExchangeRateRepository) Find(ctx context.Context, base, currency string) (*models.ExchangeRate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, rate := range r.rates {
+		if rate.Base == base && rate.Currency == currency {
+			return &rate, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryExchangeRateRepository) Save(ctx context.Context, rate *models.ExchangeRate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.rates {
+		if existing.Base == rate.Base && existing.Currency == rate.Currency {
+			rate.ID = existing.ID
+			r.rates[i] = *rate
+			return nil
+		}
+	}
+	rate.ID = primitive.NewObjectID()
+	r.rates = append(r.rates, *rate)
+	return nil
+}
+
+func (r *MemoryExchangeRateRepository) Delete(ctx context.Context, base, currency string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, rate := range r.rates {
+		if rate.Base == base && rate.Currency == currency {
+			r.rates = append(r.rates[:i], r.rates[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,511 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrInsufficientStock is returned when a change would use more than the available stock
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrReservationClosed is returned when a reservation was already committed or released
+	ErrReservationClosed = errors.New("reservation is no longer active")
+)
+
+// InventoryRepository keeps the stock levels, the reservations and the stock ledger.
+// Each change of a level is a conditional update, so concurrent requests can never
+// take more than the available stock, and is recorded in the ledger.
+type InventoryRepository interface {
+	// Levels returns the stock of a product, and of each of its variants, in each warehouse
+	Levels(ctx context.Context, productID primitive.ObjectID) ([]models.StockLevel, error)
+	// LowStock returns a page of the levels whose available quantity reached their threshold
+	LowStock(ctx context.Context, page PageRequest) (*Page[models.StockLevel], error)
+	SetThreshold(ctx context.Context, productID primitive.ObjectID, sku, warehouse string, threshold int) error
+	// Adjust applies movement.Quantity to the quantity on hand and records the movement.
+	// A decrease fails with ErrInsufficientStock if it would use reserved stock.
+	Adjust(ctx context.Context, movement *models.StockMovement) error
+	// Reserve holds the quantity of reservation.SKU in the first warehouse, by name, with
+	// enough available stock and sets reservation.Warehouse; ErrInsufficientStock if there is none
+	Reserve(ctx context.Context, reservation *models.Reservation) error
+	// Release gives back the stock of an active reservation
+	Release(ctx context.Context, reservation *models.Reservation, by *primitive.ObjectID) error
+	// Commit turns an active reservation into a sale
+	Commit(ctx context.Context, reservation *models.Reservation, by *primitive.ObjectID) error
+	FindReservations(ctx context.Context, orderID primitive.ObjectID) ([]models.Reservation, error)
+	// FindExpiredReservations returns up to limit active reservations expired before now
+	FindExpiredReservations(ctx context.Context, now time.Time, limit int) ([]models.Reservation, error)
+	Movements(ctx context.Context, productID primitive.ObjectID, page PageRequest) (*Page[models.StockMovement], error)
+}
+
+// MongoInventoryRepository uses the "stock_levels", "reservations" and "stock_movements" collections
+type MongoInventoryRepository struct {
+	levels       *mongo.Collection
+	reservations *mongo.Collection
+	movements    *mongo.Collection
+}
+
+func NewMongoInventoryRepository(db *mongo.Database) *MongoInventoryRepository {
+	return &MongoInventoryRepository{
+		levels:       db.Collection("stock_levels"),
+		reservations: db.Collection("reservations"),
+		movements:    db.Collection("stock_movements"),
+	}
+}
+
+// levelFilter matches the level of a product or variant in a warehouse. Levels of
+// products without variants have no SKU, which a nil value matches.
+func levelFilter(productID primitive.ObjectID, sku, warehouse string) bson.M {
+	filter := bson.M{"product_id": productID, "sku": nil, "warehouse": warehouse}
+	if sku != "" {
+		filter["sku"] = sku
+	}
+	return filter
+}
+
+// availableAtLeast matches the levels with at least quantity available
+func availableAtLeast(quantity int) bson.M {
+	return bson.M{"$expr": bson.M{"$gte": bson.A{bson.M{"$subtract": bson.A{"$on_hand", "$reserved"}}, quantity}}}
+}
+
+func (r *MongoInventoryRepository) Levels(ctx context.Context, productID primitive.ObjectID) ([]models.StockLevel, error) {
+	cursor, err := r.levels.Find(ctx, bson.M{"product_id": productID}, options.Find().SetSort(bson.D{{"sku", 1}, {"warehouse", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	levels := []models.StockLevel{}
+	if err := cursor.All(ctx, &levels); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+func (r *MongoInventoryRepository) LowStock(ctx context.Context, page PageRequest) (*Page[models.StockLevel], error) {
+	filter := bson.M{
+		"low_stock_threshold": bson.M{"$gt": 0},
+		"$expr":               bson.M{"$lte": bson.A{bson.M{"$subtract": bson.A{"$on_hand", "$reserved"}}, "$low_stock_threshold"}},
+	}
+	total, err := r.levels.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.levels.Find(ctx, page.withCursor(filter), page.findOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var levels []models.StockLevel
+	if err := cursor.All(ctx, &levels); err != nil {
+		return nil, err
+	}
+	return newPage(levels, total, page.Limit, false, stockLevelID), nil
+}
+
+func (r *MongoInventoryRepository) SetThreshold(ctx context.Context, productID primitive.ObjectID, sku, warehouse string, threshold int) error {
+	update := bson.M{
+		"$set":         bson.M{"low_stock_threshold": threshold, "updated_at": primitive.NewDateTimeFromTime(time.Now())},
+		"$setOnInsert": bson.M{"on_hand": 0, "reserved": 0},
+	}
+	_, err := r.levels.UpdateOne(ctx, levelFilter(productID, sku, warehouse), update, options.Update().SetUpsert(true))
+	return mongoError(err)
+}
+
+// change applies the deltas to a level. Decreases only match a level with enough stock
+// available; increases of the quantity on hand create the level when needed.
+func (r *MongoInventoryRepository) change(ctx context.Context, productID primitive.ObjectID, sku, warehouse string, onHand, reserved int) error {
+	filter := levelFilter(productID, sku, warehouse)
+	if need := reserved - onHand; need > 0 {
+		for key, value := range availableAtLeast(need) {
+			filter[key] = value
+		}
+	}
+	// A release or a sale must not take back more than what is reserved
+	if reserved < 0 {
+		filter["reserved"] = bson.M{"$gte": -reserved}
+	}
+
+	update := bson.M{
+		"$inc": bson.M{"on_hand": onHand, "reserved": reserved},
+		"$set": bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())},
+	}
+	opts := options.Update()
+	if onHand > 0 && reserved == 0 {
+		update["$setOnInsert"] = bson.M{"low_stock_threshold": 0}
+		opts.SetUpsert(true)
+	}
+
+	result, err := r.levels.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 && result.UpsertedCount == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+func (r *MongoInventoryRepository) record(ctx context.Context, movement *models.StockMovement) error {
+	if movement.ID.IsZero() {
+		movement.ID = primitive.NewObjectID()
+	}
+	movement.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	_, err := r.movements.InsertOne(ctx, movement)
+	return mongoError(err)
+}
+
+func (r *MongoInventoryRepository) Adjust(ctx context.Context, movement *models.StockMovement) error {
+	if err := r.change(ctx, movement.ProductID, movement.SKU, movement.Warehouse, movement.Quantity, 0); err != nil {
+		return err
+	}
+	return r.record(ctx, movement)
+}
+
+func (r *MongoInventoryRepository) Reserve(ctx context.Context, reservation *models.Reservation) error {
+	levels, err := r.Levels(ctx, reservation.ProductID)
+	if err != nil {
+		return err
+	}
+
+	for _, level := range levels {
+		if level.SKU != reservation.SKU || level.Available() < reservation.Quantity {
+			continue
+		}
+		err := r.change(ctx, reservation.ProductID, reservation.SKU, level.Warehouse, 0, reservation.Quantity)
+		if errors.Is(err, ErrInsufficientStock) {
+			// Taken meanwhile by another request, try the next warehouse
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		reservation.Warehouse = level.Warehouse
+		reservation.Status = models.ReservationActive
+		if reservation.ID.IsZero() {
+			reservation.ID = primitive.NewObjectID()
+		}
+		reservation.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+		if _, err := r.reservations.InsertOne(ctx, reservation); err != nil {
+			return mongoError(err)
+		}
+		return r.record(ctx, reservationMovement(reservation, models.MovementReservation, 0, reservation.Quantity, nil))
+	}
+	return ErrInsufficientStock
+}
+
+// close moves an active reservation to status, so it is released or committed only once
+func (r *MongoInventoryRepository) close(ctx context.Context, reservation *models.Reservation, status string) error {
+	result, err := r.reservations.UpdateOne(ctx,
+		bson.M{"_id": reservation.ID, "status": models.ReservationActive},
+		bson.M{"$set": bson.M{"status": status}})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrReservationClosed
+	}
+	reservation.Status = status
+	return nil
+}
+
+func (r *MongoInventoryRepository) Release(ctx context.Context, reservation *models.Reservation, by *primitive.ObjectID) error {
+	if err := r.close(ctx, reservation, models.ReservationReleased); err != nil {
+		return err
+	}
+	if err := r.change(ctx, reservation.ProductID, reservation.SKU, reservation.Warehouse, 0, -reservation.Quantity); err != nil {
+		return err
+	}
+	return r.record(ctx, reservationMovement(reservation, models.MovementRelease, 0, -reservation.Quantity, by))
+}
+
+func (r *MongoInventoryRepository) Commit(ctx context.Context, reservation *models.Reservation, by *primitive.ObjectID) error {
+	if err := r.close(ctx, reservation, models.ReservationCommitted); err != nil {
+		return err
+	}
+	if err := r.change(ctx, reservation.ProductID, reservation.SKU, reservation.Warehouse, -reservation.Quantity, -reservation.Quantity); err != nil {
+		return err
+	}
+	return r.record(ctx, reservationMovement(reservation, models.MovementSale, -reservation.Quantity, -reservation.Quantity, by))
+}
+
+func (r *MongoInventoryRepository) FindReservations(ctx context.Context, orderID primitive.ObjectID) ([]models.Reservation, error) {
+	return r.findReservations(ctx, bson.M{"order_id": orderID}, options.Find())
+}
+
+func (r *MongoInventoryRepository) FindExpiredReservations(ctx context.Context, now time.Time, limit int) ([]models.Reservation, error) {
+	filter := bson.M{"status": models.ReservationActive, "expires_at": bson.M{"$lt": primitive.NewDateTimeFromTime(now)}}
+	return r.findReservations(ctx, filter, options.Find().SetSort(bson.D{{"expires_at", 1}}).SetLimit(int64(limit)))
+}
+
+func (r *MongoInventoryRepository) findReservations(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.Reservation, error) {
+	cursor, err := r.reservations.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	reservations := []models.Reservation{}
+	if err := cursor.All(ctx, &reservations); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+func (r *MongoInventoryRepository) Movements(ctx context.Context, productID primitive.ObjectID, page PageRequest) (*Page[models.StockMovement], error) {
+	filter := bson.M{"product_id": productID}
+	total, err := r.movements.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.movements.Find(ctx, page.withCursor(filter), page.findOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var movements []models.StockMovement
+	if err := cursor.All(ctx, &movements); err != nil {
+		return nil, err
+	}
+	return newPage(movements, total, page.Limit, false, stockMovementID), nil
+}
+
+// reservationMovement is the ledger entry of a reservation change
+func reservationMovement(reservation *models.Reservation, movementType string, onHand, reserved int, by *primitive.ObjectID) *models.StockMovement {
+	orderID := reservation.OrderID
+	return &models.StockMovement{
+		ProductID:      reservation.ProductID,
+		SKU:            reservation.SKU,
+		Warehouse:      reservation.Warehouse,
+		Type:           movementType,
+		Quantity:       onHand,
+		ReservedChange: reserved,
+		OrderID:        &orderID,
+		CreatedBy:      by,
+	}
+}
+
+func stockLevelID(l models.StockLevel) primitive.ObjectID { return l.ID }
+
+func stockMovementID(m models.StockMovement) primitive.ObjectID { return m.ID }
+
+// MemoryInventoryRepository keeps the inventory in memory behind a single lock
+type MemoryInventoryRepository struct {
+	mu           sync.RWMutex
+	levels       []models.StockLevel
+	reservations []models.Reservation
+	movements    []models.StockMovement
+}
+
+func NewMemoryInventoryRepository() *MemoryInventoryRepository {
+	return &MemoryInventoryRepository{}
+}
+
+func (r *MemoryInventoryRepository) Levels(ctx context.Context, productID primitive.ObjectID) ([]models.StockLevel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	levels := []models.StockLevel{}
+	for _, l := range r.levels {
+		if l.ProductID == productID {
+			levels = append(levels, l)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if levels[i].SKU != levels[j].SKU {
+			return levels[i].SKU < levels[j].SKU
+		}
+		return levels[i].Warehouse < levels[j].Warehouse
+	})
+	return levels, nil
+}
+
+func (r *MemoryInventoryRepository) LowStock(ctx context.Context, page PageRequest) (*Page[models.StockLevel], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var levels []models.StockLevel
+	for _, l := range r.levels {
+		if l.IsLow() {
+			levels = append(levels, l)
+		}
+	}
+	return paginate(levels, page, stockLevelID), nil
+}
+
+// level returns the level of the product or variant in the warehouse, creating it if asked to
+func (r *MemoryInventoryRepository) level(productID primitive.ObjectID, sku, warehouse string, create bool) *models.StockLevel {
+	for i := range r.levels {
+		if r.levels[i].ProductID == productID && r.levels[i].SKU == sku && r.levels[i].Warehouse == warehouse {
+			return &r.levels[i]
+		}
+	}
+	if !create {
+		return nil
+	}
+	r.levels = append(r.levels, models.StockLevel{ID: primitive.NewObjectID(), ProductID: productID, SKU: sku, Warehouse: warehouse})
+	return &r.levels[len(r.levels)-1]
+}
+
+func (r *MemoryInventoryRepository) SetThreshold(ctx context.Context, productID primitive.ObjectID, sku, warehouse string, threshold int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	level := r.level(productID, sku, warehouse, true)
+	level.LowStockThreshold = threshold
+	level.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	return nil
+}
+
+// change mirrors MongoInventoryRepository.change, the lock must be held
+func (r *MemoryInventoryRepository) change(productID primitive.ObjectID, sku, warehouse string, onHand, reserved int) error {
+	level := r.level(productID, sku, warehouse, onHand > 0 && reserved == 0)
+	if level == nil || level.Available() < reserved-onHand || level.Reserved < -reserved {
+		return ErrInsufficientStock
+	}
+	level.OnHand += onHand
+	level.Reserved += reserved
+	level.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	return nil
+}
+
+func (r *MemoryInventoryRepository) record(movement *models.StockMovement) {
+	if movement.ID.IsZero() {
+		movement.ID = primitive.NewObjectID()
+	}
+	movement.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	r.movements = append(r.movements, *movement)
+}
+
+func (r *MemoryInventoryRepository) Adjust(ctx context.Context, movement *models.StockMovement) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.change(movement.ProductID, movement.SKU, movement.Warehouse, movement.Quantity, 0); err != nil {
+		return err
+	}
+	r.record(movement)
+	return nil
+}
+
+func (r *MemoryInventoryRepository) Reserve(ctx context.Context, reservation *models.Reservation) error {
+	levels, err := r.Levels(ctx, reservation.ProductID)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, level := range levels {
+		if level.SKU != reservation.SKU {
+			continue
+		}
+		if err := r.change(reservation.ProductID, reservation.SKU, level.Warehouse, 0, reservation.Quantity); err != nil {
+			continue
+		}
+		reservation.Warehouse = level.Warehouse
+		reservation.Status = models.ReservationActive
+		if reservation.ID.IsZero() {
+			reservation.ID = primitive.NewObjectID()
+		}
+		reservation.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+		r.reservations = append(r.reservations, *reservation)
+		r.record(reservationMovement(reservation, models.MovementReservation, 0, reservation.Quantity, nil))
+		return nil
+	}
+	return ErrInsufficientStock
+}
+
+// close mirrors MongoInventoryRepository.close, the lock must be held
+func (r *MemoryInventoryRepository) close(reservation *models.Reservation, status string) error {
+	for i := range r.reservations {
+		if r.reservations[i].ID == reservation.ID && r.reservations[i].Status == models.ReservationActive {
+			r.reservations[i].Status = status
+			reservation.Status = status
+			return nil
+		}
+	}
+	return ErrReservationClosed
+}
+
+func (r *MemoryInventoryRepository) Release(ctx context.Context, reservation *models.Reservation, by *primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.close(reservation, models.ReservationReleased); err != nil {
+		return err
+	}
+	if err := r.change(reservation.ProductID, reservation.SKU, reservation.Warehouse, 0, -reservation.Quantity); err != nil {
+		return err
+	}
+	r.record(reservationMovement(reservation, models.MovementRelease, 0, -reservation.Quantity, by))
+	return nil
+}
+
+func (r *MemoryInventoryRepository) Commit(ctx context.Context, reservation *models.Reservation, by *primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.close(reservation, models.ReservationCommitted); err != nil {
+		return err
+	}
+	if err := r.change(reservation.ProductID, reservation.SKU, reservation.Warehouse, -reservation.Quantity, -reservation.Quantity); err != nil {
+		return err
+	}
+	r.record(reservationMovement(reservation, models.MovementSale, -reservation.Quantity, -reservation.Quantity, by))
+	return nil
+}
+
+func (r *MemoryInventoryRepository) FindReservations(ctx context.Context, orderID primitive.ObjectID) ([]models.Reservation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reservations := []models.Reservation{}
+	for _, res := range r.reservations {
+		if res.OrderID == orderID {
+			reservations = append(reservations, res)
+		}
+	}
+	return reservations, nil
+}
+
+func (r *MemoryInventoryRepository) FindExpiredReservations(ctx context.Context, now time.Time, limit int) ([]models.Reservation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	expired := []models.Reservation{}
+	for _, res := range r.reservations {
+		if res.Status == models.ReservationActive && res.ExpiresAt.Time().Before(now) && len(expired) < limit {
+			expired = append(expired, res)
+		}
+	}
+	return expired, nil
+}
+
+func (r *MemoryInventoryRepository) Movements(ctx context.Context, productID primitive.ObjectID, page PageRequest) (*Page[models.StockMovement], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var movements []models.StockMovement
+	for _, m := range r.movements {
+		if m.ProductID == productID {
+			movements = append(movements, m)
+		}
+	}
+	return paginate(movements, page, stockMovementID), nil
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// LockoutEventRepository is the audit log of login lockouts
+type LockoutEventRepository interface {
+	Create(ctx context.Context, event *models.LockoutEvent) error
+	FindAll(ctx context.Context, page PageRequest) (*Page[models.LockoutEvent], error)
+}
+
+// MongoLockoutEventRepository stores events in the "lockout_events" collection
+type MongoLockoutEventRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLockoutEventRepository(db *mongo.Database) *MongoLockoutEventRepository {
+	return &MongoLockoutEventRepository{collection: db.Collection("lockout_events")}
+}
+
+func (r *MongoLockoutEventRepository) Create(ctx context.Context, event *models.LockoutEvent) error {
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	event.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	_, err := r.collection.InsertOne(ctx, event)
+	return mongoError(err)
+}
+
+func (r *MongoLockoutEventRepository) FindAll(ctx context.Context, page PageRequest) (*Page[models.LockoutEvent], error) {
+	total, err := r.collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Find(ctx, page.withCursor(bson.M{}), page.findOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []models.LockoutEvent
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return newPage(events, total, page.Limit, false, lockoutEventID), nil
+}
+
+func lockoutEventID(e models.LockoutEvent) primitive.ObjectID { return e.ID }
+
+// MemoryLockoutEventRepository keeps events in memory
+type MemoryLockoutEventRepository struct {
+	mu     sync.RWMutex
+	events []models.LockoutEvent
+}
+
+func NewMemoryLockoutEventRepository() *MemoryLockoutEventRepository {
+	return &MemoryLockoutEventRepository{}
+}
+
+func (r *MemoryLockoutEventRepository) Create(ctx context.Context, event *models.LockoutEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	event.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func (r *MemoryLockoutEventRepository) FindAll(ctx context.Context, page PageRequest) (*Page[models.LockoutEvent], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := append([]models.LockoutEvent(nil), r.events...)
+	return paginate(events, page, lockoutEventID), nil
+}
@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrThrottled is returned for attempts made during a backoff or a lockout
+var ErrThrottled = errors.New("too many failed attempts")
+
+// LoginThrottleRepository stores the failed login counters
+type LoginThrottleRepository interface {
+	// Attempt counts a login attempt before its password is checked, so concurrent
+	// attempts cannot slip past the limits. Failures older than the window or than an
+	// ended lockout are forgotten first. While the counter is blocked the attempt is
+	// not counted and ErrThrottled is returned with the counter.
+	Attempt(ctx context.Context, scope, key string, now time.Time, window time.Duration) (*models.LoginThrottle, error)
+	// Block starts a backoff until retryAt and, unless lockedUntil is zero, a lockout
+	Block(ctx context.Context, scope, key string, retryAt, lockedUntil time.Time) error
+	// Release takes back an attempt that turned out to succeed
+	Release(ctx context.Context, scope, key string) error
+	// Reset deletes a counter and returns it, or ErrNotFound when there is none
+	Reset(ctx context.Context, scope, key string) (*models.LoginThrottle, error)
+}
+
+// MongoLoginThrottleRepository stores counters in the "login_throttles" collection,
+// a TTL index on expires_at removes the forgotten ones
+type MongoLoginThrottleRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLoginThrottleRepository(db *mongo.Database) *MongoLoginThrottleRepository {
+	return &MongoLoginThrottleRepository{collection: db.Collection("login_throttles")}
+}
+
+func (r *MongoLoginThrottleRepository) Attempt(ctx context.Context, scope, key string, now time.Time, window time.Duration) (*models.LoginThrottle, error) {
+	at := primitive.NewDateTimeFromTime(now)
+	filter := bson.M{
+		"scope":        scope,
+		"key":          key,
+		"retry_at":     bson.M{"$not": bson.M{"$gt": at}},
+		"locked_until": bson.M{"$not": bson.M{"$gt": at}},
+	}
+	// A lockout still set on a counter passing the filter has ended
+	stale := bson.M{"$or": bson.A{
+		bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$expires_at", nil}}, at}},
+		bson.M{"$ne": bson.A{bson.M{"$ifNull": bson.A{"$locked_until", nil}}, nil}},
+	}}
+	update := mongo.Pipeline{{{"$set", bson.M{
+		"failures":     bson.M{"$cond": bson.A{stale, 1, bson.M{"$add": bson.A{"$failures", 1}}}},
+		"retry_at":     bson.M{"$cond": bson.A{stale, "$$REMOVE", "$retry_at"}},
+		"locked_until": bson.M{"$cond": bson.A{stale, "$$REMOVE", "$locked_until"}},
+		"updated_at":   at,
+		"expires_at":   primitive.NewDateTimeFromTime(now.Add(window)),
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var throttle models.LoginThrottle
+	err := mongoError(r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&throttle))
+	if !errors.Is(err, ErrDuplicate) {
+		if err != nil {
+			return nil, err
+		}
+		return &throttle, nil
+	}
+
+	// The counter exists but the filter refused it: it is blocked
+	err = r.collection.FindOne(ctx, bson.M{"scope": scope, "key": key}).Decode(&throttle)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Reset in the meantime
+		return r.Attempt(ctx, scope, key, now, window)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, ErrThrottled
+}
+
+func (r *MongoLoginThrottleRepository) Block(ctx context.Context, scope, key string, retryAt, lockedUntil time.Time) error {
+	set := bson.M{"retry_at": primitive.NewDateTimeFromTime(retryAt)}
+	update := bson.M{"$set": set}
+	if !lockedUntil.IsZero() {
+		set["locked_until"] = primitive.NewDateTimeFromTime(lockedUntil)
+		// The failures are remembered at least as long as the lockout lasts
+		update["$max"] = bson.M{"expires_at": primitive.NewDateTimeFromTime(lockedUntil)}
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"scope": scope, "key": key}, update)
+	return err
+}
+
+func (r *MongoLoginThrottleRepository) Release(ctx context.Context, scope, key string) error {
+	filter := bson.M{"scope": scope, "key": key, "failures": bson.M{"$gt": 0}}
+	_, err := r.collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"failures": -1}})
+	return err
+}
+
+func (r *MongoLoginThrottleRepository) Reset(ctx context.Context, scope, key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	if err := r.collection.FindOneAndDelete(ctx, bson.M{"scope": scope, "key": key}).Decode(&throttle); err != nil {
+		return nil, mongoError(err)
+	}
+	return &throttle, nil
+}
+
+// MemoryLoginThrottleRepository keeps counters in memory
+type MemoryLoginThrottleRepository struct {
+	mu        sync.Mutex
+	throttles map[[2]string]*models.LoginThrottle
+}
+
+func NewMemoryLoginThrottleRepository() *MemoryLoginThrottleRepository {
+	return &MemoryLoginThrottleRepository{throttles: make(map[[2]string]*models.LoginThrottle)}
+}
+
+func (r *MemoryLoginThrottleRepository) Attempt(ctx context.Context, scope, key string, now time.Time, window time.Duration) (*models.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	throttle, ok := r.throttles[[2]string{scope, key}]
+	if !ok {
+		throttle = &models.LoginThrottle{ID: primitive.NewObjectID(), Scope: scope, Key: key}
+		r.throttles[[2]string{scope, key}] = throttle
+	}
+	if _, blocked := throttle.BlockedUntil(now); blocked {
+		copied := *throttle
+		return &copied, ErrThrottled
+	}
+
+	if !throttle.ExpiresAt.Time().After(now) || throttle.LockedUntil != nil {
+		throttle.Failures, throttle.RetryAt, throttle.LockedUntil = 0, nil, nil
+	}
+	throttle.Failures++
+	throttle.UpdatedAt = primitive.NewDateTimeFromTime(now)
+	throttle.ExpiresAt = primitive.NewDateTimeFromTime(now.Add(window))
+
+	copied := *throttle
+	return &copied, nil
+}
+
+func (r *MemoryLoginThrottleRepository) Block(ctx context.Context, scope, key string, retryAt, lockedUntil time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	throttle, ok := r.throttles[[2]string{scope, key}]
+	if !ok {
+		return nil
+	}
+	at := primitive.NewDateTimeFromTime(retryAt)
+	throttle.RetryAt = &at
+	if !lockedUntil.IsZero() {
+		until := primitive.NewDateTimeFromTime(lockedUntil)
+		throttle.LockedUntil = &until
+		if lockedUntil.After(throttle.ExpiresAt.Time()) {
+			throttle.ExpiresAt = until
+		}
+	}
+	return nil
+}
+
+func (r *MemoryLoginThrottleRepository) Release(ctx context.Context, scope, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if throttle, ok := r.throttles[[2]string{scope, key}]; ok && throttle.Failures > 0 {
+		throttle.Failures--
+	}
+	return nil
+}
+
+func (r *MemoryLoginThrottleRepository) Reset(ctx context.Context, scope, key string) (*models.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	throttle, ok := r.throttles[[2]string{scope, key}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(r.throttles, [2]string{scope, key})
+	return throttle, nil
+}
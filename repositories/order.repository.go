@@ -0,0 +1,284 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrOrderStatusChanged is returned when an order no longer has the status a change expected
+var ErrOrderStatusChanged = errors.New("order status changed")
+
+type OrderRepository interface {
+	// Create stores a new order, numbering it when OrderNumber is empty
+	Create(ctx context.Context, order *models.Order) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Order, error)
+	FindAll(ctx context.Context, query ListQuery, page PageRequest) (*Page[models.Order], error)
+	// UpdateItems replaces the customer and items of an order that is still pending
+	UpdateItems(ctx context.Context, order *models.Order) error
+	// UpdateStatus applies a transition if the order is still in change.From, so
+	// concurrent transitions cannot both succeed
+	UpdateStatus(ctx context.Context, id primitive.ObjectID, change models.OrderStatusChange) error
+	CountByCustomer(ctx context.Context, customerID primitive.ObjectID) (int64, error)
+}
+
+// MongoOrderRepository stores orders in the "orders" collection and their
+// sequence in the "counters" collection
+type MongoOrderRepository struct {
+	collection *mongo.Collection
+	counters   *mongo.Collection
+}
+
+func NewMongoOrderRepository(db *mongo.Database) *MongoOrderRepository {
+	return &MongoOrderRepository{collection: db.Collection("orders"), counters: db.Collection("counters")}
+}
+
+// nextNumber increments the order sequence atomically, creating it on first use
+func (r *MongoOrderRepository) nextNumber(ctx context.Context) (string, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := r.counters.FindOneAndUpdate(ctx, bson.M{"_id": "orders"}, bson.M{"$inc": bson.M{"seq": 1}}, opts).Decode(&counter)
+	if err != nil {
+		return "", err
+	}
+	return models.OrderNumber(counter.Seq), nil
+}
+
+func (r *MongoOrderRepository) Create(ctx context.Context, order *models.Order) error {
+	if order.ID.IsZero() {
+		order.ID = primitive.NewObjectID()
+	}
+	if order.OrderNumber == "" {
+		number, err := r.nextNumber(ctx)
+		if err != nil {
+			return err
+		}
+		order.OrderNumber = number
+	}
+	_, err := r.collection.InsertOne(ctx, order)
+	return mongoError(err)
+}
+
+func (r *MongoOrderRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Order, error) {
+	var order models.Order
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&order); err != nil {
+		return nil, mongoError(err)
+	}
+	return &order, nil
+}
+
+func (r *MongoOrderRepository) FindAll(ctx context.Context, query ListQuery, page PageRequest) (*Page[models.Order], error) {
+	match := query.match()
+	total, err := r.collection.CountDocuments(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, page.stages(match, query.sort()))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var orders []models.Order
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return newPage(orders, total, page.Limit, query.Sorted(), orderID), nil
+}
+
+func (r *MongoOrderRepository) UpdateItems(ctx context.Context, order *models.Order) error {
+	update := bson.M{"$set": bson.M{
+		"customer_id": order.CustomerID,
+		"items":       order.Items,
+		"total":       order.Total,
+		"updated_at":  order.UpdatedAt,
+		"updated_by":  order.UpdatedBy,
+	}}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": order.ID, "status": models.OrderPending}, update)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return r.missingOrChanged(ctx, order.ID)
+	}
+	return nil
+}
+
+func (r *MongoOrderRepository) UpdateStatus(ctx context.Context, id primitive.ObjectID, change models.OrderStatusChange) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":     change.To,
+			"updated_at": change.ChangedAt,
+			"updated_by": change.ChangedBy,
+		},
+		"$push": bson.M{"status_history": change},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "status": change.From}, update)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return r.missingOrChanged(ctx, id)
+	}
+	return nil
+}
+
+// missingOrChanged tells apart a conditional update that missed because the order
+// does not exist from one that missed because its status changed
+func (r *MongoOrderRepository) missingOrChanged(ctx context.Context, id primitive.ObjectID) error {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"_id": id}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return ErrOrderStatusChanged
+}
+
+func (r *MongoOrderRepository) CountByCustomer(ctx context.Context, customerID primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"customer_id": customerID})
+}
+
+// MemoryOrderRepository keeps orders in memory, enforcing the same unique order number index
+type MemoryOrderRepository struct {
+	mu     sync.RWMutex
+	orders []models.Order
+	seq    int64
+}
+
+func NewMemoryOrderRepository() *MemoryOrderRepository {
+	return &MemoryOrderRepository{}
+}
+
+func orderID(o models.Order) primitive.ObjectID { return o.ID }
+
+func (r *MemoryOrderRepository) Create(ctx context.Context, order *models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if order.OrderNumber == "" {
+		r.seq++
+		order.OrderNumber = models.OrderNumber(r.seq)
+	}
+	for _, o := range r.orders {
+		if o.OrderNumber == order.OrderNumber || o.ID == order.ID {
+			return &DuplicateError{Field: "order_number"}
+		}
+	}
+	if order.ID.IsZero() {
+		order.ID = primitive.NewObjectID()
+	}
+	r.orders = append(r.orders, *order)
+	return nil
+}
+
+func (r *MemoryOrderRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, o := range r.orders {
+		if o.ID == id {
+			return &o, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryOrderRepository) FindAll(ctx context.Context, query ListQuery, page PageRequest) (*Page[models.Order], error) {
+	r.mu.RLock()
+	orders := make(map[primitive.ObjectID]models.Order, len(r.orders))
+	docs := make([]bson.M, 0, len(r.orders))
+	for _, o := range r.orders {
+		doc, err := toDocument(o)
+		if err != nil {
+			r.mu.RUnlock()
+			return nil, err
+		}
+		if query.matches(doc) {
+			orders[o.ID] = o
+			docs = append(docs, doc)
+		}
+	}
+	r.mu.RUnlock()
+
+	var result *Page[bson.M]
+	if query.Sorted() {
+		query.sortDocuments(docs)
+		result = slicePage(docs, page.Offset, page.Limit, true, documentID)
+	} else {
+		result = paginate(docs, page, documentID)
+	}
+
+	items := make([]models.Order, 0, len(result.Items))
+	for _, doc := range result.Items {
+		items = append(items, orders[documentID(doc)])
+	}
+	return &Page[models.Order]{Items: items, Total: result.Total, HasMore: result.HasMore, Next: result.Next}, nil
+}
+
+func (r *MemoryOrderRepository) UpdateItems(ctx context.Context, order *models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, o := range r.orders {
+		if o.ID != order.ID {
+			continue
+		}
+		if o.Status != models.OrderPending {
+			return ErrOrderStatusChanged
+		}
+		r.orders[i].CustomerID = order.CustomerID
+		r.orders[i].Items = order.Items
+		r.orders[i].Total = order.Total
+		r.orders[i].UpdatedAt = order.UpdatedAt
+		r.orders[i].UpdatedBy = order.UpdatedBy
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryOrderRepository) UpdateStatus(ctx context.Context, id primitive.ObjectID, change models.OrderStatusChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, o := range r.orders {
+		if o.ID != id {
+			continue
+		}
+		if o.Status != change.From {
+			return ErrOrderStatusChanged
+		}
+		r.orders[i].Status = change.To
+		r.orders[i].UpdatedAt = change.ChangedAt
+		r.orders[i].UpdatedBy = change.ChangedBy
+		r.orders[i].StatusHistory = append(r.orders[i].StatusHistory, change)
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryOrderRepository) CountByCustomer(ctx context.Context, customerID primitive.ObjectID) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, o := range r.orders {
+		if o.CustomerID == customerID {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PageRequest selects a page either by offset or, when After is set, by cursor.
+// Results are ordered by _id unless a ListQuery sorts them, so both modes are stable.
+type PageRequest struct {
+	Limit  int
+	Offset int
+	After  primitive.ObjectID
+}
+
+// Page is one page of results. Next is the cursor of the following page; it is
+// NilObjectID on the last page or when the results use a custom sort.
+type Page[T any] struct {
+	Items   []T
+	Total   int64
+	HasMore bool
+	Next    primitive.ObjectID
+}
+
+// withCursor restricts the filter to documents after the cursor
+func (p PageRequest) withCursor(filter bson.M) bson.M {
+	if !p.After.IsZero() {
+		filter["_id"] = bson.M{"$gt": p.After}
+	}
+	return filter
+}
+
+// findOptions returns the sort, skip and limit options of the page for Find.
+// One extra document is fetched to know whether a next page exists.
+func (p PageRequest) findOptions() *options.FindOptions {
+	opts := options.Find().SetSort(bson.D{{"_id", 1}}).SetLimit(int64(p.Limit + 1))
+	if p.After.IsZero() && p.Offset > 0 {
+		opts.SetSkip(int64(p.Offset))
+	}
+	return opts
+}
+
+// stages is the aggregation equivalent of withCursor and findOptions
+// for documents matching the filter in the given order
+func (p PageRequest) stages(match bson.M, sort bson.D) mongo.Pipeline {
+	pipeline := mongo.Pipeline{
+		{{"$match", p.withCursor(match)}},
+		{{"$sort", sort}},
+	}
+	if p.After.IsZero() && p.Offset > 0 {
+		pipeline = append(pipeline, bson.D{{"$skip", p.Offset}})
+	}
+	return append(pipeline, bson.D{{"$limit", p.Limit + 1}})
+}
+
+// newPage trims the extra document fetched by stages and sets the next cursor
+func newPage[T any](items []T, total int64, limit int, sorted bool, id func(T) primitive.ObjectID) *Page[T] {
+	page := &Page[T]{Items: items, Total: total}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.HasMore = true
+		if !sorted {
+			page.Next = id(page.Items[limit-1])
+		}
+	}
+	return page
+}
+
+// paginate applies a page request to in-memory items ordered by _id
+func paginate[T any](items []T, p PageRequest, id func(T) primitive.ObjectID) *Page[T] {
+	sort.Slice(items, func(i, j int) bool {
+		return id(items[i]).Hex() < id(items[j]).Hex()
+	})
+
+	start := p.Offset
+	if !p.After.IsZero() {
+		start = sort.Search(len(items), func(i int) bool {
+			return id(items[i]).Hex() > p.After.Hex()
+		})
+	}
+	return slicePage(items, start, p.Limit, false, id)
+}
+
+// slicePage returns limit items from start, already ordered
+func slicePage[T any](items []T, start, limit int, sorted bool, id func(T) primitive.ObjectID) *Page[T] {
+	total := int64(len(items))
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + limit + 1
+	if end > len(items) {
+		end = len(items)
+	}
+	return newPage(items[start:end], total, limit, sorted, id)
+}
@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrPaymentStatusChanged is returned when a payment left the status an update expected
+var ErrPaymentStatusChanged = errors.New("payment status changed")
+
+type PaymentRepository interface {
+	Create(ctx context.Context, payment *models.Payment) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Payment, error)
+	FindByIntent(ctx context.Context, provider, intentID string) (*models.Payment, error)
+	// FindByOrder returns the attempts of an order, oldest first
+	FindByOrder(ctx context.Context, orderID primitive.ObjectID) ([]models.Payment, error)
+	// UpdateStatus stores the status, failure reason and refund of the payment if it
+	// is still in status from
+	UpdateStatus(ctx context.Context, payment *models.Payment, from string) error
+	// RecordEvent stores a handled webhook event, returning ErrDuplicate if it was
+	// handled before
+	RecordEvent(ctx context.Context, event *models.PaymentEvent) error
+}
+
+// MongoPaymentRepository stores payments in the "payments" collection and the
+// handled webhook events in "payment_events"
+type MongoPaymentRepository struct {
+	collection *mongo.Collection
+	events     *mongo.Collection
+}
+
+func NewMongoPaymentRepository(db *mongo.Database) *MongoPaymentRepository {
+	return &MongoPaymentRepository{collection: db.Collection("payments"), events: db.Collection("payment_events")}
+}
+
+func (r *MongoPaymentRepository) Create(ctx context.Context, payment *models.Payment) error {
+	if payment.ID.IsZero() {
+		payment.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, payment)
+	return mongoError(err)
+}
+
+func (r *MongoPaymentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Payment, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *MongoPaymentRepository) FindByIntent(ctx context.Context, provider, intentID string) (*models.Payment, error) {
+	return r.findOne(ctx, bson.M{"provider": provider, "intent_id": intentID})
+}
+
+func (r *MongoPaymentRepository) findOne(ctx context.Context, filter bson.M) (*models.Payment, error) {
+	var payment models.Payment
+	if err := r.collection.FindOne(ctx, filter).Decode(&payment); err != nil {
+		return nil, mongoError(err)
+	}
+	return &payment, nil
+}
+
+func (r *MongoPaymentRepository) FindByOrder(ctx context.Context, orderID primitive.ObjectID) ([]models.Payment, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"order_id": orderID}, options.Find().SetSort(bson.D{{"_id", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	payments := []models.Payment{}
+	if err := cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *MongoPaymentRepository) UpdateStatus(ctx context.Context, payment *models.Payment, from string) error {
+	update := bson.M{"$set": bson.M{
+		"status":         payment.Status,
+		"failure_reason": payment.FailureReason,
+		"refund_id":      payment.RefundID,
+		"updated_at":     payment.UpdatedAt,
+	}}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": payment.ID, "status": from}, update)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		count, err := r.collection.CountDocuments(ctx, bson.M{"_id": payment.ID}, options.Count().SetLimit(1))
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+		return ErrPaymentStatusChanged
+	}
+	return nil
+}
+
+func (r *MongoPaymentRepository) RecordEvent(ctx context.Context, event *models.PaymentEvent) error {
+	_, err := r.events.InsertOne(ctx, event)
+	return mongoError(err)
+}
+
+// MemoryPaymentRepository keeps payments and handled events in memory
+type MemoryPaymentRepository struct {
+	mu       sync.RWMutex
+	payments []models.Payment
+	events   map[string]bool
+}
+
+func NewMemoryPaymentRepository() *MemoryPaymentRepository {
+	return &MemoryPaymentRepository{events: map[string]bool{}}
+}
+
+func (r *MemoryPaymentRepository) Create(ctx context.Context, payment *models.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if payment.ID.IsZero() {
+		payment.ID = primitive.NewObjectID()
+	}
+	r.payments = append(r.payments, *payment)
+	return nil
+}
+
+func (r *MemoryPaymentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Payment, error) {
+	return r.find(func(p models.Payment) bool { return p.ID == id })
+}
+
+func (r *MemoryPaymentRepository) FindByIntent(ctx context.Context, provider, intentID string) (*models.Payment, error) {
+	return r.find(func(p models.Payment) bool { return p.Provider == provider && p.IntentID == intentID })
+}
+
+func (r *MemoryPaymentRepository) find(match func(models.Payment) bool) (*models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.payments {
+		if match(p) {
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryPaymentRepository) FindByOrder(ctx context.Context, orderID primitive.ObjectID) ([]models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	payments := []models.Payment{}
+	for _, p := range r.payments {
+		if p.OrderID == orderID {
+			payments = append(payments, p)
+		}
+	}
+	return payments, nil
+}
+
+func (r *MemoryPaymentRepository) UpdateStatus(ctx context.Context, payment *models.Payment, from string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, p := range r.payments {
+		if p.ID != payment.ID {
+			continue
+		}
+		if p.Status != from {
+			return ErrPaymentStatusChanged
+		}
+		r.payments[i].Status = payment.Status
+		r.payments[i].FailureReason = payment.FailureReason
+		r.payments[i].RefundID = payment.RefundID
+		r.payments[i].UpdatedAt = payment.UpdatedAt
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryPaymentRepository) RecordEvent(ctx context.Context, event *models.PaymentEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.events[event.ID] {
+		return &DuplicateError{Field: "_id"}
+	}
+	r.events[event.ID] = true
+	return nil
+}
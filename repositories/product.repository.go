@@ -0,0 +1,500 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ProductRepository interface {
+	Create(ctx context.Context, product *models.Product) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Product, error)
+	// FindBySKU returns the live product having a variant with the SKU
+	FindBySKU(ctx context.Context, sku string) (*models.Product, error)
+	// FindAllDetailed returns a page of matching products enriched with their category and creator
+	FindAllDetailed(ctx context.Context, query ListQuery, page PageRequest) (*Page[bson.M], error)
+	// Search returns a page of products matching the text, best matches first,
+	// enriched like FindAllDetailed and with their text score in "score"
+	Search(ctx context.Context, text string, page PageRequest) (*Page[bson.M], error)
+	// FindDetailedByID returns one product enriched with its category and creator
+	FindDetailedByID(ctx context.Context, id primitive.ObjectID) (bson.M, error)
+	Update(ctx context.Context, product *models.Product) error
+	// FindByCategory returns a page of the products of the category
+	FindByCategory(ctx context.Context, categoryID primitive.ObjectID, page PageRequest) (*Page[models.Product], error)
+	// SoftDeleteByCategory moves every product of the category to the trash
+	SoftDeleteByCategory(ctx context.Context, categoryID, deletedBy primitive.ObjectID) (int64, error)
+	// ReassignCategory moves every product of a category to another category
+	ReassignCategory(ctx context.Context, from, to, updatedBy primitive.ObjectID) (int64, error)
+	Trash[models.Product]
+}
+
+// MongoProductRepository stores products in the "products" collection
+type MongoProductRepository struct {
+	mongoTrash[models.Product]
+	collection *mongo.Collection
+}
+
+func NewMongoProductRepository(db *mongo.Database) *MongoProductRepository {
+	collection := db.Collection("products")
+	return &MongoProductRepository{
+		mongoTrash: mongoTrash[models.Product]{collection: collection, id: productID},
+		collection: collection,
+	}
+}
+
+// detailStages joins the category and the creator of a product, ignoring the ones in the trash
+func detailStages() mongo.Pipeline {
+	return mongo.Pipeline{
+		{{"$lookup", bson.D{
+			{"from", "categories"},
+			{"localField", "category_id"},
+			{"foreignField", "_id"},
+			{"pipeline", notDeletedStage()},
+			{"as", "category"},
+		}}},
+		{{"$lookup", bson.D{
+			{"from", "users"},
+			{"localField", "created_by"},
+			{"foreignField", "_id"},
+			{"pipeline", notDeletedStage()},
+			{"as", "created_by"},
+		}}},
+		{{"$unwind", bson.D{{"path", "$category"}, {"preserveNullAndEmptyArrays", true}}}},
+		{{"$unwind", bson.D{{"path", "$created_by"}, {"preserveNullAndEmptyArrays", true}}}},
+		// Exclude the password field from the user object
+		{{"$project", bson.D{
+			{"created_by.password", 0}, // Exclude password from created_by (user)
+		}}},
+	}
+}
+
+func (r *MongoProductRepository) Create(ctx context.Context, product *models.Product) error {
+	if product.ID.IsZero() {
+		product.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, product)
+	return mongoError(err)
+}
+
+func (r *MongoProductRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Product, error) {
+	var product models.Product
+	if err := r.collection.FindOne(ctx, notDeleted(bson.M{"_id": id})).Decode(&product); err != nil {
+		return nil, mongoError(err)
+	}
+	return &product, nil
+}
+
+func (r *MongoProductRepository) FindBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	var product models.Product
+	if err := r.collection.FindOne(ctx, notDeleted(bson.M{"variants.sku": sku})).Decode(&product); err != nil {
+		return nil, mongoError(err)
+	}
+	return &product, nil
+}
+
+func (r *MongoProductRepository) FindAllDetailed(ctx context.Context, query ListQuery, page PageRequest) (*Page[bson.M], error) {
+	match := notDeleted(query.match())
+	total, err := r.collection.CountDocuments(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter, sort and paginate before the lookups so only the returned products are joined.
+	// Fields are projected last because category and created_by only exist after the lookups.
+	pipeline := append(page.stages(match, query.sort()), detailStages()...)
+	if project := query.project(); project != nil {
+		pipeline = append(pipeline, bson.D{{"$project", project}})
+	}
+
+	products, err := r.aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(products, total, page.Limit, query.Sorted(), documentID), nil
+}
+
+func (r *MongoProductRepository) Search(ctx context.Context, text string, page PageRequest) (*Page[bson.M], error) {
+	match := notDeleted(bson.M{"$text": bson.M{"$search": text}})
+
+	total, err := r.collection.CountDocuments(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", match}},
+		{{"$addFields", bson.D{{"score", bson.D{{"$meta", "textScore"}}}}}},
+		{{"$sort", bson.D{{"score", -1}, {"_id", 1}}}},
+		{{"$skip", page.Offset}},
+		{{"$limit", page.Limit + 1}},
+	}
+
+	products, err := r.aggregate(ctx, append(pipeline, detailStages()...))
+	if err != nil {
+		return nil, err
+	}
+	return newPage(products, total, page.Limit, true, documentID), nil
+}
+
+func (r *MongoProductRepository) FindDetailedByID(ctx context.Context, id primitive.ObjectID) (bson.M, error) {
+	pipeline := append(mongo.Pipeline{{{"$match", notDeleted(bson.M{"_id": id})}}}, detailStages()...)
+
+	products, err := r.aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, ErrNotFound
+	}
+	return products[0], nil
+}
+
+func (r *MongoProductRepository) aggregate(ctx context.Context, pipeline mongo.Pipeline) ([]bson.M, error) {
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var products []bson.M // Use bson.M to handle dynamic structure
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *MongoProductRepository) Update(ctx context.Context, product *models.Product) error {
+	update := bson.M{"$set": bson.M{
+		"name":        product.Name,
+		"description": product.Description,
+		"price":       product.Price,
+		"prices":      product.Prices,
+		"image":       product.Image,
+		"images":      product.Images,
+		"category_id": product.CategoryID,
+		"variants":    product.Variants,
+		"updated_at":  product.UpdatedAt,
+		"updated_by":  product.UpdatedBy,
+	}}
+
+	result, err := r.collection.UpdateOne(ctx, notDeleted(bson.M{"_id": product.ID}), update)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MongoProductRepository) FindByCategory(ctx context.Context, categoryID primitive.ObjectID, page PageRequest) (*Page[models.Product], error) {
+	filter := notDeleted(bson.M{"category_id": categoryID})
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Find(ctx, page.withCursor(filter), page.findOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var products []models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return newPage(products, total, page.Limit, false, productID), nil
+}
+
+func (r *MongoProductRepository) SoftDeleteByCategory(ctx context.Context, categoryID, deletedBy primitive.ObjectID) (int64, error) {
+	update := bson.M{"$set": bson.M{
+		"deleted_at": primitive.NewDateTimeFromTime(time.Now()),
+		"deleted_by": deletedBy,
+	}}
+
+	result, err := r.collection.UpdateMany(ctx, notDeleted(bson.M{"category_id": categoryID}), update)
+	if err != nil {
+		return 0, mongoError(err)
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *MongoProductRepository) ReassignCategory(ctx context.Context, from, to, updatedBy primitive.ObjectID) (int64, error) {
+	update := bson.M{"$set": bson.M{
+		"category_id": to,
+		"updated_at":  primitive.NewDateTimeFromTime(time.Now()),
+		"updated_by":  updatedBy,
+	}}
+
+	result, err := r.collection.UpdateMany(ctx, notDeleted(bson.M{"category_id": from}), update)
+	if err != nil {
+		return 0, mongoError(err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// MemoryProductRepository keeps products in memory and resolves relations
+// against the in-memory category and user repositories
+type MemoryProductRepository struct {
+	memoryTrash[models.Product]
+	mu         sync.RWMutex
+	products   []models.Product
+	categories *MemoryCategoryRepository
+	users      *MemoryUserRepository
+}
+
+func NewMemoryProductRepository(categories *MemoryCategoryRepository, users *MemoryUserRepository) *MemoryProductRepository {
+	r := &MemoryProductRepository{categories: categories, users: users}
+	r.memoryTrash = memoryTrash[models.Product]{mu: &r.mu, items: &r.products, id: productID, deletion: productDeletion, conflict: productConflict}
+	return r
+}
+
+func productID(p models.Product) primitive.ObjectID { return p.ID }
+
+func productDeletion(p *models.Product) *models.SoftDelete { return &p.SoftDelete }
+
+// productConflict mirrors the unique indexes on the name and the variant SKUs of
+// live products
+func productConflict(a, b *models.Product) error {
+	if a.Name == b.Name {
+		return &DuplicateError{Field: "name"}
+	}
+	for _, variant := range a.Variants {
+		if b.Variant(variant.SKU) != nil {
+			return &DuplicateError{Field: "variants.sku"}
+		}
+	}
+	return nil
+}
+
+func (r *MemoryProductRepository) Create(ctx context.Context, product *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range r.products {
+		if p.ID == product.ID {
+			return &DuplicateError{Field: "name"}
+		}
+		if !p.IsDeleted() {
+			if err := productConflict(&p, product); err != nil {
+				return err
+			}
+		}
+	}
+	if product.ID.IsZero() {
+		product.ID = primitive.NewObjectID()
+	}
+	r.products = append(r.products, *product)
+	return nil
+}
+
+func (r *MemoryProductRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.products {
+		if p.ID == id && !p.IsDeleted() {
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryProductRepository) FindBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range live(r.products, productDeletion) {
+		if p.Variant(sku) != nil {
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryProductRepository) FindAllDetailed(ctx context.Context, query ListQuery, page PageRequest) (*Page[bson.M], error) {
+	r.mu.RLock()
+	products := make(map[primitive.ObjectID]models.Product, len(r.products))
+	docs := make([]bson.M, 0, len(r.products))
+	for _, p := range live(r.products, productDeletion) {
+		doc, err := toDocument(p)
+		if err != nil {
+			r.mu.RUnlock()
+			return nil, err
+		}
+		if query.matches(doc) {
+			products[p.ID] = p
+			docs = append(docs, doc)
+		}
+	}
+	r.mu.RUnlock()
+
+	var result *Page[bson.M]
+	if query.Sorted() {
+		query.sortDocuments(docs)
+		result = slicePage(docs, page.Offset, page.Limit, true, documentID)
+	} else {
+		result = paginate(docs, page, documentID)
+	}
+
+	for i, doc := range result.Items {
+		detail, err := r.detail(ctx, products[documentID(doc)])
+		if err != nil {
+			return nil, err
+		}
+		result.Items[i] = query.selectFields(detail)
+	}
+	return result, nil
+}
+
+// productTextWeights mirrors the text index created in config.ConnectDB
+var productTextWeights = bson.D{{"name", 3}, {"description", 1}}
+
+func (r *MemoryProductRepository) Search(ctx context.Context, text string, page PageRequest) (*Page[bson.M], error) {
+	terms, excluded := SearchTerms(text)
+
+	r.mu.RLock()
+	products := make(map[primitive.ObjectID]models.Product, len(r.products))
+	docs := make([]bson.M, 0, len(r.products))
+	for _, p := range live(r.products, productDeletion) {
+		doc, err := toDocument(p)
+		if err != nil {
+			r.mu.RUnlock()
+			return nil, err
+		}
+		if score := textScore(doc, productTextWeights, terms, excluded); score > 0 {
+			doc["score"] = score
+			products[p.ID] = p
+			docs = append(docs, doc)
+		}
+	}
+	r.mu.RUnlock()
+
+	sortByScore(docs)
+	result := slicePage(docs, page.Offset, page.Limit, true, documentID)
+
+	for i, doc := range result.Items {
+		detail, err := r.detail(ctx, products[documentID(doc)])
+		if err != nil {
+			return nil, err
+		}
+		detail["score"] = doc["score"]
+		result.Items[i] = detail
+	}
+	return result, nil
+}
+
+func (r *MemoryProductRepository) FindDetailedByID(ctx context.Context, id primitive.ObjectID) (bson.M, error) {
+	product, err := r.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return r.detail(ctx, *product)
+}
+
+// detail mirrors detailStages: unmatched relations are dropped from the document
+func (r *MemoryProductRepository) detail(ctx context.Context, product models.Product) (bson.M, error) {
+	doc, err := toDocument(product)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(doc, "category")
+	if category, err := r.categories.FindByID(ctx, product.CategoryID); err == nil {
+		if doc["category"], err = toDocument(category); err != nil {
+			return nil, err
+		}
+	}
+
+	delete(doc, "created_by")
+	if user, err := r.users.FindByID(ctx, product.CreatedBy); err == nil {
+		creator, err := toDocument(user)
+		if err != nil {
+			return nil, err
+		}
+		delete(creator, "password")
+		doc["created_by"] = creator
+	}
+	return doc, nil
+}
+
+func (r *MemoryProductRepository) Update(ctx context.Context, product *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range r.products {
+		if p.ID != product.ID && !p.IsDeleted() {
+			if err := productConflict(&p, product); err != nil {
+				return err
+			}
+		}
+	}
+	for i, p := range r.products {
+		if p.ID == product.ID && !p.IsDeleted() {
+			r.products[i].Name = product.Name
+			r.products[i].Description = product.Description
+			r.products[i].Price = product.Price
+			r.products[i].Prices = product.Prices
+			r.products[i].Image = product.Image
+			r.products[i].Images = product.Images
+			r.products[i].CategoryID = product.CategoryID
+			r.products[i].Variants = product.Variants
+			r.products[i].UpdatedAt = product.UpdatedAt
+			r.products[i].UpdatedBy = product.UpdatedBy
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryProductRepository) FindByCategory(ctx context.Context, categoryID primitive.ObjectID, page PageRequest) (*Page[models.Product], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var products []models.Product
+	for _, p := range live(r.products, productDeletion) {
+		if p.CategoryID == categoryID {
+			products = append(products, p)
+		}
+	}
+	return paginate(products, page, productID), nil
+}
+
+func (r *MemoryProductRepository) SoftDeleteByCategory(ctx context.Context, categoryID, deletedBy primitive.ObjectID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	var count int64
+	for i, p := range r.products {
+		if p.CategoryID == categoryID && !p.IsDeleted() {
+			r.products[i].SoftDelete = models.SoftDelete{DeletedAt: &now, DeletedBy: &deletedBy}
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *MemoryProductRepository) ReassignCategory(ctx context.Context, from, to, updatedBy primitive.ObjectID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	var count int64
+	for i, p := range r.products {
+		if p.CategoryID == from && !p.IsDeleted() {
+			r.products[i].CategoryID = to
+			r.products[i].UpdatedAt = now
+			r.products[i].UpdatedBy = updatedBy
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,288 @@
+package repositories
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"fiber/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Condition is a single filter such as price $gte 10
+type Condition struct {
+	Field string
+	// $eq, $ne, $gt, $gte, $lt, $lte, $in with a bson.A value or $elemMatch with
+	// a bson.M mapping paths in the elements of an array to their operators
+	Op    string
+	Value interface{}
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListQuery filters, sorts and projects a list. Fields are whitelisted by the
+// caller before they reach the repository.
+type ListQuery struct {
+	Conditions []Condition
+	Sort       []SortField
+	Fields     []string
+}
+
+// Sorted reports whether a custom order was requested, in which case cursors are not available
+func (q ListQuery) Sorted() bool {
+	return len(q.Sort) > 0
+}
+
+func (q ListQuery) match() bson.M {
+	match := bson.M{}
+	for _, cond := range q.Conditions {
+		ops, ok := match[cond.Field].(bson.M)
+		if !ok {
+			ops = bson.M{}
+			match[cond.Field] = ops
+		}
+		ops[cond.Op] = cond.Value
+	}
+	return match
+}
+
+// sort always ends with _id so pages are stable
+func (q ListQuery) sort() bson.D {
+	sortDoc := bson.D{}
+	for _, s := range q.Sort {
+		direction := 1
+		if s.Desc {
+			direction = -1
+		}
+		sortDoc = append(sortDoc, bson.E{Key: s.Field, Value: direction})
+	}
+	return append(sortDoc, bson.E{Key: "_id", Value: 1})
+}
+
+// project returns an inclusion projection or nil when every field is wanted
+func (q ListQuery) project() bson.D {
+	if len(q.Fields) == 0 {
+		return nil
+	}
+	project := bson.D{}
+	for _, field := range q.Fields {
+		project = append(project, bson.E{Key: field, Value: 1})
+	}
+	return project
+}
+
+// matches evaluates the conditions against a document, like $match would
+func (q ListQuery) matches(doc bson.M) bool {
+	for _, cond := range q.Conditions {
+		value, ok := lookup(doc, cond.Field)
+		if !ok || !matchOperator(value, cond.Op, cond.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchOperator evaluates one query operator against a value. Like in MongoDB,
+// values only match operands of the same type.
+func matchOperator(value interface{}, op string, operand interface{}) bool {
+	switch op {
+	case "$in":
+		return inValues(value, operand.(bson.A))
+	case "$elemMatch":
+		return elemMatch(value, operand.(bson.M))
+	}
+	if !sameType(value, operand) {
+		return op == "$ne"
+	}
+	cmp := compareValues(value, operand)
+	switch op {
+	case "$eq":
+		return cmp == 0
+	case "$ne":
+		return cmp != 0
+	case "$gt":
+		return cmp > 0
+	case "$gte":
+		return cmp >= 0
+	case "$lt":
+		return cmp < 0
+	case "$lte":
+		return cmp <= 0
+	}
+	return true
+}
+
+// elemMatch reports whether an element of the array satisfies every operator of the
+// filter, which maps paths in the element to their operators
+func elemMatch(value interface{}, filter bson.M) bool {
+	elements, ok := value.(bson.A)
+	if !ok {
+		return false
+	}
+	for _, element := range elements {
+		if elementMatches(element, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+func elementMatches(element interface{}, filter bson.M) bool {
+	for path, ops := range filter {
+		value, ok := lookup(element, path)
+		if !ok {
+			return false
+		}
+		for op, operand := range ops.(bson.M) {
+			if !matchOperator(value, op, operand) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sortDocuments orders documents like the $sort stage built by sort
+func (q ListQuery) sortDocuments(docs []bson.M) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, s := range q.Sort {
+			x, _ := lookup(docs[i], s.Field)
+			y, _ := lookup(docs[j], s.Field)
+			cmp := compareValues(x, y)
+			if cmp != 0 {
+				return (cmp < 0) != s.Desc
+			}
+		}
+		return documentID(docs[i]).Hex() < documentID(docs[j]).Hex()
+	})
+}
+
+// selectFields keeps only the requested fields and _id, like project
+func (q ListQuery) selectFields(doc bson.M) bson.M {
+	if len(q.Fields) == 0 {
+		return doc
+	}
+	selected := bson.M{"_id": doc["_id"]}
+	for _, field := range q.Fields {
+		if value, ok := doc[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected
+}
+
+// lookup returns the value at a dotted path such as price.amount
+func lookup(doc interface{}, path string) (interface{}, bool) {
+	value := doc
+	for _, key := range strings.Split(path, ".") {
+		var ok bool
+		switch d := value.(type) {
+		case bson.M:
+			value, ok = d[key]
+		case bson.D:
+			for _, e := range d {
+				if e.Key == key {
+					value, ok = e.Value, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// compareValues compares two BSON values of the same kind, returning -1, 0 or 1
+func compareValues(a, b interface{}) int {
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case primitive.Decimal128:
+		if y, ok := b.(primitive.Decimal128); ok {
+			return money.DecimalRat(x).Cmp(money.DecimalRat(y))
+		}
+	case primitive.ObjectID:
+		if y, ok := b.(primitive.ObjectID); ok {
+			return strings.Compare(x.Hex(), y.Hex())
+		}
+	case primitive.DateTime:
+		if y, ok := b.(primitive.DateTime); ok {
+			return compareValues(int64(x), int64(y))
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok && x != y {
+			if !x {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(typeOrder(a), typeOrder(b))
+}
+
+func inValues(value interface{}, values bson.A) bool {
+	for _, v := range values {
+		if sameType(value, v) && compareValues(value, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sameType reports whether two values are of the same BSON type, numbers being one type
+func sameType(a, b interface{}) bool {
+	if _, ok := toFloat(a); ok {
+		_, ok = toFloat(b)
+		return ok
+	}
+	return reflect.TypeOf(a) == reflect.TypeOf(b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// typeOrder gives mismatched types a deterministic order; missing values sort first like in MongoDB
+func typeOrder(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return "~"
+}
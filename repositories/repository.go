@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"errors"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrNotFound  = errors.New("document not found")
+	ErrDuplicate = errors.New("duplicate document")
+)
+
+// DuplicateError is returned when a unique index rejects a write. It matches
+// ErrDuplicate with errors.Is.
+type DuplicateError struct {
+	Field string
+}
+
+func (e *DuplicateError) Error() string {
+	if e.Field == "" {
+		return ErrDuplicate.Error()
+	}
+	return "duplicate " + e.Field
+}
+
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// Repositories bundles every repository used by the controllers
+type Repositories struct {
+	Users      UserRepository
+	Categories CategoryRepository
+	Products   ProductRepository
+	Customers  CustomerRepository
+	Orders     OrderRepository
+	Carts      CartRepository
+	Inventory  InventoryRepository
+	Payments   PaymentRepository
+	Rates      ExchangeRateRepository
+
+	Sessions      SessionRepository
+	RevokedTokens RevokedTokenRepository
+	UserTokens    UserTokenRepository
+	Throttles     LoginThrottleRepository
+	Lockouts      LockoutEventRepository
+
+	Transactor Transactor
+	Unique     UniqueChecker
+}
+
+// NewMongoRepositories returns the MongoDB repositories. Without a replica set it fails
+// with ErrNoTransactions, unless allowStandalone accepts changes without atomicity.
+func NewMongoRepositories(db *mongo.Database, allowStandalone bool) (*Repositories, error) {
+	transactor, err := NewMongoTransactor(db, allowStandalone)
+	if err != nil {
+		return nil, err
+	}
+	return &Repositories{
+		Users:      NewMongoUserRepository(db),
+		Categories: NewMongoCategoryRepository(db),
+		Products:   NewMongoProductRepository(db),
+		Customers:  NewMongoCustomerRepository(db),
+		Orders:     NewMongoOrderRepository(db),
+		Carts:      NewMongoCartRepository(db),
+		Inventory:  NewMongoInventoryRepository(db),
+		Payments:   NewMongoPaymentRepository(db),
+		Rates:      NewMongoExchangeRateRepository(db),
+
+		Sessions:      NewMongoSessionRepository(db),
+		RevokedTokens: NewMongoRevokedTokenRepository(db),
+		UserTokens:    NewMongoUserTokenRepository(db),
+		Throttles:     NewMongoLoginThrottleRepository(db),
+		Lockouts:      NewMongoLockoutEventRepository(db),
+
+		Transactor: transactor,
+		Unique:     NewMongoUniqueChecker(db),
+	}, nil
+}
+
+// NewMemoryRepositories returns repositories that keep everything in memory, useful for tests
+func NewMemoryRepositories() *Repositories {
+	users := NewMemoryUserRepository()
+	categories := NewMemoryCategoryRepository()
+	products := NewMemoryProductRepository(categories, users)
+	customers := NewMemoryCustomerRepository()
+	return &Repositories{
+		Users:      users,
+		Categories: categories,
+		Products:   products,
+		Customers:  customers,
+		Orders:     NewMemoryOrderRepository(),
+		Carts:      NewMemoryCartRepository(),
+		Inventory:  NewMemoryInventoryRepository(),
+		Payments:   NewMemoryPaymentRepository(),
+		Rates:      NewMemoryExchangeRateRepository(),
+
+		Sessions:      NewMemorySessionRepository(),
+		RevokedTokens: NewMemoryRevokedTokenRepository(),
+		UserTokens:    NewMemoryUserTokenRepository(),
+		Throttles:     NewMemoryLoginThrottleRepository(),
+		Lockouts:      NewMemoryLockoutEventRepository(),
+
+		Transactor: NewMemoryTransactor(),
+		Unique:     NewMemoryUniqueChecker(users, categories, products, customers),
+	}
+}
+
+// mongoError translates driver errors into repository errors
+func mongoError(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return &DuplicateError{Field: duplicateField(err)}
+	}
+	return err
+}
+
+// dupKeyPattern finds the first key in "E11000 ... dup key: { email: \"a@b.co\" }"
+var dupKeyPattern = regexp.MustCompile(`dup key: \{ ?"?([\w.]+)"?:`)
+
+// duplicateField returns the field of a duplicate key error, or "" when the
+// server message does not name it
+func duplicateField(err error) string {
+	if match := dupKeyPattern.FindStringSubmatch(err.Error()); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// documentID returns the _id of a generic document
+func documentID(doc bson.M) primitive.ObjectID {
+	id, _ := doc["_id"].(primitive.ObjectID)
+	return id
+}
+
+// toDocument converts a model into a generic document the same way the driver would
+func toDocument(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
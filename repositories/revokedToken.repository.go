@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RevokedTokenRepository is the denylist of access token IDs (jti) revoked before they expire
+type RevokedTokenRepository interface {
+	// Revoke adds a jti to the denylist until the token would have expired anyway
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// MongoRevokedTokenRepository stores the denylist in the "revoked_tokens" collection,
+// a TTL index on expires_at removes entries once the token has expired
+type MongoRevokedTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRevokedTokenRepository(db *mongo.Database) *MongoRevokedTokenRepository {
+	return &MongoRevokedTokenRepository{collection: db.Collection("revoked_tokens")}
+}
+
+func (r *MongoRevokedTokenRepository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := r.collection.InsertOne(ctx, bson.M{
+		"jti":        jti,
+		"expires_at": primitive.NewDateTimeFromTime(expiresAt),
+	})
+	// Revoking the same token twice is not an error
+	if err = mongoError(err); errors.Is(err, ErrDuplicate) {
+		return nil
+	}
+	return err
+}
+
+func (r *MongoRevokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"jti": jti})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// MemoryRevokedTokenRepository keeps the denylist in memory
+type MemoryRevokedTokenRepository struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+}
+
+func NewMemoryRevokedTokenRepository() *MemoryRevokedTokenRepository {
+	return &MemoryRevokedTokenRepository{tokens: make(map[string]time.Time)}
+}
+
+func (r *MemoryRevokedTokenRepository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[jti] = expiresAt
+	return nil
+}
+
+func (r *MemoryRevokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	expiresAt, ok := r.tokens[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SearchTerms splits a $text search string into lowercase terms, ignoring
+// quotes; terms prefixed with "-" are returned separately as exclusions
+func SearchTerms(text string) (terms []string, excluded []string) {
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		negated := strings.HasPrefix(word, "-")
+		word = strings.TrimFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if word == "" {
+			continue
+		}
+		if negated {
+			excluded = append(excluded, word)
+		} else {
+			terms = append(terms, word)
+		}
+	}
+	return terms, excluded
+}
+
+// countMatches counts the words of text starting with term, a rough stand-in
+// for the stemming done by MongoDB text indexes
+func countMatches(text, term string) int {
+	count := 0
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if strings.HasPrefix(word, term) {
+			count++
+		}
+	}
+	return count
+}
+
+// textScore scores a document against the weighted fields of a text index,
+// returning 0 when it does not match
+func textScore(doc bson.M, weights bson.D, terms, excluded []string) float64 {
+	score := 0.0
+	for _, field := range weights {
+		text, _ := doc[field.Key].(string)
+		weight, _ := toFloat(field.Value)
+		for _, term := range excluded {
+			if countMatches(text, term) > 0 {
+				return 0
+			}
+		}
+		for _, term := range terms {
+			score += weight * float64(countMatches(text, term))
+		}
+	}
+	return score
+}
+
+// sortByScore orders documents by descending score, then by _id
+func sortByScore(docs []bson.M) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		si, _ := docs[i]["score"].(float64)
+		sj, _ := docs[j]["score"].(float64)
+		if si != sj {
+			return si > sj
+		}
+		return documentID(docs[i]).Hex() < documentID(docs[j]).Hex()
+	})
+}
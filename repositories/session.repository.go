@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	FindByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error)
+	// MarkRotated records the session replacing id. It returns ErrNotFound when the
+	// session was already rotated or revoked, so concurrent refreshes cannot both win.
+	MarkRotated(ctx context.Context, id, replacedBy primitive.ObjectID) error
+	// RevokeFamily revokes every session of a family and returns them
+	RevokeFamily(ctx context.Context, familyID primitive.ObjectID) ([]models.Session, error)
+	// RevokeUser revokes every session of a user and returns the ones that were still active
+	RevokeUser(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error)
+}
+
+// MongoSessionRepository stores refresh token sessions in the "sessions" collection
+type MongoSessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoSessionRepository(db *mongo.Database) *MongoSessionRepository {
+	return &MongoSessionRepository{collection: db.Collection("sessions")}
+}
+
+func (r *MongoSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, session)
+	return mongoError(err)
+}
+
+func (r *MongoSessionRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	var session models.Session
+	if err := r.collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&session); err != nil {
+		return nil, mongoError(err)
+	}
+	return &session, nil
+}
+
+func (r *MongoSessionRepository) MarkRotated(ctx context.Context, id, replacedBy primitive.ObjectID) error {
+	filter := bson.M{"_id": id, "revoked": false, "replaced_by": bson.M{"$exists": false}}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"replaced_by": replacedBy}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MongoSessionRepository) RevokeFamily(ctx context.Context, familyID primitive.ObjectID) ([]models.Session, error) {
+	filter := bson.M{"family_id": familyID}
+	if _, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked": true}}); err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []models.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *MongoSessionRepository) RevokeUser(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	filter := bson.M{"user_id": userID, "revoked": false}
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []models.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked": true}}); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// MemorySessionRepository keeps sessions in memory
+type MemorySessionRepository struct {
+	mu       sync.Mutex
+	sessions []models.Session
+}
+
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{}
+}
+
+func (r *MemorySessionRepository) Create(ctx context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.sessions {
+		if s.TokenHash == session.TokenHash || s.ID == session.ID {
+			return &DuplicateError{Field: "token_hash"}
+		}
+	}
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	r.sessions = append(r.sessions, *session)
+	return nil
+}
+
+func (r *MemorySessionRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.sessions {
+		if s.TokenHash == tokenHash {
+			return &s, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemorySessionRepository) MarkRotated(ctx context.Context, id, replacedBy primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, s := range r.sessions {
+		if s.ID == id && !s.Revoked && s.ReplacedBy.IsZero() {
+			r.sessions[i].ReplacedBy = replacedBy
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemorySessionRepository) RevokeFamily(ctx context.Context, familyID primitive.ObjectID) ([]models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var revoked []models.Session
+	for i, s := range r.sessions {
+		if s.FamilyID == familyID {
+			r.sessions[i].Revoked = true
+			revoked = append(revoked, r.sessions[i])
+		}
+	}
+	return revoked, nil
+}
+
+func (r *MemorySessionRepository) RevokeUser(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var revoked []models.Session
+	for i, s := range r.sessions {
+		if s.UserID == userID && !s.Revoked {
+			r.sessions[i].Revoked = true
+			revoked = append(revoked, r.sessions[i])
+		}
+	}
+	return revoked, nil
+}
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNoTransactions is returned when MongoDB cannot run multi-document transactions
+var ErrNoTransactions = errors.New("MongoDB does not support transactions, a replica set or a sharded cluster is required")
+
+// Transactor runs a function atomically. Repositories called with the context
+// passed to fn take part in the transaction.
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// MongoTransactor runs functions inside MongoDB multi-document transactions
+type MongoTransactor struct {
+	client    *mongo.Client
+	supported bool
+}
+
+// NewMongoTransactor fails with ErrNoTransactions on a standalone MongoDB unless
+// allowStandalone is set, in which case changes run without transactions
+func NewMongoTransactor(db *mongo.Database, allowStandalone bool) (*MongoTransactor, error) {
+	t := &MongoTransactor{client: db.Client()}
+
+	// Transactions need a replica set or a sharded cluster
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := db.RunCommand(ctx, bson.D{{"hello", 1}}).Decode(&hello); err == nil {
+		t.supported = hello.SetName != "" || hello.Msg == "isdbgrid"
+	}
+	if !t.supported {
+		if !allowStandalone {
+			return nil, ErrNoTransactions
+		}
+		log.Println("⚠️ MongoDB is not a replica set, multi-document changes run without transactions")
+	}
+	return t, nil
+}
+
+func (t *MongoTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !t.supported {
+		return fn(ctx)
+	}
+
+	session, err := t.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// MemoryTransactor serializes functions; the memory repositories cannot roll back
+// so fn should check everything before its first write
+type MemoryTransactor struct {
+	mu sync.Mutex
+}
+
+func NewMemoryTransactor() *MemoryTransactor {
+	return &MemoryTransactor{}
+}
+
+func (t *MemoryTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return fn(ctx)
+}
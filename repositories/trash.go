@@ -0,0 +1,231 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Trash is implemented by the repositories of soft deletable documents.
+// Deleted documents are hidden from every other method until restored or purged.
+type Trash[T any] interface {
+	SoftDelete(ctx context.Context, id, deletedBy primitive.ObjectID) error
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	// FindDeletedByID returns a document of the trash, or ErrNotFound
+	FindDeletedByID(ctx context.Context, id primitive.ObjectID) (*T, error)
+	FindDeleted(ctx context.Context, page PageRequest) (*Page[T], error)
+	// Purge permanently removes the documents deleted before the given time and returns them
+	Purge(ctx context.Context, before time.Time) ([]T, error)
+}
+
+// notDeleted restricts the filter to documents that are not in the trash
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$exists": false}
+	return filter
+}
+
+// notDeletedStage is the $lookup sub-pipeline that skips documents in the trash
+func notDeletedStage() bson.A {
+	return bson.A{bson.D{{"$match", notDeleted(bson.M{})}}}
+}
+
+// mongoTrash implements Trash on top of a collection
+type mongoTrash[T any] struct {
+	collection *mongo.Collection
+	id         func(T) primitive.ObjectID
+}
+
+func (t mongoTrash[T]) SoftDelete(ctx context.Context, id, deletedBy primitive.ObjectID) error {
+	update := bson.M{"$set": bson.M{
+		"deleted_at": primitive.NewDateTimeFromTime(time.Now()),
+		"deleted_by": deletedBy,
+	}}
+
+	result, err := t.collection.UpdateOne(ctx, notDeleted(bson.M{"_id": id}), update)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (t mongoTrash[T]) Restore(ctx context.Context, id primitive.ObjectID) error {
+	filter := bson.M{"_id": id, "deleted_at": bson.M{"$exists": true}}
+	update := bson.M{"$unset": bson.M{"deleted_at": "", "deleted_by": ""}}
+
+	result, err := t.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (t mongoTrash[T]) FindDeletedByID(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	var item T
+	if err := t.collection.FindOne(ctx, bson.M{"_id": id, "deleted_at": bson.M{"$exists": true}}).Decode(&item); err != nil {
+		return nil, mongoError(err)
+	}
+	return &item, nil
+}
+
+func (t mongoTrash[T]) FindDeleted(ctx context.Context, page PageRequest) (*Page[T], error) {
+	filter := bson.M{"deleted_at": bson.M{"$exists": true}}
+	total, err := t.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := t.collection.Find(ctx, page.withCursor(filter), page.findOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var items []T
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return newPage(items, total, page.Limit, false, t.id), nil
+}
+
+func (t mongoTrash[T]) Purge(ctx context.Context, before time.Time) ([]T, error) {
+	filter := bson.M{"deleted_at": bson.M{"$lt": primitive.NewDateTimeFromTime(before)}}
+	cursor, err := t.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var items []T
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	// Delete one by one with the same filter so documents restored meanwhile are kept
+	purged := make([]T, 0, len(items))
+	for _, item := range items {
+		filter["_id"] = t.id(item)
+		result, err := t.collection.DeleteOne(ctx, filter)
+		if err != nil {
+			return purged, err
+		}
+		if result.DeletedCount == 1 {
+			purged = append(purged, item)
+		}
+	}
+	return purged, nil
+}
+
+// memoryTrash implements Trash on top of the items of an in-memory repository
+type memoryTrash[T any] struct {
+	mu       *sync.RWMutex
+	items    *[]T
+	id       func(T) primitive.ObjectID
+	deletion func(*T) *models.SoftDelete
+	// conflict returns a DuplicateError when two items cannot both be live
+	conflict func(a, b *T) error
+}
+
+func (t memoryTrash[T]) SoftDelete(ctx context.Context, id, deletedBy primitive.ObjectID) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range *t.items {
+		item := &(*t.items)[i]
+		if t.id(*item) == id && !t.deletion(item).IsDeleted() {
+			now := primitive.NewDateTimeFromTime(time.Now())
+			*t.deletion(item) = models.SoftDelete{DeletedAt: &now, DeletedBy: &deletedBy}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (t memoryTrash[T]) Restore(ctx context.Context, id primitive.ObjectID) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range *t.items {
+		item := &(*t.items)[i]
+		if t.id(*item) == id && t.deletion(item).IsDeleted() {
+			// Like the unique indexes, refuse to restore over a live item with the same values
+			for j := range *t.items {
+				other := &(*t.items)[j]
+				if j == i || t.deletion(other).IsDeleted() {
+					continue
+				}
+				if err := t.conflict(item, other); err != nil {
+					return err
+				}
+			}
+			*t.deletion(item) = models.SoftDelete{}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (t memoryTrash[T]) FindDeletedByID(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for i := range *t.items {
+		item := (*t.items)[i]
+		if t.id(item) == id && t.deletion(&item).IsDeleted() {
+			return &item, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (t memoryTrash[T]) FindDeleted(ctx context.Context, page PageRequest) (*Page[T], error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var deleted []T
+	for i := range *t.items {
+		if t.deletion(&(*t.items)[i]).IsDeleted() {
+			deleted = append(deleted, (*t.items)[i])
+		}
+	}
+	return paginate(deleted, page, t.id), nil
+}
+
+func (t memoryTrash[T]) Purge(ctx context.Context, before time.Time) ([]T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var kept, purged []T
+	for i := range *t.items {
+		item := (*t.items)[i]
+		if deletion := t.deletion(&item); deletion.IsDeleted() && deletion.DeletedAt.Time().Before(before) {
+			purged = append(purged, item)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	*t.items = kept
+	return purged, nil
+}
+
+// live returns the items that are not in the trash
+func live[T any](items []T, deletion func(*T) *models.SoftDelete) []T {
+	result := make([]T, 0, len(items))
+	for i := range items {
+		if !deletion(&items[i]).IsDeleted() {
+			result = append(result, items[i])
+		}
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UniqueChecker backs the unique_in_collection validation rule
+type UniqueChecker interface {
+	// Exists reports whether a live document of the collection has the value in field
+	Exists(ctx context.Context, collection, field string, value interface{}) (bool, error)
+}
+
+type MongoUniqueChecker struct {
+	db *mongo.Database
+}
+
+func NewMongoUniqueChecker(db *mongo.Database) *MongoUniqueChecker {
+	return &MongoUniqueChecker{db: db}
+}
+
+func (u *MongoUniqueChecker) Exists(ctx context.Context, collection, field string, value interface{}) (bool, error) {
+	count, err := u.db.Collection(collection).CountDocuments(ctx, notDeleted(bson.M{field: value}), options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// MemoryUniqueChecker looks values up in the in-memory repositories
+type MemoryUniqueChecker struct {
+	collections map[string]func() ([]bson.M, error)
+}
+
+func NewMemoryUniqueChecker(users *MemoryUserRepository, categories *MemoryCategoryRepository, products *MemoryProductRepository, customers *MemoryCustomerRepository) *MemoryUniqueChecker {
+	return &MemoryUniqueChecker{collections: map[string]func() ([]bson.M, error){
+		"users": func() ([]bson.M, error) { return liveDocuments(&users.mu, users.users, userDeletion) },
+		"categories": func() ([]bson.M, error) {
+			return liveDocuments(&categories.mu, categories.categories, categoryDeletion)
+		},
+		"products":  func() ([]bson.M, error) { return liveDocuments(&products.mu, products.products, productDeletion) },
+		"customers": customers.documents,
+	}}
+}
+
+func (u *MemoryUniqueChecker) Exists(ctx context.Context, collection, field string, value interface{}) (bool, error) {
+	documents, ok := u.collections[collection]
+	if !ok {
+		return false, nil
+	}
+	docs, err := documents()
+	if err != nil {
+		return false, err
+	}
+	for _, doc := range docs {
+		if stored, ok := doc[field]; ok && compareValues(stored, value) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// liveDocuments converts the live items of a memory repository into documents
+func liveDocuments[T any](mu *sync.RWMutex, items []T, deletion func(*T) *models.SoftDelete) ([]bson.M, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	docs := []bson.M{}
+	for _, item := range live(items, deletion) {
+		doc, err := toDocument(item)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
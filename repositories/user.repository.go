@@ -0,0 +1,257 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindAll(ctx context.Context, page PageRequest) (*Page[models.User], error)
+	UpdateRole(ctx context.Context, id primitive.ObjectID, role string) error
+	// VerifyEmail records that the user received a link sent to email and activates the
+	// account if it was pending. It returns ErrNotFound when the user no longer has that email.
+	VerifyEmail(ctx context.Context, id primitive.ObjectID, email string, verifiedAt time.Time) error
+	// UpdatePassword replaces the password hash of the user
+	UpdatePassword(ctx context.Context, id primitive.ObjectID, hash string) error
+	CountByRole(ctx context.Context, role string) (int64, error)
+	Trash[models.User]
+}
+
+// MongoUserRepository stores users in the "users" collection
+type MongoUserRepository struct {
+	mongoTrash[models.User]
+	collection *mongo.Collection
+}
+
+func NewMongoUserRepository(db *mongo.Database) *MongoUserRepository {
+	collection := db.Collection("users")
+	return &MongoUserRepository{
+		mongoTrash: mongoTrash[models.User]{collection: collection, id: userID},
+		collection: collection,
+	}
+}
+
+func (r *MongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, user)
+	return mongoError(err)
+}
+
+func (r *MongoUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	var user models.User
+	if err := r.collection.FindOne(ctx, notDeleted(bson.M{"_id": id})).Decode(&user); err != nil {
+		return nil, mongoError(err)
+	}
+	return &user, nil
+}
+
+func (r *MongoUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.collection.FindOne(ctx, notDeleted(bson.M{"email": email})).Decode(&user); err != nil {
+		return nil, mongoError(err)
+	}
+	return &user, nil
+}
+
+func (r *MongoUserRepository) FindAll(ctx context.Context, page PageRequest) (*Page[models.User], error) {
+	total, err := r.collection.CountDocuments(ctx, notDeleted(bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Find(ctx, page.withCursor(notDeleted(bson.M{})), page.findOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return newPage(users, total, page.Limit, false, userID), nil
+}
+
+func (r *MongoUserRepository) UpdateRole(ctx context.Context, id primitive.ObjectID, role string) error {
+	result, err := r.collection.UpdateOne(ctx, notDeleted(bson.M{"_id": id}), bson.M{"$set": bson.M{"role": role}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MongoUserRepository) VerifyEmail(ctx context.Context, id primitive.ObjectID, email string, verifiedAt time.Time) error {
+	// An update pipeline, so only pending accounts are activated and other statuses are kept
+	update := bson.A{bson.M{"$set": bson.M{
+		"email_verified_at": primitive.NewDateTimeFromTime(verifiedAt),
+		"status": bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$status", models.UserPending}}, models.UserActive, "$status",
+		}},
+	}}}
+	result, err := r.collection.UpdateOne(ctx, notDeleted(bson.M{"_id": id, "email": email}), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MongoUserRepository) UpdatePassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+	result, err := r.collection.UpdateOne(ctx, notDeleted(bson.M{"_id": id}), bson.M{"$set": bson.M{"password": hash}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MongoUserRepository) CountByRole(ctx context.Context, role string) (int64, error) {
+	return r.collection.CountDocuments(ctx, notDeleted(bson.M{"role": role}))
+}
+
+// MemoryUserRepository keeps users in memory, enforcing the same unique email index
+type MemoryUserRepository struct {
+	memoryTrash[models.User]
+	mu    sync.RWMutex
+	users []models.User
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	r := &MemoryUserRepository{}
+	r.memoryTrash = memoryTrash[models.User]{mu: &r.mu, items: &r.users, id: userID, deletion: userDeletion, conflict: userConflict}
+	return r
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if (u.Email == user.Email && !u.IsDeleted()) || u.ID == user.ID {
+			return &DuplicateError{Field: "email"}
+		}
+	}
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	r.users = append(r.users, *user)
+	return nil
+}
+
+func (r *MemoryUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.ID == id && !u.IsDeleted() {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.Email == email && !u.IsDeleted() {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserRepository) FindAll(ctx context.Context, page PageRequest) (*Page[models.User], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return paginate(live(r.users, userDeletion), page, userID), nil
+}
+
+func (r *MemoryUserRepository) UpdateRole(ctx context.Context, id primitive.ObjectID, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, u := range r.users {
+		if u.ID == id && !u.IsDeleted() {
+			r.users[i].Role = role
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryUserRepository) VerifyEmail(ctx context.Context, id primitive.ObjectID, email string, verifiedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, u := range r.users {
+		if u.ID == id && u.Email == email && !u.IsDeleted() {
+			at := primitive.NewDateTimeFromTime(verifiedAt)
+			r.users[i].EmailVerifiedAt = &at
+			if u.Status == models.UserPending {
+				r.users[i].Status = models.UserActive
+			}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryUserRepository) UpdatePassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, u := range r.users {
+		if u.ID == id && !u.IsDeleted() {
+			r.users[i].Password = hash
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryUserRepository) CountByRole(ctx context.Context, role string) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, u := range r.users {
+		if u.Role == role && !u.IsDeleted() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func userID(u models.User) primitive.ObjectID { return u.ID }
+
+func userDeletion(u *models.User) *models.SoftDelete { return &u.SoftDelete }
+
+// userConflict mirrors the unique index on the email of live users
+func userConflict(a, b *models.User) error {
+	if a.Email == b.Email {
+		return &DuplicateError{Field: "email"}
+	}
+	return nil
+}
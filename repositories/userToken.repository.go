@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserTokenRepository stores the records of the single-use tokens mailed to users
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *models.UserToken) error
+	// Use marks a token of the purpose as used and returns it. It returns ErrNotFound
+	// when the token is unknown, expired or already used, so it only succeeds once.
+	Use(ctx context.Context, id primitive.ObjectID, purpose string, now time.Time) (*models.UserToken, error)
+	// Invalidate marks the unused tokens of a user for the purpose as used
+	Invalidate(ctx context.Context, userID primitive.ObjectID, purpose string, now time.Time) error
+}
+
+// MongoUserTokenRepository stores tokens in the "user_tokens" collection, a TTL
+// index on expires_at removes them once they have expired
+type MongoUserTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserTokenRepository(db *mongo.Database) *MongoUserTokenRepository {
+	return &MongoUserTokenRepository{collection: db.Collection("user_tokens")}
+}
+
+func (r *MongoUserTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, token)
+	return mongoError(err)
+}
+
+func (r *MongoUserTokenRepository) Use(ctx context.Context, id primitive.ObjectID, purpose string, now time.Time) (*models.UserToken, error) {
+	filter := bson.M{
+		"_id":        id,
+		"purpose":    purpose,
+		"used_at":    nil,
+		"expires_at": bson.M{"$gt": primitive.NewDateTimeFromTime(now)},
+	}
+	update := bson.M{"$set": bson.M{"used_at": primitive.NewDateTimeFromTime(now)}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var token models.UserToken
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&token); err != nil {
+		return nil, mongoError(err)
+	}
+	return &token, nil
+}
+
+func (r *MongoUserTokenRepository) Invalidate(ctx context.Context, userID primitive.ObjectID, purpose string, now time.Time) error {
+	filter := bson.M{"user_id": userID, "purpose": purpose, "used_at": nil}
+	_, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"used_at": primitive.NewDateTimeFromTime(now)}})
+	return err
+}
+
+// MemoryUserTokenRepository keeps tokens in memory
+type MemoryUserTokenRepository struct {
+	mu     sync.Mutex
+	tokens []models.UserToken
+}
+
+func NewMemoryUserTokenRepository() *MemoryUserTokenRepository {
+	return &MemoryUserTokenRepository{}
+}
+
+func (r *MemoryUserTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+func (r *MemoryUserTokenRepository) Use(ctx context.Context, id primitive.ObjectID, purpose string, now time.Time) (*models.UserToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, t := range r.tokens {
+		if t.ID == id && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.Time().After(now) {
+			usedAt := primitive.NewDateTimeFromTime(now)
+			r.tokens[i].UsedAt = &usedAt
+			token := r.tokens[i]
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserTokenRepository) Invalidate(ctx context.Context, userID primitive.ObjectID, purpose string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usedAt := primitive.NewDateTimeFromTime(now)
+	for i, t := range r.tokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			r.tokens[i].UsedAt = &usedAt
+		}
+	}
+	return nil
+}
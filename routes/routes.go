@@ -1,39 +1,169 @@
 package routes
 
 import (
+	"context"
+	"fiber/config"
 	"fiber/controllers"
 	"fiber/dto"
+	"fiber/mail"
 	"fiber/middlewares"
+	"fiber/payments"
+	"fiber/policies"
+	"fiber/repositories"
+	"fiber/storage"
+	"fiber/validation"
 
 	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func SetupRoutes(app *fiber.App) {
-	app.Get("/", controllers.LoginView)
+func SetupRoutes(app *fiber.App, cfg *config.Config, repos *repositories.Repositories, store storage.Storage, provider payments.PaymentProvider, mailer mail.Mailer) {
+	authMiddleware := middlewares.AuthMiddleware(cfg, repos.RevokedTokens)
+	optionalAuthMiddleware := middlewares.OptionalAuthMiddleware(cfg, repos.RevokedTokens)
+	validation.SetUniqueChecker(repos.Unique)
+
+	authController := controllers.NewAuthController(cfg, repos.Users, repos.Sessions, repos.RevokedTokens, repos.Carts, repos.UserTokens, repos.Throttles, repos.Lockouts, mailer, store, repos.Transactor)
+	userController := controllers.NewUserController(cfg, repos.Users, repos.Sessions, repos.RevokedTokens)
+	lockoutController := controllers.NewLockoutController(cfg, repos.Users, repos.Throttles, repos.Lockouts)
+	categoryController := controllers.NewCategoryController(cfg, repos.Categories, repos.Products, repos.Transactor)
+	productController := controllers.NewProductController(cfg, repos.Products, repos.Categories, repos.Rates, store, repos.Transactor)
+	mediaController := controllers.NewMediaController(store)
+	customerController := controllers.NewCustomerController(cfg, repos.Customers, repos.Orders, repos.Transactor)
+	orderController := controllers.NewOrderController(cfg, repos.Orders, repos.Customers, repos.Products, repos.Inventory, repos.Transactor)
+	inventoryController := controllers.NewInventoryController(cfg, repos.Inventory, repos.Products)
+	cartController := controllers.NewCartController(cfg, repos.Carts, repos.Products, repos.Users, repos.Customers, repos.Orders, repos.Inventory, repos.Transactor)
+	paymentController := controllers.NewPaymentController(cfg, provider, repos.Payments, repos.Orders, repos.Inventory, repos.Transactor)
+	exchangeRateController := controllers.NewExchangeRateController(cfg, repos.Rates)
+	trashController := controllers.NewTrashController(cfg, repos.Users, repos.Categories, repos.Products)
+	canManageTrash := middlewares.RequirePermission("trash:manage")
+
+	app.Get("/", authController.LoginView)
+	// Uploaded files are public, their keys are unguessable
+	app.Get("/media/*", mediaController.GetMedia)
 	api := app.Group("/api")
 
 	auth := api.Group("/auth")
-	auth.Post("/register", middlewares.ValidateBody[dto.UserRegisterDTO](), controllers.Register)
-	auth.Post("/login", middlewares.ValidateBody[dto.UserLoginDTO](), controllers.Login)
+	auth.Post("/register", middlewares.ValidateBody[dto.UserRegisterDTO](), authController.Register)
+	auth.Post("/login", middlewares.ValidateBody[dto.UserLoginDTO](), authController.Login)
+	auth.Post("/refresh", middlewares.ValidateBody[dto.RefreshTokenDTO](), authController.Refresh)
+	auth.Post("/logout", authMiddleware, authController.Logout)
+	auth.Post("/verify-email", middlewares.ValidateBody[dto.VerifyEmailDTO](), authController.VerifyEmail)
+	auth.Post("/resend-verification", middlewares.ValidateBody[dto.ResendVerificationDTO](), authController.ResendVerification)
+	auth.Post("/forgot-password", middlewares.ValidateBody[dto.ForgotPasswordDTO](), authController.ForgotPassword)
+	auth.Post("/reset-password", middlewares.ValidateBody[dto.ResetPasswordDTO](), authController.ResetPassword)
+
+	app.Get("/auth/login", authController.LoginView)
+	// Pages opened from the links sent by email
+	app.Get("/auth/verify-email", authController.VerifyEmailView)
+	app.Get("/auth/reset-password", authController.ResetPasswordView)
+
+	// Signed by the payment provider instead of authenticated
+	app.Post("/webhooks/payments", paymentController.Webhook)
 
-	app.Get("/auth/login", controllers.LoginView)
+	// Carts work before login with the cart token, checkout needs an account
+	cart := api.Group("/cart", optionalAuthMiddleware)
+	cart.Get("/", cartController.GetCart)
+	cart.Delete("/", cartController.ClearCart)
+	cart.Post("/items", middlewares.ValidateBody[dto.CartItemDTO](), cartController.AddCartItem)
+	cart.Patch("/items/:productId", middlewares.ValidateBody[dto.CartQuantityDTO](), cartController.UpdateCartItem)
+	cart.Delete("/items/:productId", cartController.RemoveCartItem)
+	cart.Post("/checkout", authMiddleware, cartController.Checkout)
 
-	api.Use(middlewares.AuthMiddleware)
-	api.Get("/users", controllers.GetUsers)
+	api.Use(authMiddleware)
+	api.Get("/users", middlewares.RequirePermission("users:read"), userController.GetUsers)
+
+	admin := api.Group("/admin", middlewares.RequirePermission("users:write"))
+	admin.Put("/users/:id/role", middlewares.ValidateBody[dto.AssignRoleDTO](), userController.AssignRole)
+	admin.Delete("/users/:id", userController.DeleteUser)
+	admin.Post("/users/:id/restore", canManageTrash, userController.RestoreUser)
+	admin.Post("/users/:id/unlock", lockoutController.UnlockUser)
+	admin.Post("/ips/:ip/unlock", lockoutController.UnlockIP)
+	admin.Get("/lockouts", lockoutController.GetLockoutEvents)
+	admin.Get("/trash", canManageTrash, trashController.GetTrash)
 
 	category := api.Group("/categories")
+	canReadCategories := middlewares.RequirePermission("categories:read")
+	canWriteCategories := middlewares.RequirePermission("categories:write")
 
-	category.Post("/", middlewares.ValidateBody[dto.CategoryDTO](), controllers.CreateCategory)
-	category.Get("/", controllers.GetCategories)
-	category.Get("/:id", controllers.GetCategory)
-	category.Patch("/:id", middlewares.ValidateBody[dto.CategoryDTO](), controllers.UpdateCategory)
-	category.Delete("/:id", controllers.DeleteCategory)
+	category.Post("/", canWriteCategories, middlewares.ValidateBody[dto.CategoryDTO](), categoryController.CreateCategory)
+	category.Get("/", canReadCategories, categoryController.GetCategories)
+	category.Get("/tree", canReadCategories, categoryController.GetCategoryTree)
+	category.Get("/:id", canReadCategories, categoryController.GetCategory)
+	category.Patch("/:id", canWriteCategories, middlewares.ValidateBody[dto.UpdateCategoryDTO](), categoryController.UpdateCategory)
+	category.Put("/:id/parent", canWriteCategories, middlewares.ValidateBody[dto.MoveCategoryDTO](), categoryController.MoveCategory)
+	category.Delete("/:id", canWriteCategories, categoryController.DeleteCategory)
+	category.Post("/:id/restore", canManageTrash, categoryController.RestoreCategory)
 
 	product := api.Group("/products")
+	canReadProducts := middlewares.RequirePermission("products:read")
+	canWriteProducts := middlewares.RequirePermission("products:write")
+	productOwner := func(ctx context.Context, id primitive.ObjectID) (primitive.ObjectID, error) {
+		p, err := repos.Products.FindByID(ctx, id)
+		if err != nil {
+			return primitive.NilObjectID, err
+		}
+		return p.CreatedBy, nil
+	}
+
+	product.Post("/", canWriteProducts, middlewares.ValidateBody[dto.ProductDTO](), productController.CreateProduct)
+	product.Get("/", canReadProducts, productController.GetProducts)
+	product.Get("/search", canReadProducts, productController.SearchProducts)
+	product.Get("/:id", canReadProducts, productController.GetProduct)
+	product.Patch("/:id", canWriteProducts, middlewares.Authorize(policies.Product, "update", productOwner), middlewares.ValidateBody[dto.ProductDTO](), productController.UpdateProduct)
+	product.Post("/:id/variants/:sku/image", canWriteProducts, middlewares.Authorize(policies.Product, "update", productOwner), middlewares.ValidateBody[dto.VariantImageDTO](), productController.UploadVariantImage)
+	product.Delete("/:id", canWriteProducts, middlewares.Authorize(policies.Product, "delete", productOwner), productController.DeleteProduct)
+	product.Post("/:id/restore", canManageTrash, productController.RestoreProduct)
+
+	exchangeRate := api.Group("/exchange-rates")
+	canWritePricing := middlewares.RequirePermission("pricing:write")
+
+	exchangeRate.Get("/", canReadProducts, exchangeRateController.GetExchangeRates)
+	exchangeRate.Put("/:currency", canWritePricing, middlewares.ValidateBody[dto.ExchangeRateDTO](), exchangeRateController.SetExchangeRate)
+	exchangeRate.Delete("/:currency", canWritePricing, exchangeRateController.DeleteExchangeRate)
+
+	customer := api.Group("/customers")
+	canReadCustomers := middlewares.RequirePermission("customers:read")
+	canWriteCustomers := middlewares.RequirePermission("customers:write")
+
+	customer.Post("/", canWriteCustomers, middlewares.ValidateBody[dto.CustomerDTO](), customerController.CreateCustomer)
+	customer.Get("/", canReadCustomers, customerController.GetCustomers)
+	customer.Get("/:id", canReadCustomers, customerController.GetCustomer)
+	customer.Patch("/:id", canWriteCustomers, middlewares.ValidateBody[dto.UpdateCustomerDTO](), customerController.UpdateCustomer)
+	customer.Delete("/:id", canWriteCustomers, customerController.DeleteCustomer)
+
+	order := api.Group("/orders")
+	canReadOrders := middlewares.RequirePermission("orders:read")
+	canWriteOrders := middlewares.RequirePermission("orders:write")
+
+	order.Post("/", canWriteOrders, middlewares.ValidateBody[dto.OrderDTO](), orderController.CreateOrder)
+	order.Get("/", canReadOrders, orderController.GetOrders)
+	order.Get("/:id", canReadOrders, orderController.GetOrder)
+	order.Patch("/:id", canWriteOrders, middlewares.ValidateBody[dto.OrderDTO](), orderController.UpdateOrder)
+	order.Put("/:id/status", canWriteOrders, middlewares.ValidateBody[dto.OrderStatusDTO](), orderController.UpdateOrderStatus)
+
+	// Users pay the orders they placed at checkout, staff any order
+	orderOwner := func(ctx context.Context, id primitive.ObjectID) (primitive.ObjectID, error) {
+		o, err := repos.Orders.FindByID(ctx, id)
+		if err != nil {
+			return primitive.NilObjectID, err
+		}
+		return o.CreatedBy, nil
+	}
+	order.Post("/:id/payments", middlewares.Authorize(policies.Order, "pay", orderOwner), paymentController.CreatePayment)
+	order.Get("/:id/payments", middlewares.Authorize(policies.Order, "read_payments", orderOwner), paymentController.GetPayments)
+
+	payment := api.Group("/payments")
+	payment.Post("/:id/capture", canWriteOrders, paymentController.CapturePayment)
+	payment.Post("/:id/refund", canWriteOrders, paymentController.RefundPayment)
+
+	inventory := api.Group("/inventory")
+	canReadInventory := middlewares.RequirePermission("inventory:read")
+	canWriteInventory := middlewares.RequirePermission("inventory:write")
 
-	product.Post("/", middlewares.ValidateBody[dto.ProductDTO](), controllers.CreateProduct)
-	product.Get("/", controllers.GetProducts)
-	product.Get("/:id", controllers.GetProduct)
-	product.Patch("/:id", middlewares.ValidateBody[dto.ProductDTO](), controllers.UpdateProduct)
+	inventory.Get("/low-stock", canReadInventory, inventoryController.GetLowStock)
+	inventory.Get("/:productId", canReadInventory, inventoryController.GetStock)
+	inventory.Get("/:productId/movements", canReadInventory, inventoryController.GetMovements)
+	inventory.Post("/:productId/movements", canWriteInventory, middlewares.ValidateBody[dto.StockMovementDTO](), inventoryController.CreateMovement)
+	inventory.Put("/:productId/threshold", canWriteInventory, middlewares.ValidateBody[dto.StockThresholdDTO](), inventoryController.SetThreshold)
 
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"fiber/config"
+	"fiber/models"
+	"fiber/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// seedAdmin makes sure there is at least one admin: when none exists the user
+// with ADMIN_EMAIL is promoted, or created if it does not exist yet. A deleted
+// user with that email stays in the trash, it cannot be restored while the admin
+// is live.
+func seedAdmin(cfg *config.Config, users repositories.UserRepository) error {
+	if cfg.AdminEmail == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	admins, err := users.CountByRole(ctx, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	user, err := users.FindByEmail(ctx, cfg.AdminEmail)
+	if err == nil {
+		log.Printf("✅ Promoted %s to admin", cfg.AdminEmail)
+		return users.UpdateRole(ctx, user.ID, models.RoleAdmin)
+	}
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(cfg.AdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	err = users.Create(ctx, &models.User{
+		ID:       primitive.NewObjectID(),
+		Name:     "Admin",
+		Email:    cfg.AdminEmail,
+		Password: string(hashedPassword),
+		Status:   models.UserActive,
+		Role:     models.RoleAdmin,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("✅ Seeded admin %s", cfg.AdminEmail)
+	return nil
+}
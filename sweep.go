@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"fiber/config"
+	"fiber/repositories"
+)
+
+// reservationSweepBatch bounds the reservations released per sweep, the rest wait
+// for the next one
+const reservationSweepBatch = 500
+
+// startReservationSweeper periodically gives back the stock held by checkouts that
+// were not paid in time
+func startReservationSweeper(cfg *config.Config, repos *repositories.Repositories) {
+	go func() {
+		ticker := time.NewTicker(cfg.ReservationSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			releaseExpiredReservations(repos)
+			<-ticker.C
+		}
+	}()
+}
+
+func releaseExpiredReservations(repos *repositories.Repositories) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	reservations, err := repos.Inventory.FindExpiredReservations(ctx, time.Now(), reservationSweepBatch)
+	if err != nil {
+		log.Println("❌ Failed to find expired reservations:", err)
+		return
+	}
+
+	released := 0
+	for i := range reservations {
+		// A reservation paid or cancelled meanwhile is no longer active and is skipped
+		err := repos.Inventory.Release(ctx, &reservations[i], nil)
+		if errors.Is(err, repositories.ErrReservationClosed) {
+			continue
+		}
+		if err != nil {
+			log.Printf("❌ Failed to release reservation %s: %v", reservations[i].ID.Hex(), err)
+			continue
+		}
+		released++
+	}
+
+	if released > 0 {
+		log.Printf("📦 Released %d expired stock reservations", released)
+	}
+}
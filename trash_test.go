@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"fiber/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDeletedNameCanBeReused(t *testing.T) {
+	s := newTestServer(t, nil)
+	deleted := s.createProduct(t, "Pen", "2.50")
+
+	if status, body := s.request(t, "DELETE", "/api/products/"+deleted.ID.Hex(), s.adminToken, nil); status != fiber.StatusOK {
+		t.Fatalf("delete: %d %v", status, body)
+	}
+	product := fiber.Map{"name": "Pen", "description": "Blue", "price": 3, "category_id": deleted.CategoryID.Hex()}
+	if status, body := s.request(t, "POST", "/api/products/", s.adminToken, product); status != fiber.StatusCreated {
+		t.Fatalf("create: %d %v", status, body)
+	}
+
+	// The deleted product cannot come back while the new one has its name
+	status, body := s.request(t, "POST", "/api/products/"+deleted.ID.Hex()+"/restore", s.adminToken, nil)
+	expectProblem(t, status, body, fiber.StatusConflict, "duplicate")
+}
+
+func TestRestoreProductNeedsLiveCategory(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+	product := s.createProduct(t, "Pen", "2.50")
+	restore := "/api/products/" + product.ID.Hex() + "/restore"
+
+	if status, body := s.request(t, "DELETE", "/api/products/"+product.ID.Hex(), s.adminToken, nil); status != fiber.StatusOK {
+		t.Fatalf("delete: %d %v", status, body)
+	}
+
+	category, err := s.repos.Categories.FindByID(ctx, product.CategoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	category.Status = models.CategoryInactive
+	if err := s.repos.Categories.Update(ctx, category.ID, category); err != nil {
+		t.Fatal(err)
+	}
+	status, body := s.request(t, "POST", restore, s.adminToken, nil)
+	expectProblem(t, status, body, fiber.StatusConflict, "category_inactive")
+
+	if err := s.repos.Categories.SoftDelete(ctx, category.ID, primitive.NewObjectID()); err != nil {
+		t.Fatal(err)
+	}
+	status, body = s.request(t, "POST", restore, s.adminToken, nil)
+	expectProblem(t, status, body, fiber.StatusConflict, "category_deleted")
+
+	// Once the category is back and active, so is the product
+	if err := s.repos.Categories.Restore(ctx, category.ID); err != nil {
+		t.Fatal(err)
+	}
+	category.Status = models.CategoryActive
+	if err := s.repos.Categories.Update(ctx, category.ID, category); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := s.request(t, "POST", restore, s.adminToken, nil); status != fiber.StatusOK {
+		t.Fatalf("restore: %d %v", status, body)
+	}
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"fiber/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BuildCategoryTree nests categories below their parents, keeping the order of the
+// input among siblings. Categories whose parent is not in the list become roots.
+func BuildCategoryTree(categories []models.Category) []*models.CategoryNode {
+	nodes := make(map[primitive.ObjectID]*models.CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &models.CategoryNode{Category: category, Children: []*models.CategoryNode{}}
+	}
+
+	roots := []*models.CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
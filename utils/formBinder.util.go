@@ -0,0 +1,400 @@
+package utils
+
+import (
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FieldError is a form value that could not be bound. Rule and Param describe the
+// failure like a validation rule does (number, boolean, file_max=5MB...).
+type FieldError struct {
+	Path  string
+	Field string
+	Rule  string
+	Param string
+	Kind  reflect.Kind
+}
+
+// BindErrors lists every form value that could not be bound
+type BindErrors []FieldError
+
+func (e BindErrors) Error() string {
+	paths := make([]string, 0, len(e))
+	for _, fe := range e {
+		paths = append(paths, fe.Path)
+	}
+	return "invalid form values: " + strings.Join(paths, ", ")
+}
+
+// Form is the parsed body of a multipart or urlencoded request
+type Form struct {
+	Values map[string][]string
+	Files  map[string][]*multipart.FileHeader
+}
+
+var (
+	fileHeaderType  = reflect.TypeOf(&multipart.FileHeader{})
+	timeType        = reflect.TypeOf(time.Time{})
+	objectIDType    = reflect.TypeOf(primitive.ObjectID{})
+	mongoDateType   = reflect.TypeOf(primitive.DateTime(0))
+	sizeUnits       = map[string]int64{"B": 1, "KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30}
+	trueFormValues  = map[string]bool{"true": true, "on": true, "yes": true, "1": true}
+	falseFormValues = map[string]bool{"false": true, "off": true, "no": true, "0": true}
+)
+
+// ParseForm reads a multipart/form-data or application/x-www-form-urlencoded body
+func ParseForm(c *fiber.Ctx) (*Form, error) {
+	if strings.Contains(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		multipartForm, err := c.MultipartForm()
+		if err != nil {
+			return nil, err
+		}
+		return &Form{Values: multipartForm.Value, Files: multipartForm.File}, nil
+	}
+
+	form := &Form{Values: map[string][]string{}, Files: map[string][]*multipart.FileHeader{}}
+	c.Request().PostArgs().VisitAll(func(key, value []byte) {
+		form.Values[string(key)] = append(form.Values[string(key)], string(value))
+	})
+	return form, nil
+}
+
+// BindForm parses the form body of the request into the struct pointed to by v.
+//
+// Fields are named by their form tag, or else their json tag. Nested structs use
+// dotted keys (address.city), slices take repeated keys (tags=a&tags=b, tags[]=a)
+// or indexes (items[0].qty) and maps with string keys take dotted keys too
+// (attributes.size=M), an interface{} element keeping the text. File fields are *multipart.FileHeader or a slice of
+// them and may declare rules: `file:"max=5MB,types=image/jpeg image/png"`.
+// Values that cannot be converted are returned together as BindErrors.
+func BindForm(c *fiber.Ctx, v interface{}) error {
+	form, err := ParseForm(c)
+	if err != nil {
+		return err
+	}
+	return form.Bind(v)
+}
+
+// Bind fills the struct pointed to by v, see BindForm
+func (f *Form) Bind(v interface{}) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("form can only be bound to a struct pointer, got %T", v)
+	}
+
+	var errs BindErrors
+	f.bindStruct(val.Elem(), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (f *Form) bindStruct(val reflect.Value, prefix string, errs *BindErrors) {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := formFieldName(field)
+		if name == "" {
+			continue
+		}
+		f.bindValue(val.Field(i), field, prefix+name, errs)
+	}
+}
+
+// formFieldName prefers the form tag so fields hidden from JSON can still be bound
+func formFieldName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("form"), ",")[0]; name != "" {
+		if name == "-" {
+			return ""
+		}
+		return name
+	}
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+func (f *Form) bindValue(val reflect.Value, field reflect.StructField, key string, errs *BindErrors) {
+	typ := val.Type()
+	switch {
+	case typ == fileHeaderType:
+		if files := f.files(key); len(files) > 0 {
+			f.bindFile(val, files[0], field, key, errs)
+		}
+
+	case typ.Kind() == reflect.Slice && typ.Elem() == fileHeaderType:
+		files := f.files(key)
+		slice := reflect.MakeSlice(typ, len(files), len(files))
+		for i, file := range files {
+			f.bindFile(slice.Index(i), file, field, fmt.Sprintf("%s[%d]", key, i), errs)
+		}
+		if len(files) > 0 {
+			val.Set(slice)
+		}
+
+	case isScalar(typ):
+		if values := f.Values[key]; len(values) > 0 {
+			bindScalar(val, values[0], key, errs)
+		}
+
+	case typ.Kind() == reflect.Ptr:
+		if f.has(key) {
+			elem := reflect.New(typ.Elem())
+			f.bindValue(elem.Elem(), field, key, errs)
+			val.Set(elem)
+		}
+
+	case typ.Kind() == reflect.Struct:
+		f.bindStruct(val, key+".", errs)
+
+	case typ.Kind() == reflect.Slice && isScalar(typ.Elem()):
+		values := append(append([]string{}, f.Values[key]...), f.Values[key+"[]"]...)
+		for _, index := range f.indexes(key) {
+			values = append(values, f.Values[fmt.Sprintf("%s[%d]", key, index)]...)
+		}
+		if len(values) == 0 {
+			return
+		}
+		slice := reflect.MakeSlice(typ, len(values), len(values))
+		for i, value := range values {
+			bindScalar(slice.Index(i), value, fmt.Sprintf("%s[%d]", key, i), errs)
+		}
+		val.Set(slice)
+
+	case typ.Kind() == reflect.Map && typ.Key().Kind() == reflect.String:
+		m := reflect.MakeMap(typ)
+		for name, values := range f.Values {
+			entry, ok := strings.CutPrefix(name, key+".")
+			if !ok || entry == "" || strings.ContainsAny(entry, ".[") || len(values) == 0 {
+				continue
+			}
+			elem := reflect.New(typ.Elem()).Elem()
+			switch {
+			case typ.Elem().Kind() == reflect.Interface:
+				elem.Set(reflect.ValueOf(values[0]))
+			case isScalar(typ.Elem()):
+				bindScalar(elem, values[0], name, errs)
+			default:
+				continue
+			}
+			m.SetMapIndex(reflect.ValueOf(entry).Convert(typ.Key()), elem)
+		}
+		if m.Len() > 0 {
+			val.Set(m)
+		}
+
+	case typ.Kind() == reflect.Slice:
+		indexes := f.indexes(key)
+		if len(indexes) == 0 {
+			return
+		}
+		slice := reflect.MakeSlice(typ, len(indexes), len(indexes))
+		for i, index := range indexes {
+			f.bindValue(slice.Index(i), field, fmt.Sprintf("%s[%d]", key, index), errs)
+		}
+		val.Set(slice)
+	}
+}
+
+// bindFile applies the file rules of the field before binding the file
+func (f *Form) bindFile(val reflect.Value, file *multipart.FileHeader, field reflect.StructField, key string, errs *BindErrors) {
+	for _, rule := range strings.Split(field.Tag.Get("file"), ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "max":
+			if max, err := parseSize(param); err == nil && file.Size > max {
+				*errs = append(*errs, FieldError{Path: key, Field: lastSegment(key), Rule: "file_max", Param: param})
+				return
+			}
+		case "types":
+			contentType, err := DetectFileType(file)
+			if err != nil || !acceptsType(strings.Fields(param), contentType) {
+				*errs = append(*errs, FieldError{Path: key, Field: lastSegment(key), Rule: "file_types", Param: param})
+				return
+			}
+		}
+	}
+	val.Set(reflect.ValueOf(file))
+}
+
+func isScalar(typ reflect.Type) bool {
+	switch typ {
+	case timeType, objectIDType, mongoDateType:
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// bindScalar converts one form value; empty values leave the zero value so optional
+// fields can be submitted blank
+func bindScalar(val reflect.Value, value, key string, errs *BindErrors) {
+	typ := val.Type()
+	if value == "" && typ.Kind() != reflect.String {
+		return
+	}
+	fail := func(rule string) {
+		*errs = append(*errs, FieldError{Path: key, Field: lastSegment(key), Rule: rule, Kind: typ.Kind()})
+	}
+
+	switch typ {
+	case objectIDType:
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			fail("objectid")
+			return
+		}
+		val.Set(reflect.ValueOf(id))
+		return
+	case timeType, mongoDateType:
+		t, err := parseFormTime(value)
+		if err != nil {
+			fail("datetime")
+			return
+		}
+		if typ == mongoDateType {
+			val.Set(reflect.ValueOf(primitive.NewDateTimeFromTime(t)))
+		} else {
+			val.Set(reflect.ValueOf(t))
+		}
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		val.SetString(value)
+	case reflect.Bool:
+		switch lower := strings.ToLower(value); {
+		case trueFormValues[lower]:
+			val.SetBool(true)
+		case falseFormValues[lower]:
+			val.SetBool(false)
+		default:
+			fail("boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, typ.Bits())
+		if err != nil {
+			fail("integer")
+			return
+		}
+		val.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, typ.Bits())
+		if err != nil {
+			fail("integer")
+			return
+		}
+		val.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, typ.Bits())
+		if err != nil {
+			fail("number")
+			return
+		}
+		val.SetFloat(n)
+	}
+}
+
+// parseFormTime accepts RFC 3339 timestamps, HTML datetime-local values and dates
+func parseFormTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// parseSize reads sizes such as 512KB or 5MB
+func parseSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	for _, unit := range []string{"KB", "MB", "GB", "B"} {
+		if number, ok := strings.CutSuffix(size, unit); ok {
+			n, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+			return n * sizeUnits[unit], err
+		}
+	}
+	return strconv.ParseInt(size, 10, 64)
+}
+
+func (f *Form) files(key string) []*multipart.FileHeader {
+	return append(append([]*multipart.FileHeader{}, f.Files[key]...), f.Files[key+"[]"]...)
+}
+
+// has reports whether the form holds a value or file for the key or below it
+func (f *Form) has(key string) bool {
+	matches := func(name string) bool {
+		return name == key || strings.HasPrefix(name, key+".") || strings.HasPrefix(name, key+"[")
+	}
+	for name := range f.Values {
+		if matches(name) {
+			return true
+		}
+	}
+	for name := range f.Files {
+		if matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexes returns the sorted indexes used with key, such as 0 and 2 for items[0].qty and items[2]
+func (f *Form) indexes(key string) []int {
+	seen := map[int]bool{}
+	collect := func(name string) {
+		rest, ok := strings.CutPrefix(name, key+"[")
+		if !ok {
+			return
+		}
+		number, _, ok := strings.Cut(rest, "]")
+		if index, err := strconv.Atoi(number); ok && err == nil && index >= 0 {
+			seen[index] = true
+		}
+	}
+	for name := range f.Values {
+		collect(name)
+	}
+	for name := range f.Files {
+		collect(name)
+	}
+
+	indexes := make([]int, 0, len(seen))
+	for index := range seen {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// lastSegment returns the field name of a path: items[2].qty gives qty
+func lastSegment(path string) string {
+	if i := strings.LastIndex(path, "."); i != -1 {
+		return path[i+1:]
+	}
+	return path
+}
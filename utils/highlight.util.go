@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Highlight HTML-escapes text and wraps every word starting with one of the
+// terms in <mark> tags. Terms must be lowercase.
+func Highlight(text string, terms []string) string {
+	var b strings.Builder
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			start := i
+			for i < len(runes) && !isWordRune(runes[i]) {
+				i++
+			}
+			b.WriteString(html.EscapeString(string(runes[start:i])))
+			continue
+		}
+
+		start := i
+		for i < len(runes) && isWordRune(runes[i]) {
+			i++
+		}
+		word := string(runes[start:i])
+		if matchesTerm(strings.ToLower(word), terms) {
+			b.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+func matchesTerm(word string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
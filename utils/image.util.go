@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fiber/storage"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // register the WebP decoder
+)
+
+// ImageSize describes a variant generated for every uploaded image
+type ImageSize struct {
+	Name   string
+	Width  int
+	Height int
+	// Crop fills the exact size instead of fitting inside it
+	Crop bool
+}
+
+// ImageSizes are the generated variants, "original" keeps the dimensions
+var ImageSizes = []ImageSize{
+	{Name: "thumb", Width: 200, Height: 200, Crop: true},
+	{Name: "medium", Width: 800, Height: 800},
+	{Name: "original"},
+}
+
+// maxImagePixels guards against decompression bombs
+const maxImagePixels = 40_000_000
+
+// ResizeImage decodes the image, applies its EXIF orientation and encodes one
+// variant per size in the same format. Re-encoding drops EXIF and any other
+// metadata, so the variants never leak e.g. the GPS location of a photo.
+func ResizeImage(r io.Reader, contentType string, sizes []ImageSize) (map[string][]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("invalid image")
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, fmt.Errorf("image exceeds %d pixels", maxImagePixels)
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, errors.New("invalid image")
+	}
+
+	variants := make(map[string][]byte, len(sizes))
+	for _, size := range sizes {
+		var resized image.Image
+		switch {
+		case size.Width == 0 || size.Height == 0:
+			resized = img
+		case size.Crop:
+			resized = imaging.Fill(img, size.Width, size.Height, imaging.Center, imaging.Lanczos)
+		default:
+			// Fit never upscales smaller images
+			resized = imaging.Fit(img, size.Width, size.Height, imaging.Lanczos)
+		}
+
+		var buf bytes.Buffer
+		if err := encodeImage(&buf, resized, contentType); err != nil {
+			return nil, err
+		}
+		variants[size.Name] = buf.Bytes()
+	}
+	return variants, nil
+}
+
+func encodeImage(w io.Writer, img image.Image, contentType string) error {
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	case "image/png":
+		return png.Encode(w, img)
+	case "image/webp":
+		return nativewebp.Encode(w, img, nil)
+	default:
+		return fmt.Errorf("unsupported image type %s", contentType)
+	}
+}
+
+// VariantKey maps "products/abc.png" to "products/abc_thumb.png" for the thumb size
+func VariantKey(key, size string) string {
+	if size == "original" {
+		return key
+	}
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_" + size + ext
+}
+
+// DeleteImage removes every variant of a stored image, failures only leave orphans behind
+func DeleteImage(ctx context.Context, store storage.Storage, key string) {
+	for _, size := range ImageSizes {
+		store.Delete(ctx, VariantKey(key, size.Name))
+	}
+}
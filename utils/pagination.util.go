@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"fiber/repositories"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PageResponse is the envelope returned by every list endpoint
+type PageResponse struct {
+	Data       interface{}       `json:"data"`
+	Total      int64             `json:"total"`
+	Page       int               `json:"page,omitempty"`
+	PerPage    int               `json:"per_page"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	Links      map[string]string `json:"links"`
+}
+
+// maxOffset bounds how many items a page may skip, deeper pages need the cursor
+const maxOffset = 1_000_000
+
+// ParsePageRequest reads the page, per_page and cursor query parameters.
+// per_page is capped at maxSize; when cursor is given page is ignored.
+func ParsePageRequest(c *fiber.Ctx, defaultSize, maxSize int) (repositories.PageRequest, error) {
+	var req repositories.PageRequest
+
+	perPage, err := queryInt(c, "per_page", defaultSize)
+	if err != nil {
+		return req, err
+	}
+	if perPage > maxSize {
+		perPage = maxSize
+	}
+	req.Limit = perPage
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := DecodeCursor(cursor)
+		if err != nil {
+			return req, err
+		}
+		req.After = after
+		return req, nil
+	}
+
+	page, err := queryInt(c, "page", 1)
+	if err != nil {
+		return req, err
+	}
+	// Checked before multiplying so a huge page cannot overflow into a negative offset
+	if page-1 > maxOffset/perPage {
+		return req, fmt.Errorf("page must be at most %d, use the cursor to go further", maxOffset/perPage+1)
+	}
+	req.Offset = (page - 1) * perPage
+	return req, nil
+}
+
+// NewPageResponse wraps a page of items with its total, next cursor and navigation links
+func NewPageResponse[T any](c *fiber.Ctx, req repositories.PageRequest, page *repositories.Page[T]) PageResponse {
+	resp := PageResponse{
+		Data:    page.Items,
+		Total:   page.Total,
+		PerPage: req.Limit,
+		Links:   map[string]string{"self": c.BaseURL() + c.OriginalURL()},
+	}
+
+	if !page.Next.IsZero() {
+		resp.NextCursor = EncodeCursor(page.Next)
+	}
+
+	if !req.After.IsZero() {
+		if resp.NextCursor != "" {
+			resp.Links["next"] = pageLink(c, map[string]string{"cursor": resp.NextCursor}, "page")
+		}
+		resp.Links["first"] = pageLink(c, map[string]string{"page": "1"}, "cursor")
+		return resp
+	}
+
+	resp.Page = req.Offset/req.Limit + 1
+	lastPage := int((page.Total + int64(req.Limit) - 1) / int64(req.Limit))
+	if lastPage < 1 {
+		lastPage = 1
+	}
+	resp.Links["first"] = pageLink(c, map[string]string{"page": "1"}, "cursor")
+	resp.Links["last"] = pageLink(c, map[string]string{"page": strconv.Itoa(lastPage)}, "cursor")
+	if resp.Page > 1 {
+		resp.Links["prev"] = pageLink(c, map[string]string{"page": strconv.Itoa(resp.Page - 1)}, "cursor")
+	}
+	if page.HasMore {
+		resp.Links["next"] = pageLink(c, map[string]string{"page": strconv.Itoa(resp.Page + 1)}, "cursor")
+	}
+	return resp
+}
+
+// EncodeCursor turns the last seen ID into an opaque cursor
+func EncodeCursor(id primitive.ObjectID) string {
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+func DecodeCursor(cursor string) (primitive.ObjectID, error) {
+	var id primitive.ObjectID
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(data) != len(id) {
+		return primitive.NilObjectID, fmt.Errorf("invalid cursor")
+	}
+	copy(id[:], data)
+	return id, nil
+}
+
+func queryInt(c *fiber.Ctx, key string, defaultValue int) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", key)
+	}
+	return n, nil
+}
+
+// pageLink rebuilds the current URL, keeping other query parameters such as filters
+func pageLink(c *fiber.Ctx, set map[string]string, remove string) string {
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+
+	c.Request().URI().QueryArgs().CopyTo(args)
+	args.Del(remove)
+	for key, value := range set {
+		args.Set(key, value)
+	}
+	return c.BaseURL() + c.Path() + "?" + args.String()
+}
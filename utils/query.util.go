@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiber/repositories"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FieldType int
+
+const (
+	StringField FieldType = iota
+	NumberField
+	ObjectIDField
+	TimeField
+	// DecimalField values are compared exactly, such as the amounts of prices
+	DecimalField
+)
+
+// FilterField describes a filterable field and the operators it accepts
+type FilterField struct {
+	Type FieldType
+	Ops  []string
+}
+
+// QuerySchema whitelists what a list endpoint can filter, sort and select
+type QuerySchema struct {
+	Filters map[string]FilterField
+	Sorts   []string
+	Fields  []string
+	// Params are extra query parameters the endpoint reads itself, as are the
+	// parameters starting with one of ParamPrefixes
+	Params        []string
+	ParamPrefixes []string
+	// Paths maps the filters and sorts that are stored below another field to
+	// their document path, e.g. price to price.amount
+	Paths map[string]string
+}
+
+// path returns where the filter or sort name is stored
+func (s QuerySchema) path(name string) string {
+	if path, ok := s.Paths[name]; ok {
+		return path
+	}
+	return name
+}
+
+// operators maps the bracket syntax (price[gte]=10) to MongoDB operators; a bare key means eq
+var operators = map[string]string{
+	"eq":     "$eq",
+	"ne":     "$ne",
+	"gt":     "$gt",
+	"gte":    "$gte",
+	"lt":     "$lt",
+	"lte":    "$lte",
+	"after":  "$gt",
+	"before": "$lt",
+}
+
+// paginationParams are read by ParsePageRequest
+var paginationParams = []string{"page", "per_page", "cursor"}
+
+// ParseListQuery turns filter, sort and fields query parameters into a list query,
+// rejecting anything the schema does not whitelist
+func ParseListQuery(c *fiber.Ctx, schema QuerySchema) (repositories.ListQuery, error) {
+	var query repositories.ListQuery
+
+	for key, value := range c.Queries() {
+		if key == "sort" || key == "fields" || contains(paginationParams, key) || contains(schema.Params, key) || hasPrefix(key, schema.ParamPrefixes) {
+			continue
+		}
+
+		name, op := key, "eq"
+		if open := strings.Index(key, "["); open != -1 && strings.HasSuffix(key, "]") {
+			name, op = key[:open], key[open+1:len(key)-1]
+		}
+
+		field, ok := schema.Filters[name]
+		if !ok {
+			return query, fmt.Errorf("unknown query parameter %s", key)
+		}
+		if !contains(field.Ops, op) {
+			return query, fmt.Errorf("operator %s is not allowed on %s", op, name)
+		}
+
+		parsed, err := parseFilterValue(field.Type, value)
+		if err != nil {
+			return query, fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		query.Conditions = append(query.Conditions, repositories.Condition{Field: schema.path(name), Op: operators[op], Value: parsed})
+	}
+
+	if sortParam := c.Query("sort"); sortParam != "" {
+		if c.Query("cursor") != "" {
+			return query, fmt.Errorf("cursor cannot be combined with sort, use page instead")
+		}
+		for _, name := range strings.Split(sortParam, ",") {
+			desc := strings.HasPrefix(name, "-")
+			name = strings.TrimPrefix(name, "-")
+			if !contains(schema.Sorts, name) {
+				return query, fmt.Errorf("cannot sort by %s", name)
+			}
+			query.Sort = append(query.Sort, repositories.SortField{Field: schema.path(name), Desc: desc})
+		}
+	}
+
+	if fieldsParam := c.Query("fields"); fieldsParam != "" {
+		for _, name := range strings.Split(fieldsParam, ",") {
+			if !contains(schema.Fields, name) {
+				return query, fmt.Errorf("unknown field %s", name)
+			}
+			query.Fields = append(query.Fields, name)
+		}
+	}
+
+	return query, nil
+}
+
+func parseFilterValue(fieldType FieldType, value string) (interface{}, error) {
+	switch fieldType {
+	case NumberField:
+		return strconv.ParseFloat(value, 64)
+	case DecimalField:
+		d, err := primitive.ParseDecimal128(value)
+		if err != nil || d.IsNaN() || d.IsInf() != 0 {
+			return nil, fmt.Errorf("expected a decimal number")
+		}
+		return d, nil
+	case ObjectIDField:
+		return primitive.ObjectIDFromHex(value)
+	case TimeField:
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return primitive.NewDateTimeFromTime(t), nil
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("expected RFC 3339 timestamp or YYYY-MM-DD date")
+		}
+		return primitive.NewDateTimeFromTime(t), nil
+	default:
+		return value, nil
+	}
+}
+
+func hasPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// SignToken returns a token such as "<id>.<unix expiry>.<signature>" for links sent by
+// email. The signature is the HMAC-SHA256 of the purpose, id and expiry keyed with
+// secret, so a token cannot be forged, extended or used for another purpose.
+func SignToken(secret, purpose, id string, expiresAt time.Time) string {
+	payload := id + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + tokenSignature(secret, purpose, payload)
+}
+
+// VerifyToken checks a token produced by SignToken for the purpose and returns its id
+func VerifyToken(secret, purpose, token string, now time.Time) (string, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", ErrInvalidToken
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(tokenSignature(secret, purpose, payload))) {
+		return "", ErrInvalidToken
+	}
+
+	id, expiry, found := strings.Cut(payload, ".")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if !found || err != nil || !now.Before(time.Unix(unix, 0)) {
+		return "", ErrInvalidToken
+	}
+	return id, nil
+}
+
+func tokenSignature(secret, purpose, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + ":" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
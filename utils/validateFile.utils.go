@@ -1,37 +1,35 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
-	"strings"
+	"net/http"
 )
 
-// ValidateFile checks file type and size
-func ValidateFile(file *multipart.FileHeader, acceptedTypes []string, maxSize int64) error {
-	// Check if file exists
-	if file == nil {
-		return fmt.Errorf("no file uploaded")
+// DetectFileType sniffs the content type of an uploaded file from its first bytes
+func DetectFileType(file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("could not read uploaded file")
 	}
+	defer f.Close()
 
-	// Check file size
-	if file.Size > maxSize {
-		return fmt.Errorf("file size exceeds %d bytes", maxSize)
+	// DetectContentType considers at most the first 512 bytes
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("could not read uploaded file")
 	}
+	return http.DetectContentType(head[:n]), nil
+}
 
-	// Extract file extension
-	fileExt := strings.ToLower(file.Filename[strings.LastIndex(file.Filename, ".")+1:])
-
-	// Validate file type
-	isValidType := false
+func acceptsType(acceptedTypes []string, contentType string) bool {
 	for _, t := range acceptedTypes {
-		if fileExt == t {
-			isValidType = true
-			break
+		if contentType == t {
+			return true
 		}
 	}
-	if !isValidType {
-		return fmt.Errorf("invalid file type, allowed: %s", strings.Join(acceptedTypes, ", "))
-	}
-
-	return nil
+	return false
 }
@@ -0,0 +1,153 @@
+package validation
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Messages maps a rule to its message. A rule may have a variant per kind of value
+// (min.string, min.number, min.items); {field}, {param} and {tag} are replaced.
+type Messages map[string]string
+
+// DefaultLocale is used when the client accepts none of the registered locales
+const DefaultLocale = "en"
+
+var locales = map[string]Messages{
+	"en": {
+		"required":             "{field} is required",
+		"email":                "{field} must be a valid email address",
+		"url":                  "{field} must be a valid URL",
+		"oneof":                "{field} must be one of: {param}",
+		"alpha":                "{field} must contain only letters",
+		"alphanum":             "{field} must contain only letters and numbers",
+		"numeric":              "{field} must be a number",
+		"objectid":             "{field} must be a valid ID",
+		"unique_in_collection": "{field} is already taken",
+		"amount":               "{field} must be a positive amount such as 12.50",
+		"iso4217":              "{field} must be a currency code such as USD",
+		"sku":                  "{field} must be a SKU of letters, digits, dots, dashes and underscores",
+		"attribute_name":       "{field} must start with a lowercase letter followed by lowercase letters, digits or underscores",
+		"required_if":          "{field} is required",
+		"unique":               "{field} must not contain duplicates",
+		"min.string":           "{field} must be at least {param} characters long",
+		"min.items":            "{field} must contain at least {param} items",
+		"min.number":           "{field} must be {param} or greater",
+		"max.string":           "{field} must be at most {param} characters long",
+		"max.items":            "{field} must contain at most {param} items",
+		"max.number":           "{field} must be {param} or less",
+		"len.string":           "{field} must be exactly {param} characters long",
+		"len.items":            "{field} must contain exactly {param} items",
+		"len.number":           "{field} must be equal to {param}",
+		"gt.number":            "{field} must be greater than {param}",
+		"gte.number":           "{field} must be {param} or greater",
+		"lt.number":            "{field} must be less than {param}",
+		"lte.number":           "{field} must be {param} or less",
+		"boolean":              "{field} must be true or false",
+		"integer":              "{field} must be a whole number",
+		"number":               "{field} must be a number",
+		"datetime":             "{field} must be a date such as 2024-01-31 or 2024-01-31T10:00:00Z",
+		"file_max":             "{field} must not be larger than {param}",
+		"file_types":           "{field} must be one of these file types: {param}",
+		"default":              "{field} does not satisfy the {tag} rule",
+	},
+	"fr": {
+		"required":             "{field} est obligatoire",
+		"email":                "{field} doit être une adresse e-mail valide",
+		"url":                  "{field} doit être une URL valide",
+		"oneof":                "{field} doit être l'une des valeurs suivantes : {param}",
+		"alpha":                "{field} ne doit contenir que des lettres",
+		"alphanum":             "{field} ne doit contenir que des lettres et des chiffres",
+		"numeric":              "{field} doit être un nombre",
+		"objectid":             "{field} doit être un identifiant valide",
+		"unique_in_collection": "{field} est déjà utilisé",
+		"amount":               "{field} doit être un montant positif comme 12.50",
+		"iso4217":              "{field} doit être un code de devise comme EUR",
+		"sku":                  "{field} doit être une référence composée de lettres, chiffres, points, tirets et tirets bas",
+		"attribute_name":       "{field} doit commencer par une lettre minuscule suivie de lettres minuscules, chiffres ou tirets bas",
+		"required_if":          "{field} est obligatoire",
+		"unique":               "{field} ne doit pas contenir de doublons",
+		"min.string":           "{field} doit contenir au moins {param} caractères",
+		"min.items":            "{field} doit contenir au moins {param} éléments",
+		"min.number":           "{field} doit être supérieur ou égal à {param}",
+		"max.string":           "{field} doit contenir au plus {param} caractères",
+		"max.items":            "{field} doit contenir au plus {param} éléments",
+		"max.number":           "{field} doit être inférieur ou égal à {param}",
+		"len.string":           "{field} doit contenir exactement {param} caractères",
+		"len.items":            "{field} doit contenir exactement {param} éléments",
+		"len.number":           "{field} doit être égal à {param}",
+		"gt.number":            "{field} doit être supérieur à {param}",
+		"gte.number":           "{field} doit être supérieur ou égal à {param}",
+		"lt.number":            "{field} doit être inférieur à {param}",
+		"lte.number":           "{field} doit être inférieur ou égal à {param}",
+		"boolean":              "{field} doit valoir true ou false",
+		"integer":              "{field} doit être un nombre entier",
+		"number":               "{field} doit être un nombre",
+		"datetime":             "{field} doit être une date comme 2024-01-31 ou 2024-01-31T10:00:00Z",
+		"file_max":             "{field} ne doit pas dépasser {param}",
+		"file_types":           "{field} doit être un fichier de type : {param}",
+		"default":              "{field} ne respecte pas la règle {tag}",
+	},
+}
+
+// RegisterLocale adds or extends a locale; missing rules fall back to English
+func RegisterLocale(locale string, messages Messages) {
+	if locales[locale] == nil {
+		locales[locale] = Messages{}
+	}
+	for rule, message := range messages {
+		locales[locale][rule] = message
+	}
+}
+
+// Locales lists the registered locales, the default one first
+func Locales() []string {
+	var names []string
+	for name := range locales {
+		if name != DefaultLocale {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultLocale}, names...)
+}
+
+// Message renders the message of a rule in the locale
+func Message(locale, tag string, kind reflect.Kind, field, param string) string {
+	if tag == "oneof" || tag == "file_types" {
+		param = strings.Join(strings.Fields(param), ", ")
+	}
+	template := lookup(locale, tag, kind)
+	return strings.NewReplacer("{field}", field, "{param}", param, "{tag}", tag).Replace(template)
+}
+
+// lookup tries the kind specific message, the rule and the default message,
+// first in the locale and then in English
+func lookup(locale, tag string, kind reflect.Kind) string {
+	keys := []string{tag, "default"}
+	if class := kindClass(kind); class != "" {
+		keys = append([]string{tag + "." + class}, keys...)
+	}
+	for _, name := range []string{locale, DefaultLocale} {
+		for _, key := range keys {
+			if template, ok := locales[name][key]; ok {
+				return template
+			}
+		}
+	}
+	return "{field} is invalid"
+}
+
+func kindClass(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return ""
+}
@@ -0,0 +1,200 @@
+package validation
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"fiber/money"
+	"fiber/repositories"
+
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// validate reports fields by their JSON name so error paths match the request body
+var validate = newValidator()
+
+// unique is set by SetUniqueChecker once the repositories exist
+var unique repositories.UniqueChecker
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	v.RegisterValidation("objectid", isObjectID)
+	v.RegisterValidation("amount", isAmount)
+	v.RegisterValidation("sku", matches(skuPattern))
+	v.RegisterValidation("attribute_name", matches(attributeNamePattern))
+	v.RegisterValidationCtx("unique_in_collection", isUniqueInCollection)
+	return v
+}
+
+// SetUniqueChecker connects the unique_in_collection rule to the database
+func SetUniqueChecker(checker repositories.UniqueChecker) {
+	unique = checker
+}
+
+// objectid accepts a hex ObjectID string or a non-zero ObjectID
+func isObjectID(fl validator.FieldLevel) bool {
+	switch value := fl.Field().Interface().(type) {
+	case string:
+		return primitive.IsValidObjectID(value)
+	case primitive.ObjectID:
+		return !value.IsZero()
+	}
+	return false
+}
+
+// amount accepts a non-negative decimal number such as 12.50, given as a string or
+// json.Number so it is never rounded through a float
+func isAmount(fl validator.FieldLevel) bool {
+	if fl.Field().Kind() != reflect.String {
+		return false
+	}
+	_, err := money.ParseDecimal(fl.Field().String())
+	return err == nil
+}
+
+var (
+	skuPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+	// Attribute names are used in query parameters and document paths, so they are kept simple
+	attributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+)
+
+// matches builds a rule accepting the strings matching the pattern
+func matches(pattern *regexp.Regexp) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return fl.Field().Kind() == reflect.String && pattern.MatchString(fl.Field().String())
+	}
+}
+
+// unique_in_collection=users.email fails when a live user already has the value
+func isUniqueInCollection(ctx context.Context, fl validator.FieldLevel) bool {
+	collection, field, ok := strings.Cut(fl.Param(), ".")
+	if !ok || unique == nil {
+		return true
+	}
+	exists, err := unique.Exists(ctx, collection, field, fl.Field().Interface())
+	if err != nil {
+		// The unique index still rejects the write, do not block the request on a lookup error
+		log.Printf("unique_in_collection lookup on %s failed: %v", fl.Param(), err)
+		return true
+	}
+	return !exists
+}
+
+// Struct validates v and returns the messages of every failing rule keyed by the
+// field path (items[2].qty), in the given locale. It returns nil when v is valid.
+func Struct(ctx context.Context, v interface{}, locale string) map[string][]string {
+	err := validate.StructCtx(ctx, v)
+	if err == nil {
+		return nil
+	}
+	fieldErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return map[string][]string{"": {Message(locale, "default", reflect.Invalid, "", "")}}
+	}
+
+	root := reflect.TypeOf(v)
+	errors := map[string][]string{}
+	for _, e := range fieldErrors {
+		path := fieldPath(e.Namespace())
+		errors[path] = append(errors[path], Message(locale, e.Tag(), e.Kind(), e.Field(), e.Param()))
+
+		// The validator stops at the first failing rule of a field, check the rest too
+		for _, tag := range remainingTags(root, e) {
+			if err := validate.VarCtx(ctx, e.Value(), tag); err != nil {
+				for _, extra := range err.(validator.ValidationErrors) {
+					errors[path] = append(errors[path], Message(locale, extra.Tag(), extra.Kind(), e.Field(), extra.Param()))
+				}
+			}
+		}
+	}
+	return errors
+}
+
+// fieldPath drops the struct name from a namespace: UserDTO.items[2].qty becomes items[2].qty
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+// remainingTags returns the rules of the field declared after the one that failed.
+// Nothing is returned after required, the other rules would only repeat it.
+func remainingTags(root reflect.Type, e validator.FieldError) []string {
+	if strings.HasPrefix(e.Tag(), "required") {
+		return nil
+	}
+	tags := fieldTags(root, e.StructNamespace())
+
+	var remaining []string
+	failed := false
+	for _, tag := range tags {
+		name, _, _ := strings.Cut(tag, "=")
+		switch {
+		case name == "dive":
+			return remaining
+		case !failed:
+			failed = name == e.Tag() || tag == e.ActualTag()
+		case name != "omitempty" && !strings.HasPrefix(name, "required"):
+			remaining = append(remaining, tag)
+		}
+	}
+	return remaining
+}
+
+// fieldTags finds the validate tag of the field a struct namespace points to,
+// following pointers, slices and maps. For elements of a slice the rules after dive apply.
+func fieldTags(root reflect.Type, structNamespace string) []string {
+	segments := strings.Split(structNamespace, ".")
+	typ := root
+	var tag string
+	for _, segment := range segments[1:] {
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return nil
+		}
+		name, index, indexed := strings.Cut(segment, "[")
+		field, ok := typ.FieldByName(name)
+		if !ok {
+			return nil
+		}
+		tag = field.Tag.Get("validate")
+		typ = field.Type
+		if indexed {
+			// One level of elements per [i]
+			for range strings.Split(index, "[") {
+				for typ.Kind() == reflect.Ptr {
+					typ = typ.Elem()
+				}
+				if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+					typ = typ.Elem()
+				}
+			}
+			if _, after, ok := strings.Cut(tag, "dive,"); ok {
+				tag = after
+			} else {
+				tag = ""
+			}
+		}
+	}
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"fiber/payments"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// pendingPayment places an order for 2 of a product with 5 in stock and starts its
+// payment, returning the order ID, the product ID and the payment intent
+func (s *testServer) pendingPayment(t *testing.T) (orderID, productID, intentID string) {
+	t.Helper()
+
+	pen := s.createProduct(t, "Pen", "2.50")
+	s.receiveStock(t, pen, 5)
+	token := s.register(t, "Vivian", "vivian@example.com")
+	s.addToCart(t, token, pen, 2)
+	orderID = s.checkout(t, token)["id"].(string)
+
+	status, body := s.request(t, "POST", "/api/orders/"+orderID+"/payments", token, nil)
+	if status != fiber.StatusCreated {
+		t.Fatalf("pay: %d %v", status, body)
+	}
+	return orderID, pen.ID.Hex(), body["data"].(map[string]interface{})["intent_id"].(string)
+}
+
+// webhook delivers a notification as the payment provider would
+func (s *testServer) webhook(t *testing.T, payload []byte, signature string) (int, map[string]interface{}) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/webhooks/payments", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(payments.SignatureHeader, signature)
+	return s.send(t, req)
+}
+
+// orderStatus returns the status of the order
+func (s *testServer) orderStatus(t *testing.T, orderID string) string {
+	t.Helper()
+
+	status, body := s.request(t, "GET", "/api/orders/"+orderID, s.adminToken, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("order: %d %v", status, body)
+	}
+	return body["data"].(map[string]interface{})["status"].(string)
+}
+
+func TestWebhookRejectsInvalidSignature(t *testing.T) {
+	s := newTestServer(t, nil)
+	orderID, _, intentID := s.pendingPayment(t)
+
+	payload, signature, err := s.provider.Notify(intentID, payments.EventPaymentSucceeded, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := bytes.Replace(payload, []byte(intentID), []byte(intentID+"x"), 1)
+	for name, delivery := range map[string]struct {
+		payload   []byte
+		signature string
+	}{
+		"missing signature": {payload, ""},
+		"wrong signature":   {payload, signature + "0"},
+		"altered payload":   {forged, signature},
+	} {
+		status, body := s.webhook(t, delivery.payload, delivery.signature)
+		if status != fiber.StatusBadRequest || body["code"] != "invalid_signature" {
+			t.Fatalf("%s: got %d %v, want 400 invalid_signature", name, status, body)
+		}
+	}
+
+	if got := s.orderStatus(t, orderID); got != "pending" {
+		t.Fatalf("order status = %s, want pending", got)
+	}
+}
+
+func TestWebhookIsIdempotent(t *testing.T) {
+	s := newTestServer(t, nil)
+	orderID, productID, intentID := s.pendingPayment(t)
+
+	payload, signature, err := s.provider.Notify(intentID, payments.EventPaymentSucceeded, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, body := s.webhook(t, payload, signature); status != fiber.StatusOK || body["message"] != "Event processed" {
+		t.Fatalf("first delivery: %d %v", status, body)
+	}
+	// Providers redeliver events until they are acknowledged
+	if status, body := s.webhook(t, payload, signature); status != fiber.StatusOK || body["message"] != "Event already processed" {
+		t.Fatalf("redelivery: %d %v", status, body)
+	}
+
+	if got := s.orderStatus(t, orderID); got != "paid" {
+		t.Fatalf("order status = %s, want paid", got)
+	}
+	// The stock left once
+	if onHand, reserved := s.stockOf(t, productID); onHand != 3 || reserved != 0 {
+		t.Fatalf("stock = %v on hand, %v reserved, want 3 and 0", onHand, reserved)
+	}
+}